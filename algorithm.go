@@ -0,0 +1,125 @@
+//
+// algorithm.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"fmt"
+)
+
+// Algorithm specifies the compression algorithm.
+type Algorithm int
+
+// Compression algorithms.
+const (
+	AlgorithmLZ77 Algorithm = iota
+	AlgorithmLZ77Huffman
+	AlgorithmLZNT1
+)
+
+var algorithmNames = map[Algorithm]string{
+	AlgorithmLZ77:        "LZ77",
+	AlgorithmLZ77Huffman: "LZ77+Huffman",
+	AlgorithmLZNT1:       "LZNT1",
+}
+
+func (algo Algorithm) String() string {
+	name, ok := algorithmNames[algo]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Algorithm %d}", algo)
+}
+
+// Format specifies the compression format. The formats are the
+// compression algorithms.
+type Format = Algorithm
+
+// Compression formats.
+const (
+	FormatLZ77        = AlgorithmLZ77
+	FormatLZ77Huffman = AlgorithmLZ77Huffman
+	FormatLZNT1       = AlgorithmLZNT1
+)
+
+// Decompress decompresses data in the format and appends the
+// decompressed data to out. The function returns an error for an
+// unknown format.
+func Decompress(format Format, data, out []byte) ([]byte, error) {
+	d := &decoder{
+		out:   out,
+		start: len(out),
+		fast:  true,
+	}
+	if err := d.decode(format, data); err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// Decompressor decompresses data in a compression format. Decompress
+// appends the decompressed data to out.
+type Decompressor interface {
+	Decompress(data, out []byte) ([]byte, error)
+}
+
+// LZ77 is the Decompressor of the plain LZ77 format.
+type LZ77 struct{}
+
+// Decompress implements Decompressor.
+func (LZ77) Decompress(data, out []byte) ([]byte, error) {
+	return Decompress(FormatLZ77, data, out)
+}
+
+// LZ77Huffman is the Decompressor of the LZ77+Huffman format.
+type LZ77Huffman struct{}
+
+// Decompress implements Decompressor.
+func (LZ77Huffman) Decompress(data, out []byte) ([]byte, error) {
+	return Decompress(FormatLZ77Huffman, data, out)
+}
+
+// LZNT1 is the Decompressor of the LZNT1 format.
+type LZNT1 struct{}
+
+// Decompress implements Decompressor.
+func (LZNT1) Decompress(data, out []byte) ([]byte, error) {
+	return Decompress(FormatLZNT1, data, out)
+}
+
+// DecompressorFor returns the Decompressor of the format. The
+// function returns an error for an unknown format.
+func DecompressorFor(format Format) (Decompressor, error) {
+	switch format {
+	case FormatLZ77:
+		return LZ77{}, nil
+	case FormatLZ77Huffman:
+		return LZ77Huffman{}, nil
+	case FormatLZNT1:
+		return LZNT1{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown algorithm %s", format)
+	}
+}
+
+// decode decompresses data with the algorithm algo.
+func (d *decoder) decode(algo Algorithm, data []byte) error {
+	if d.maxExpansion > 0 && len(data) <= maxOutput/d.maxExpansion {
+		d.expansionLimit = max(1, d.maxExpansion*len(data))
+	}
+	switch algo {
+	case AlgorithmLZ77:
+		return d.lz77(data)
+	case AlgorithmLZ77Huffman:
+		return d.lz77Huffman(data)
+	case AlgorithmLZNT1:
+		return d.lznt1(data)
+	default:
+		return fmt.Errorf("Unknown algorithm %s", algo)
+	}
+}
@@ -0,0 +1,154 @@
+//
+// bitreader.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"fmt"
+)
+
+// BitReader reads the LZ77+Huffman bitstream. The bitstream is a
+// sequence of 16-bit little-endian words and the bits of each word
+// are read from the most significant bit. The reader buffers the next
+// 32 bits: it loads two words when it starts and loads the next word
+// into the bits below the valid bits as the bits are consumed.
+// The bytes of the extended match lengths are read with ReadByte and
+// ReadUint16 from the byte position after the loaded words.
+type BitReader struct {
+	in *input
+
+	// next has the buffered bits, the next bit in the most
+	// significant bit. The buffer has 16+extra valid bits. The words
+	// has the input offsets of the two words in the buffer.
+	next   uint32
+	extra  int
+	words  [2]int
+	loaded bool
+}
+
+// NewBitReader creates a new bit reader for the data.
+func NewBitReader(data []byte) *BitReader {
+	return &BitReader{
+		in: &input{
+			input: data,
+		},
+	}
+}
+
+// load loads the first two words of the bitstream.
+func (r *BitReader) load() error {
+	r.words[0] = r.in.pos
+	b, err := r.in.ReadUint16()
+	if err != nil {
+		return err
+	}
+	r.next = uint32(b) << 16
+	r.words[1] = r.in.pos
+	b, err = r.in.ReadUint16()
+	if err != nil {
+		return err
+	}
+	r.next |= uint32(b)
+	r.extra = 16
+	r.loaded = true
+	return nil
+}
+
+// consume drops the n bits from the buffer without refilling it.
+func (r *BitReader) consume(n int) {
+	r.next <<= uint(n)
+	r.extra -= n
+}
+
+// refill loads the next word into the buffer if fewer than 16 valid
+// bits remain.
+func (r *BitReader) refill() error {
+	if r.extra >= 0 {
+		return nil
+	}
+	pos := r.in.pos
+	b, err := r.in.ReadUint16()
+	if err != nil {
+		return err
+	}
+	r.next |= uint32(b) << uint(-r.extra)
+	r.extra += 16
+	r.words[0] = r.words[1]
+	r.words[1] = pos
+	return nil
+}
+
+// bitOffset returns the input bit position of the next bit. The
+// position is the input offset of the byte that holds the bit times 8
+// plus the bit's position in the byte from the most significant bit.
+// The words are little-endian so their most significant bits are in
+// their second bytes.
+func (r *BitReader) bitOffset() int {
+	// The bit's position in its word from the most significant bit.
+	word, bit := r.words[1], -r.extra
+	if r.extra > 0 {
+		word, bit = r.words[0], 16-r.extra
+	}
+	if bit < 8 {
+		return (word+1)*8 + bit
+	}
+	return word*8 + bit - 8
+}
+
+// valid returns the number of valid bits in the buffer.
+func (r *BitReader) valid() int {
+	return 16 + r.extra
+}
+
+// ReadBits reads the next n bits, 0 <= n <= 16, and returns them in
+// the low bits of the result. The reader loads the next word when the
+// buffer has fewer than n bits.
+func (r *BitReader) ReadBits(n int) (uint32, error) {
+	if n < 0 || n > 16 {
+		return 0, fmt.Errorf("Invalid bit count %d", n)
+	}
+	if !r.loaded {
+		if err := r.load(); err != nil {
+			return 0, err
+		}
+	}
+	if r.valid() < n {
+		if err := r.refill(); err != nil {
+			return 0, err
+		}
+	}
+	// With 0 bits, the shift is 32 and the value is 0.
+	v := r.next >> uint(32-n)
+	r.consume(n)
+	return v, nil
+}
+
+// Align discards the buffered bits. The next ReadBits loads the
+// bitstream words from the current byte position, after the words
+// that the reader has loaded.
+func (r *BitReader) Align() {
+	r.next = 0
+	r.extra = 0
+	r.loaded = false
+}
+
+// ReadByte reads a byte from the current byte position.
+func (r *BitReader) ReadByte() (byte, error) {
+	return r.in.ReadByte()
+}
+
+// ReadUint16 reads a 16-bit little-endian value from the current byte
+// position.
+func (r *BitReader) ReadUint16() (uint16, error) {
+	return r.in.ReadUint16()
+}
+
+// Offset returns the current byte position.
+func (r *BitReader) Offset() int {
+	return r.in.pos
+}
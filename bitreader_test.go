@@ -0,0 +1,96 @@
+//
+// bitreader_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"testing"
+)
+
+func TestBitReader(t *testing.T) {
+	// The words 0xa5c3 and 0x0ff0 are stored in little-endian order
+	// and read from their most significant bits. The byte 0x42 and
+	// the word 0x1234 follow the two loaded words.
+	data := []byte{
+		0xc3, 0xa5, 0xf0, 0x0f,
+		0x42, 0x34, 0x12,
+		0x81, 0x7e,
+	}
+	r := NewBitReader(data)
+	for i, c := range []struct {
+		n     int
+		value uint32
+	}{
+		{1, 1},
+		{3, 0x2},
+		{4, 0x5},
+		{0, 0},
+		{8, 0xc3},
+	} {
+		v, err := r.ReadBits(c.n)
+		if err != nil {
+			t.Fatalf("read %d: %s", i, err)
+		}
+		if v != c.value {
+			t.Errorf("read %d: got %#x, expected %#x", i, v, c.value)
+		}
+	}
+	if r.Offset() != 4 {
+		t.Errorf("offset %d, expected 4", r.Offset())
+	}
+	b, err := r.ReadByte()
+	if err != nil || b != 0x42 {
+		t.Errorf("ReadByte: %#x, %v", b, err)
+	}
+	w, err := r.ReadUint16()
+	if err != nil || w != 0x1234 {
+		t.Errorf("ReadUint16: %#x, %v", w, err)
+	}
+
+	// The second word continues with the word after the bytes.
+	for i, c := range []struct {
+		n     int
+		value uint32
+	}{
+		{12, 0x0ff},
+		{4, 0x0},
+		{16, 0x7e81},
+	} {
+		v, err := r.ReadBits(c.n)
+		if err != nil {
+			t.Fatalf("read %d: %s", i, err)
+		}
+		if v != c.value {
+			t.Errorf("read %d: got %#x, expected %#x", i, v, c.value)
+		}
+	}
+	if r.Offset() != 9 {
+		t.Errorf("offset %d, expected 9", r.Offset())
+	}
+	if _, err := r.ReadBits(1); !truncation(err) {
+		t.Errorf("got %v, expected %v", err, ErrTruncatedInput)
+	}
+
+	// Align discards the buffered bits and the reader loads the
+	// words after the first two words.
+	r = NewBitReader(data)
+	if _, err := r.ReadBits(3); err != nil {
+		t.Fatal(err)
+	}
+	r.Align()
+	v, err := r.ReadBits(16)
+	if err != nil || v != 0x3442 {
+		t.Errorf("aligned ReadBits: %#x, %v", v, err)
+	}
+	if r.Offset() != 8 {
+		t.Errorf("aligned offset %d, expected 8", r.Offset())
+	}
+	if _, err := r.ReadBits(17); err == nil {
+		t.Errorf("17-bit read accepted")
+	}
+}
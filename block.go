@@ -0,0 +1,108 @@
+//
+// block.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// BlockInfo describes a compressed block in the output of the
+// blocked compressor.
+type BlockInfo struct {
+	// Offset is the offset of the compressed block in the output.
+	Offset int
+	// CompressedSize is the size of the compressed block.
+	CompressedSize int
+	// UncompressedSize is the size of the block's uncompressed data.
+	UncompressedSize int
+}
+
+// CompressLZ77HuffmanWithManifest compresses data in blocks of
+// blockSize bytes. Each block is an independent LZ77+Huffman stream
+// that can be decompressed with DecompressLZ77Huffman. The function
+// returns the concatenated compressed blocks and the manifest
+// describing each block.
+func CompressLZ77HuffmanWithManifest(data []byte, blockSize int) (
+	[]byte, []BlockInfo, error) {
+	return new(Encoder).compressBlocks(data, blockSize, false)
+}
+
+// CompressLZ77HuffmanWithManifest compresses data in blocks like the
+// CompressLZ77HuffmanWithManifest function using the encoder's
+// parameters.
+func (enc *Encoder) CompressLZ77HuffmanWithManifest(data []byte,
+	blockSize int) ([]byte, []BlockInfo, error) {
+	return enc.compressBlocks(data, blockSize, false)
+}
+
+// CompressLZ77HuffmanDedup compresses data in blocks like
+// CompressLZ77HuffmanWithManifest but it compresses identical
+// plaintext blocks only once. The manifest entries of the duplicate
+// blocks refer to the compressed bytes of the first identical block
+// so the blocks must be located with the manifest.
+func CompressLZ77HuffmanDedup(data []byte, blockSize int) (
+	[]byte, []BlockInfo, error) {
+	return new(Encoder).compressBlocks(data, blockSize, true)
+}
+
+// CompressLZ77HuffmanDedup compresses data in blocks like the
+// CompressLZ77HuffmanDedup function using the encoder's parameters.
+func (enc *Encoder) CompressLZ77HuffmanDedup(data []byte, blockSize int) (
+	[]byte, []BlockInfo, error) {
+	return enc.compressBlocks(data, blockSize, true)
+}
+
+func (enc *Encoder) compressBlocks(data []byte, blockSize int, dedup bool) (
+	[]byte, []BlockInfo, error) {
+
+	if blockSize <= 0 {
+		return nil, nil, fmt.Errorf("Invalid block size %d", blockSize)
+	}
+	var out []byte
+	var manifest []BlockInfo
+	var err error
+	seen := make(map[[sha256.Size]byte]int)
+
+	for start := 0; start < len(data); start += blockSize {
+		end := start + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		block := data[start:end]
+
+		var digest [sha256.Size]byte
+		if dedup {
+			digest = sha256.Sum256(block)
+			idx, ok := seen[digest]
+			if ok {
+				manifest = append(manifest, manifest[idx])
+				continue
+			}
+		}
+
+		offset := len(out)
+		out, err = enc.CompressLZ77Huffman(block, out)
+		if err != nil {
+			if verr, ok := err.(*VerifyError); ok {
+				verr.Offset = start
+			}
+			return nil, nil, err
+		}
+		if dedup {
+			seen[digest] = len(manifest)
+		}
+		manifest = append(manifest, BlockInfo{
+			Offset:           offset,
+			CompressedSize:   len(out) - offset,
+			UncompressedSize: len(block),
+		})
+	}
+	return out, manifest, nil
+}
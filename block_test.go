@@ -0,0 +1,97 @@
+//
+// block_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressLZ77HuffmanWithManifest(t *testing.T) {
+	data := writerInput()
+	blockSize := 40000
+
+	out, manifest, err := CompressLZ77HuffmanWithManifest(data, blockSize)
+	if err != nil {
+		t.Fatalf("CompressLZ77HuffmanWithManifest failed: %s", err)
+	}
+	expectedBlocks := (len(data) + blockSize - 1) / blockSize
+	if len(manifest) != expectedBlocks {
+		t.Fatalf("got %d blocks, expected %d", len(manifest), expectedBlocks)
+	}
+
+	var offset, start int
+	for i, block := range manifest {
+		if block.Offset != offset {
+			t.Errorf("block %d: offset %d, expected %d",
+				i, block.Offset, offset)
+		}
+		compressed := out[block.Offset : block.Offset+block.CompressedSize]
+		decompressed, err := DecompressLZ77Huffman(compressed, nil)
+		if err != nil {
+			t.Fatalf("block %d: decompress failed: %s", i, err)
+		}
+		if len(decompressed) != block.UncompressedSize {
+			t.Errorf("block %d: got %d bytes, expected %d",
+				i, len(decompressed), block.UncompressedSize)
+		}
+		if !bytes.Equal(decompressed, data[start:start+len(decompressed)]) {
+			t.Errorf("block %d: data mismatch", i)
+		}
+		offset += block.CompressedSize
+		start += block.UncompressedSize
+	}
+	if offset != len(out) || start != len(data) {
+		t.Errorf("manifest does not cover the output")
+	}
+
+	if _, _, err := CompressLZ77HuffmanWithManifest(data, 0); err == nil {
+		t.Errorf("invalid block size accepted")
+	}
+}
+
+func TestCompressLZ77HuffmanDedup(t *testing.T) {
+	blockSize := 4096
+	blocks := [][]byte{
+		randomBytes(1, blockSize),
+		randomBytes(2, blockSize),
+	}
+	var data []byte
+	for i := 0; i < 10; i++ {
+		data = append(data, blocks[i%2]...)
+	}
+	data = append(data, blocks[0][:100]...)
+
+	plain, _, err := CompressLZ77HuffmanWithManifest(data, blockSize)
+	if err != nil {
+		t.Fatalf("CompressLZ77HuffmanWithManifest failed: %s", err)
+	}
+	out, manifest, err := CompressLZ77HuffmanDedup(data, blockSize)
+	if err != nil {
+		t.Fatalf("CompressLZ77HuffmanDedup failed: %s", err)
+	}
+	if len(out)*3 > len(plain) {
+		t.Errorf("dedup did not reduce size: %d vs. %d", len(out), len(plain))
+	}
+	if len(manifest) != 11 {
+		t.Fatalf("got %d blocks, expected 11", len(manifest))
+	}
+
+	var decompressed []byte
+	for i, block := range manifest {
+		compressed := out[block.Offset : block.Offset+block.CompressedSize]
+		decompressed, err = DecompressLZ77Huffman(compressed, decompressed)
+		if err != nil {
+			t.Fatalf("block %d: decompress failed: %s", i, err)
+		}
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Errorf("dedup round trip failed")
+	}
+}
@@ -0,0 +1,117 @@
+//
+// chunker.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+// Default Chunker parameters.
+const (
+	ChunkMinSize = 2 * 1024
+	ChunkMaxSize = 64 * 1024
+	ChunkBits    = 13
+)
+
+// chunkFeedSize is the number of output bytes that the decoder
+// collects before it feeds them to its Chunker.
+const chunkFeedSize = 4096
+
+// gearTable has the random values of the gear rolling hash.
+var gearTable = func() (table [256]uint64) {
+	// SplitMix64 with a fixed seed so that the boundaries are stable.
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+// Chunker finds content-defined chunk boundaries in a byte stream
+// with a gear rolling hash. A chunk ends after a byte where the Bits
+// high bits of the hash are zero, so the chunks are 2^Bits bytes on
+// average. The chunks are at least MinSize and at most MaxSize bytes
+// long, except possibly the last chunk. The zero values of MinSize,
+// MaxSize, and Bits select ChunkMinSize, ChunkMaxSize, and
+// ChunkBits.
+//
+// The decoders feed the decompressed data to the Chunker of
+// Options.Chunker as they produce it so that the boundaries are found
+// without a separate pass over the output. The Chunker can also be
+// used as an io.Writer for the other streams.
+type Chunker struct {
+	MinSize int
+	MaxSize int
+	Bits    uint
+
+	// OnBoundary is called with the stream offset of the end of
+	// each chunk. The chunking fails with its error.
+	OnBoundary func(offset int64) error
+
+	hash   uint64
+	size   int
+	offset int64
+}
+
+// Write feeds the bytes of p to the chunker.
+func (c *Chunker) Write(p []byte) (int, error) {
+	minSize := c.MinSize
+	if minSize == 0 {
+		minSize = ChunkMinSize
+	}
+	maxSize := c.MaxSize
+	if maxSize == 0 {
+		maxSize = ChunkMaxSize
+	}
+	bits := c.Bits
+	if bits == 0 {
+		bits = ChunkBits
+	}
+	mask := ^uint64(0) << (64 - bits)
+
+	for i, b := range p {
+		c.hash = c.hash<<1 + gearTable[b]
+		c.size++
+		if c.size >= minSize && c.hash&mask == 0 || c.size >= maxSize {
+			if err := c.boundary(int64(i + 1)); err != nil {
+				return i + 1, err
+			}
+		}
+	}
+	c.offset += int64(len(p))
+	return len(p), nil
+}
+
+// boundary reports the chunk that ends n bytes after the current
+// offset and starts a new chunk.
+func (c *Chunker) boundary(n int64) error {
+	c.hash = 0
+	c.size = 0
+	if c.OnBoundary != nil {
+		return c.OnBoundary(c.offset + n)
+	}
+	return nil
+}
+
+// Close reports the last chunk if the stream does not end at a
+// boundary.
+func (c *Chunker) Close() error {
+	if c.size == 0 {
+		return nil
+	}
+	return c.boundary(0)
+}
+
+// feedChunker writes the output that the Chunker has not received to
+// the Chunker.
+func (d *decoder) feedChunker() error {
+	_, err := d.chunker.Write(d.out[d.chunked:])
+	d.chunked = len(d.out)
+	return err
+}
@@ -0,0 +1,112 @@
+//
+// chunker_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// chunkBoundaries returns the chunk boundaries of data with a
+// separate pass over the data.
+func chunkBoundaries(t *testing.T, data []byte) []int64 {
+	var boundaries []int64
+	c := &Chunker{
+		OnBoundary: func(offset int64) error {
+			boundaries = append(boundaries, offset)
+			return nil
+		},
+	}
+	if _, err := c.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return boundaries
+}
+
+func TestChunker(t *testing.T) {
+	var data []byte
+	for i := 0; i < 20; i++ {
+		data = append(data, randomBytes(int64(i), 30000)...)
+		data = append(data, repeatedMatch(20000+i)...)
+	}
+	expected := chunkBoundaries(t, data)
+	if len(expected) < 10 || expected[len(expected)-1] != int64(len(data)) {
+		t.Fatalf("unexpected boundaries %v", expected)
+	}
+	var prev int64
+	for _, b := range expected {
+		if b-prev > ChunkMaxSize ||
+			b-prev < ChunkMinSize && b != int64(len(data)) {
+			t.Fatalf("chunk %d...%d", prev, b)
+		}
+		prev = b
+	}
+
+	for _, algo := range []Algorithm{AlgorithmLZ77, AlgorithmLZ77Huffman} {
+		compressed, err := compress(algo, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var boundaries []int64
+		opts := &Options{
+			Chunker: &Chunker{
+				OnBoundary: func(offset int64) error {
+					boundaries = append(boundaries, offset)
+					return nil
+				},
+			},
+		}
+		out, err := DecompressWithOptions(algo, compressed, []byte("prefix"),
+			opts)
+		if err != nil {
+			t.Fatalf("%s: decompress failed: %s", algo, err)
+		}
+		if !bytes.Equal(out[6:], data) {
+			t.Fatalf("%s: output mismatch", algo)
+		}
+		if !reflect.DeepEqual(boundaries, expected) {
+			t.Errorf("%s: got %d boundaries, expected %d", algo,
+				len(boundaries), len(expected))
+		}
+
+		boundaries = nil
+		opts.Chunker = &Chunker{
+			OnBoundary: opts.Chunker.OnBoundary,
+		}
+		_, err = DecompressToWriter(algo, compressed, io.Discard, opts)
+		if err != nil {
+			t.Fatalf("%s: DecompressToWriter failed: %s", algo, err)
+		}
+		if !reflect.DeepEqual(boundaries, expected) {
+			t.Errorf("%s: DecompressToWriter: got %d boundaries, expected %d",
+				algo, len(boundaries), len(expected))
+		}
+	}
+
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Chunker{
+		OnBoundary: func(offset int64) error {
+			return errWriteFailed
+		},
+	}
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman, compressed, nil,
+		&Options{Chunker: c})
+	if err != errWriteFailed {
+		t.Errorf("got %v, expected %v", err, errWriteFailed)
+	}
+}
@@ -0,0 +1,57 @@
+//
+// column.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"fmt"
+)
+
+// DecompressColumn decompresses data with the algorithm algo and
+// options opts, and returns one column of the decompressed data that
+// has rows of stride bytes: the output bytes at the offsets column,
+// column+stride, column+2*stride, and so on. The function
+// de-interleaves the row-interleaved data while decoding. The decoder
+// resolves the matches from its circular window so the full output
+// is not kept in memory.
+func DecompressColumn(algo Algorithm, data []byte, stride, column int,
+	opts *Options) ([]byte, error) {
+
+	if stride <= 0 || column < 0 || column >= stride {
+		return nil, fmt.Errorf("Invalid column %d of stride %d",
+			column, stride)
+	}
+	w := &columnWriter{
+		stride: stride,
+		column: column,
+	}
+	_, err := DecompressToWriter(algo, data, w, opts)
+	if err != nil {
+		return nil, err
+	}
+	return w.out, nil
+}
+
+// columnWriter collects the column bytes of the decoder's output
+// segments.
+type columnWriter struct {
+	stride int
+	column int
+	pos    int
+	out    []byte
+}
+
+func (w *columnWriter) Write(p []byte) (int, error) {
+	// The offset of the first column byte in p.
+	i := (w.column - w.pos%w.stride + w.stride) % w.stride
+	for ; i < len(p); i += w.stride {
+		w.out = append(w.out, p[i])
+	}
+	w.pos += len(p)
+	return len(p), nil
+}
@@ -0,0 +1,56 @@
+//
+// column_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecompressColumn(t *testing.T) {
+	// Rows of a 16-bit counter, a constant tag, and a random byte.
+	const stride = 4
+	random := randomBytes(1, 50000)
+	var data []byte
+	for i, r := range random {
+		data = append(data, byte(i>>8), byte(i), 'T', r)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecompressLZ77Huffman(compressed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for column := 0; column < stride; column++ {
+		var expected []byte
+		for i := column; i < len(out); i += stride {
+			expected = append(expected, out[i])
+		}
+		got, err := DecompressColumn(AlgorithmLZ77Huffman, compressed,
+			stride, column, &Options{FlushSize: 1001})
+		if err != nil {
+			t.Fatalf("column %d: DecompressColumn failed: %s", column, err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("column %d: got %d bytes, expected %d", column,
+				len(got), len(expected))
+		}
+	}
+
+	for _, c := range [][2]int{{0, 0}, {4, 4}, {4, -1}} {
+		_, err := DecompressColumn(AlgorithmLZ77Huffman, compressed,
+			c[0], c[1], nil)
+		if err == nil {
+			t.Errorf("stride %d, column %d accepted", c[0], c[1])
+		}
+	}
+}
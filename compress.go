@@ -0,0 +1,950 @@
+//
+// compress.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidMatch is returned if a match finder returns a match that
+// does not match the data or that the algorithm can not encode.
+var ErrInvalidMatch = errors.New("Invalid match")
+
+// The longest LZ77 match has the largest 16-bit length field value
+// 0xffff, encoding the match length minus lz77MinMatch.
+const (
+	lz77MinMatch  = 3
+	lz77MaxMatch  = 0xffff + lz77MinMatch
+	lz77MaxOffset = 1 << 13
+
+	hashBits    = 15
+	maxChainLen = 64
+	noPosition  = -1
+)
+
+// matcher finds LZ77 matches using hash chains over the match
+// window. The matcher implements the default MatchFinder.
+type matcher struct {
+	window int
+	maxLen int
+	chain  int
+	head   []int
+	prev   []int
+	mask   int
+	next   int
+}
+
+// NewHashChainMatchFinder creates the default greedy hash-chain
+// match finder. The finder returns the longest match it finds within
+// window bytes before the position.
+func NewHashChainMatchFinder(window int) MatchFinder {
+	return newMatcher(window, huffmanMaxMatch, maxChainLen)
+}
+
+func newMatcher(window, maxLen, chain int) *matcher {
+	size := 1
+	for size < window {
+		size <<= 1
+	}
+	m := &matcher{
+		window: window,
+		maxLen: maxLen,
+		chain:  chain,
+		head:   make([]int, 1<<hashBits),
+		prev:   make([]int, size),
+		mask:   size - 1,
+	}
+	for i := range m.head {
+		m.head[i] = noPosition
+	}
+	return m
+}
+
+func (m *matcher) reset() {
+	for i := range m.head {
+		m.head[i] = noPosition
+	}
+	m.next = 0
+}
+
+// Find implements MatchFinder. The function inserts the positions
+// before pos into the hash chains before searching the match. If pos
+// is before the previous search position, Find starts a new input.
+func (m *matcher) Find(data []byte, pos int) (offset, length int) {
+	if pos < m.next {
+		m.reset()
+	}
+	for ; m.next < pos; m.next++ {
+		m.insert(data, m.next)
+	}
+	return m.find(data, pos)
+}
+
+func hash3(data []byte, pos int) int {
+	v := uint32(data[pos])<<16 | uint32(data[pos+1])<<8 | uint32(data[pos+2])
+	return int((v * 2654435761) >> (32 - hashBits))
+}
+
+// slide removes shift bytes from the beginning of the match
+// window. The shift must be a multiple of the chain table size.
+func (m *matcher) slide(shift int) {
+	for i, pos := range m.head {
+		if pos < shift {
+			m.head[i] = noPosition
+		} else {
+			m.head[i] = pos - shift
+		}
+	}
+	for i, pos := range m.prev {
+		if pos < shift {
+			m.prev[i] = noPosition
+		} else {
+			m.prev[i] = pos - shift
+		}
+	}
+	m.next -= shift
+}
+
+func (m *matcher) insert(data []byte, pos int) {
+	if pos+lz77MinMatch > len(data) {
+		return
+	}
+	h := hash3(data, pos)
+	m.prev[pos&m.mask] = m.head[h]
+	m.head[h] = pos
+}
+
+// find returns the longest match for data[pos:] within the match
+// window. The returned length is 0 if no match was found.
+func (m *matcher) find(data []byte, pos int) (offset, length int) {
+	if pos+lz77MinMatch > len(data) {
+		return 0, 0
+	}
+	maxLen := len(data) - pos
+	if maxLen > m.maxLen {
+		maxLen = m.maxLen
+	}
+	if pos > 0 && data[pos-1] == 0 && data[pos] == 0 && m.window > 0 {
+		// The zero runs are encoded as offset 1 matches. The hash
+		// chain candidates replace the run only if they are longer.
+		for length < maxLen && data[pos+length] == 0 {
+			length++
+		}
+		offset = 1
+		if length == maxLen {
+			return offset, length
+		}
+	}
+	cand := m.head[hash3(data, pos)]
+	for i := 0; i < m.chain && cand != noPosition; i++ {
+		dist := pos - cand
+		if dist <= 0 || dist > m.window {
+			break
+		}
+		if data[cand+length] == data[pos+length] {
+			var l int
+			for l < maxLen && data[cand+l] == data[pos+l] {
+				l++
+			}
+			if l > length {
+				offset = dist
+				length = l
+				if l == maxLen {
+					break
+				}
+			}
+		}
+		next := m.prev[cand&m.mask]
+		if next >= cand {
+			break
+		}
+		cand = next
+	}
+	if length < lz77MinMatch {
+		return 0, 0
+	}
+	return offset, length
+}
+
+// lz77Encoder emits the plain LZ77 token stream. The tokens are
+// grouped by 32-bit flag words that precede their tokens. Match
+// lengths are encoded with the same escape ladder as the decoder
+// reads them: 3 bits in the match token, a 4-bit nibble shared
+// between two matches, a byte, and finally an uint16.
+type lz77Encoder struct {
+	out       []byte
+	flagPos   int
+	flags     uint32
+	flagCount uint
+	nibblePos int
+}
+
+func newLZ77Encoder(out []byte) *lz77Encoder {
+	e := &lz77Encoder{
+		out:       out,
+		flagPos:   len(out),
+		nibblePos: noPosition,
+	}
+	e.out = append(e.out, 0, 0, 0, 0)
+	return e
+}
+
+func (e *lz77Encoder) nextFlags() {
+	binary.LittleEndian.PutUint32(e.out[e.flagPos:], e.flags)
+	e.flagPos = len(e.out)
+	e.out = append(e.out, 0, 0, 0, 0)
+	e.flags = 0
+	e.flagCount = 0
+}
+
+func (e *lz77Encoder) flag(bit uint32) {
+	if e.flagCount == 32 {
+		e.nextFlags()
+	}
+	e.flags = (e.flags << 1) | bit
+	e.flagCount++
+}
+
+func (e *lz77Encoder) putUint16(v uint16) {
+	e.out = append(e.out, byte(v), byte(v>>8))
+}
+
+func (e *lz77Encoder) literal(b byte) {
+	e.flag(0)
+	e.out = append(e.out, b)
+}
+
+// match encodes a match of length bytes at offset. The offset must
+// be in the range [1...lz77MaxOffset] and the length in the range
+// [lz77MinMatch...lz77MaxMatch].
+func (e *lz77Encoder) match(offset, length int) {
+	e.flag(1)
+
+	token := uint16(offset-1) << 3
+	l := length - lz77MinMatch
+	if l < 7 {
+		e.putUint16(token | uint16(l))
+		return
+	}
+	e.putUint16(token | 7)
+	l -= 7
+
+	nibble := l
+	if nibble > 15 {
+		nibble = 15
+	}
+	if e.nibblePos == noPosition {
+		e.nibblePos = len(e.out)
+		e.out = append(e.out, byte(nibble))
+	} else {
+		e.out[e.nibblePos] |= byte(nibble << 4)
+		e.nibblePos = noPosition
+	}
+	if l < 15 {
+		return
+	}
+	l -= 15
+	if l < 255 {
+		e.out = append(e.out, byte(l))
+		return
+	}
+	e.out = append(e.out, 255)
+	e.putUint16(uint16(length - lz77MinMatch))
+}
+
+// finish terminates the stream. The unused flag bits are set so that
+// the decoder sees a match flag at the end of the input.
+func (e *lz77Encoder) finish() []byte {
+	if e.flagCount == 32 {
+		e.nextFlags()
+	}
+	unused := 32 - e.flagCount
+	e.flags = (e.flags << unused) | ((1 << unused) - 1)
+	binary.LittleEndian.PutUint32(e.out[e.flagPos:], e.flags)
+	return e.out
+}
+
+// encode encodes data starting from pos until the position reaches
+// end. The matches can extend past end up to len(data) and their
+// offsets are limited to maxOffset. The function returns the new
+// position.
+func (e *lz77Encoder) encode(f MatchFinder, data []byte, pos, end,
+	maxOffset int) (int, error) {
+
+	for pos < end {
+		t, err := nextToken(f, data, pos, maxOffset, lz77MaxMatch)
+		if err != nil {
+			return pos, err
+		}
+		if t.length == 0 {
+			e.literal(t.literal)
+			pos++
+		} else {
+			e.match(t.offset, t.length)
+			pos += t.length
+		}
+	}
+	return pos, nil
+}
+
+// CompressLZ77 compresses data with the plain LZ77 algorithm.
+func CompressLZ77(data []byte) ([]byte, error) {
+	return new(Encoder).CompressLZ77(data)
+}
+
+// Compression levels of CompressLZ77Level.
+const (
+	// LevelFastest finds only the runs of zero bytes and emits the
+	// other data as literals.
+	LevelFastest = 0
+	// LevelDefault searches the hash chains as deep as
+	// CompressLZ77.
+	LevelDefault = 6
+	// LevelBest searches the longest hash chains.
+	LevelBest = 9
+)
+
+// levelChainLens are the hash chain lengths of the compression
+// levels.
+var levelChainLens = [LevelBest + 1]int{
+	0, 1, 2, 4, 8, 16, maxChainLen, 128, 256, 1024,
+}
+
+// CompressLZ77Level compresses data with the plain LZ77 algorithm
+// and the compression level level. The level is in the range
+// LevelFastest...LevelBest and the higher levels search longer hash
+// chains for longer matches. All levels produce valid LZ77 data;
+// the level changes only the compression ratio and speed.
+// LevelDefault compresses as CompressLZ77.
+func CompressLZ77Level(data []byte, level int) ([]byte, error) {
+	if level < LevelFastest || level > LevelBest {
+		return nil, fmt.Errorf("Invalid compression level %d", level)
+	}
+	enc := &Encoder{
+		MatchFinder: newMatcher(lz77MaxOffset, huffmanMaxMatch,
+			levelChainLens[level]),
+	}
+	return enc.CompressLZ77(data)
+}
+
+// CompressLZ77 compresses data with the plain LZ77 algorithm.
+func (enc *Encoder) CompressLZ77(data []byte) ([]byte, error) {
+	e := newLZ77Encoder(make([]byte, 0, len(data)+len(data)/8+8))
+	window := enc.window(lz77MaxOffset)
+	_, err := e.encode(enc.matchFinder(window), data, 0, len(data), window)
+	if err != nil {
+		return nil, err
+	}
+	out := e.finish()
+	if err := enc.verify(AlgorithmLZ77, out, data); err != nil {
+		return nil, err
+	}
+	return enc.checksum(out, data), nil
+}
+
+// The longest LZ77+Huffman match has the largest 16-bit length field
+// value 0xffff, encoding the match length minus huffmanMinMatch.
+const (
+	huffmanMinMatch = 3
+	huffmanMaxMatch = 0xffff + huffmanMinMatch
+)
+
+// lzToken is a literal or a match found by the matcher. The length is
+// 0 for literals.
+type lzToken struct {
+	literal byte
+	offset  int
+	length  int
+}
+
+// nextToken returns the token for data[pos:] using the match finder
+// f. The function checks that the match is valid and that it fits
+// into the maxOffset and maxLen limits. The matches shorter than the
+// minimum match length are returned as literals and the matches
+// longer than maxLen are truncated to maxLen.
+func nextToken(f MatchFinder, data []byte, pos, maxOffset, maxLen int) (
+	lzToken, error) {
+
+	offset, length := f.Find(data, pos)
+	if length < lz77MinMatch {
+		return lzToken{
+			literal: data[pos],
+		}, nil
+	}
+	if offset <= 0 || offset > pos || offset > maxOffset ||
+		length > len(data)-pos {
+		return lzToken{}, ErrInvalidMatch
+	}
+	if length > maxLen {
+		length = maxLen
+	}
+	for i := 0; i < length; i++ {
+		if data[pos+i] != data[pos+i-offset] {
+			return lzToken{}, ErrInvalidMatch
+		}
+	}
+	return lzToken{
+		offset: offset,
+		length: length,
+	}, nil
+}
+
+// findTokens finds the LZ77 tokens of data starting from pos until
+// the position reaches end. The function appends the tokens to
+// tokens and returns the resulting slice.
+func findTokens(f MatchFinder, data []byte, pos, end, maxOffset,
+	maxLen int, tokens []lzToken) ([]lzToken, error) {
+
+	for pos < end {
+		t, err := nextToken(f, data, pos, maxOffset, maxLen)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.length == 0 {
+			pos++
+		} else {
+			pos += t.length
+		}
+	}
+	return tokens, nil
+}
+
+// huffmanMatchSymbol returns the Huffman symbol for the match and
+// the number of offset bits.
+func huffmanMatchSymbol(offset, length int) (int, uint) {
+	var bits uint
+	for (offset >> (bits + 1)) != 0 {
+		bits++
+	}
+	l := length - huffmanMinMatch
+	if l > 15 {
+		l = 15
+	}
+	return 256 + int(bits)<<4 + l, bits
+}
+
+// CompressLZ77Huffman compresses data with the LZ77+Huffman
+// algorithm and appends the compressed data to out.
+func CompressLZ77Huffman(data []byte, out []byte) ([]byte, error) {
+	return new(Encoder).CompressLZ77Huffman(data, out)
+}
+
+// CompressLZ77Huffman compresses data with the LZ77+Huffman
+// algorithm and appends the compressed data to out.
+func (enc *Encoder) CompressLZ77Huffman(data []byte, out []byte) (
+	[]byte, error) {
+
+	tokens, err := enc.huffmanTokens(data)
+	if err != nil {
+		return nil, err
+	}
+	result := enc.huffmanEncode(tokens, out, nil).flush()
+	err = enc.verify(AlgorithmLZ77Huffman, result[len(out):], data)
+	if err != nil {
+		return nil, err
+	}
+	return enc.checksum(result, data), nil
+}
+
+// CompressLZ77HuffmanTo compresses data with the LZ77+Huffman
+// algorithm and writes the compressed data to w. The function
+// returns the number of bytes written to w.
+func CompressLZ77HuffmanTo(w io.Writer, data []byte) (int, error) {
+	return new(Encoder).CompressLZ77HuffmanTo(w, data)
+}
+
+// CompressLZ77HuffmanTo compresses data with the LZ77+Huffman
+// algorithm and writes the compressed data to w. The tokens are found
+// and encoded one block at a time and the compressed data is written
+// in blocks as it is encoded. If the encoder verifies its output, the
+// compressed data is buffered and written after the verification.
+// The function returns the number of bytes written to w.
+func (enc *Encoder) CompressLZ77HuffmanTo(w io.Writer, data []byte) (
+	int, error) {
+
+	if enc.Verify {
+		compressed, err := enc.CompressLZ77Huffman(data, nil)
+		if err != nil {
+			return 0, err
+		}
+		return w.Write(compressed)
+	}
+	var bw *bitWriter
+	err := enc.huffmanTokenBlocks(data, func(block huffmanBlock) error {
+		bw = enc.huffmanEncodeBlock(bw, block, nil, w)
+		return bw.err
+	})
+	if err != nil {
+		if bw == nil {
+			return 0, err
+		}
+		return bw.written, err
+	}
+	bw.flush()
+	if bw.err == nil && enc.AppendChecksum {
+		var n int
+		n, bw.err = w.Write(appendChecksum(nil, data))
+		bw.written += n
+	}
+	return bw.written, bw.err
+}
+
+// huffmanTokens returns the LZ77+Huffman tokens for data.
+func (enc *Encoder) huffmanTokens(data []byte) ([]lzToken, error) {
+	window := enc.window(MatchWindowSize)
+	tokens, err := findTokens(enc.matchFinder(window), data, 0, len(data),
+		window, huffmanMaxMatch, nil)
+	if err != nil {
+		return nil, err
+	}
+	tokens = dropTerminatorMatches(data, tokens)
+	if len(data) < smallInputSize {
+		// The short matches of small inputs cost more than the
+		// literals they replace. The literals-only stream is used if
+		// it is smaller than the stream with matches.
+		tokens = dropShortMatches(data, tokens)
+		literals := literalTokens(data)
+		if enc.huffmanSize(literals) < enc.huffmanSize(tokens) {
+			tokens = literals
+		}
+	}
+	return tokens, nil
+}
+
+// huffmanTokenBlocks finds the LZ77+Huffman tokens for data one block
+// at a time and calls fn for each block. The blocks are the blocks of
+// huffmanBlocks for the tokens of huffmanTokens but only the tokens
+// of the current block are kept in memory. The small inputs are a
+// single block and their tokens are found with huffmanTokens. The
+// function stops at the first error of fn.
+func (enc *Encoder) huffmanTokenBlocks(data []byte,
+	fn func(block huffmanBlock) error) error {
+
+	if len(data) < smallInputSize {
+		tokens, err := enc.huffmanTokens(data)
+		if err != nil {
+			return err
+		}
+		for _, block := range huffmanBlocks(tokens) {
+			if err := fn(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	window := enc.window(MatchWindowSize)
+	f := enc.matchFinder(window)
+	var tokens []lzToken
+	var pos int
+	for {
+		// A block ends after the token that reaches
+		// huffmanBlockSize output bytes from the start of the block.
+		blockEnd := pos + huffmanBlockSize
+		tokens = tokens[:0]
+		for pos < blockEnd && pos < len(data) {
+			t, err := nextToken(f, data, pos, window, huffmanMaxMatch)
+			if err != nil {
+				return err
+			}
+			switch {
+			case t.length == 0:
+				tokens = append(tokens, t)
+				pos++
+			case t.offset == 1 && t.length == huffmanMinMatch:
+				// The match is replaced with literals, see
+				// dropTerminatorMatches.
+				for _, b := range data[pos : pos+t.length] {
+					tokens = append(tokens, lzToken{
+						literal: b,
+					})
+				}
+				pos += t.length
+			default:
+				tokens = append(tokens, t)
+				pos += t.length
+			}
+		}
+		full := pos >= blockEnd
+		err := fn(huffmanBlock{
+			tokens: tokens,
+			eof:    !full,
+		})
+		if err != nil || !full || pos == len(data) {
+			return err
+		}
+	}
+}
+
+// The LZ77+Huffman compressor prefers literals over the matches
+// shorter than smallInputMinMatch for inputs smaller than
+// smallInputSize bytes.
+const (
+	smallInputSize     = 1024
+	smallInputMinMatch = 4
+)
+
+// dropShortMatches replaces the matches shorter than
+// smallInputMinMatch with literals.
+func dropShortMatches(data []byte, tokens []lzToken) []lzToken {
+	return dropMatches(data, tokens, func(t lzToken) bool {
+		return t.length < smallInputMinMatch
+	})
+}
+
+// dropTerminatorMatches replaces the matches with offset 1 and length
+// 3 with literals. The match symbol of these matches is the
+// end-of-stream marker 256 and the decoder ends the stream if it
+// decodes the symbol at the end of input.
+func dropTerminatorMatches(data []byte, tokens []lzToken) []lzToken {
+	return dropMatches(data, tokens, func(t lzToken) bool {
+		return t.offset == 1 && t.length == huffmanMinMatch
+	})
+}
+
+// dropMatches replaces the matches for which drop returns true with
+// literals.
+func dropMatches(data []byte, tokens []lzToken,
+	drop func(t lzToken) bool) []lzToken {
+
+	var result []lzToken
+	var pos int
+	for _, t := range tokens {
+		if t.length == 0 {
+			result = append(result, t)
+			pos++
+			continue
+		}
+		if drop(t) {
+			result = append(result, literalTokens(data[pos:pos+t.length])...)
+		} else {
+			result = append(result, t)
+		}
+		pos += t.length
+	}
+	return result
+}
+
+// literalTokens returns the literal tokens for data.
+func literalTokens(data []byte) []lzToken {
+	tokens := make([]lzToken, len(data))
+	for i, b := range data {
+		tokens[i].literal = b
+	}
+	return tokens
+}
+
+// huffmanEncode encodes the tokens with the LZ77+Huffman algorithm
+// and appends the encoded data to out. Each block of
+// huffmanBlockSize output bytes is encoded with its own Huffman
+// table. If sink is not nil, the completed output is written to sink
+// as it is encoded. The caller flushes the returned bitWriter to
+// complete the stream.
+func (enc *Encoder) huffmanEncode(tokens []lzToken, out []byte,
+	sink io.Writer) *bitWriter {
+
+	var w *bitWriter
+	for _, block := range huffmanBlocks(tokens) {
+		w = enc.huffmanEncodeBlock(w, block, out, sink)
+	}
+	return w
+}
+
+// huffmanEncodeBlock encodes the block with its own Huffman table.
+// The first pass over the tokens computes the symbol frequencies for
+// the table and the second pass writes the codes. If w is nil, the
+// function creates a new bitWriter that appends the stream to out and
+// writes the completed output to sink.
+func (enc *Encoder) huffmanEncodeBlock(w *bitWriter, block huffmanBlock,
+	out []byte, sink io.Writer) *bitWriter {
+
+	lengths := enc.huffmanTokenLengths(block.tokens, block.eof)
+	symLen := packSymbolLength(&lengths)
+	codes := huffmanCodes(symLen)
+
+	if w == nil {
+		w = newBitWriter(append(out, symLen...))
+		w.sink = sink
+	} else {
+		w.block(symLen)
+	}
+	for _, t := range block.tokens {
+		if t.length == 0 {
+			w.writeBits(uint32(codes[t.literal]), uint(lengths[t.literal]))
+			continue
+		}
+		sym, bits := huffmanMatchSymbol(t.offset, t.length)
+		w.writeBits(uint32(codes[sym]), uint(lengths[sym]))
+
+		l := t.length - huffmanMinMatch
+		if l >= 15 {
+			if l-15 < 255 {
+				w.writeByte(byte(l - 15))
+			} else {
+				w.writeByte(255)
+				w.writeUint16(uint16(l))
+			}
+		}
+		w.writeBits(uint32(t.offset-(1<<bits)), bits)
+	}
+	if block.eof {
+		w.writeBits(uint32(codes[huffmanEOF]), uint(lengths[huffmanEOF]))
+	}
+	return w
+}
+
+// huffmanBlock is a block of LZ77+Huffman tokens. The eof tells if
+// the block ends with the end-of-stream marker.
+type huffmanBlock struct {
+	tokens []lzToken
+	eof    bool
+}
+
+// huffmanBlocks splits the tokens into the blocks of the decoder. A
+// block ends after the token that reaches huffmanBlockSize output
+// bytes from the start of the block. The last block ends with the
+// end-of-stream marker unless it is a full block at the end of the
+// stream.
+func huffmanBlocks(tokens []lzToken) []huffmanBlock {
+	var blocks []huffmanBlock
+	var start, produced int
+	blockEnd := huffmanBlockSize
+	for i, t := range tokens {
+		if t.length == 0 {
+			produced++
+		} else {
+			produced += t.length
+		}
+		if produced >= blockEnd {
+			blocks = append(blocks, huffmanBlock{
+				tokens: tokens[start : i+1],
+			})
+			start = i + 1
+			blockEnd = produced + huffmanBlockSize
+		}
+	}
+	if start < len(tokens) || len(blocks) == 0 {
+		blocks = append(blocks, huffmanBlock{
+			tokens: tokens[start:],
+			eof:    true,
+		})
+	}
+	return blocks
+}
+
+// huffmanTokenLengths computes the Huffman code lengths for the
+// tokens and, if eof is true, the end-of-stream marker.
+func (enc *Encoder) huffmanTokenLengths(tokens []lzToken, eof bool) (
+	lengths [huffmanSymbols]uint8) {
+
+	var freq [huffmanSymbols]uint64
+	for _, t := range tokens {
+		if t.length == 0 {
+			freq[t.literal]++
+		} else {
+			sym, _ := huffmanMatchSymbol(t.offset, t.length)
+			freq[sym]++
+		}
+	}
+	if eof {
+		freq[huffmanEOF]++
+	}
+
+	copy(lengths[:], enc.huffmanLengths(freq[:]))
+	return
+}
+
+// huffmanSize computes the size of the output of huffmanEncode for
+// the tokens.
+func (enc *Encoder) huffmanSize(tokens []lzToken) int {
+	var size int
+	for _, block := range huffmanBlocks(tokens) {
+		size += enc.huffmanEncodedSize(block)
+	}
+	return size
+}
+
+// huffmanEncodedSize computes the encoded size of the block.
+func (enc *Encoder) huffmanEncodedSize(block huffmanBlock) int {
+	lengths := enc.huffmanTokenLengths(block.tokens, block.eof)
+
+	var bits, raw int
+	for _, t := range block.tokens {
+		if t.length == 0 {
+			bits += int(lengths[t.literal])
+			continue
+		}
+		sym, offsetBits := huffmanMatchSymbol(t.offset, t.length)
+		bits += int(lengths[sym]) + int(offsetBits)
+
+		l := t.length - huffmanMinMatch
+		if l >= 15 {
+			if l-15 < 255 {
+				raw++
+			} else {
+				raw += 3
+			}
+		}
+	}
+	if block.eof {
+		bits += int(lengths[huffmanEOF])
+	}
+
+	// The bitWriter starts with two words and adds a word each time
+	// the pending bits exceed 16 bits.
+	words := 2 + (bits-1)/16
+	return huffmanSymbols/2 + 2*words + raw
+}
+
+// lz77Size computes the size of the plain LZ77 stream for the
+// tokens.
+func lz77Size(tokens []lzToken) int {
+	size := 4 * (len(tokens)/32 + 1)
+	var nibble bool
+	for _, t := range tokens {
+		if t.length == 0 {
+			size++
+			continue
+		}
+		size += 2
+		l := t.length - lz77MinMatch
+		if l < 7 {
+			continue
+		}
+		l -= 7
+		if !nibble {
+			size++
+		}
+		nibble = !nibble
+		if l < 15 {
+			continue
+		}
+		l -= 15
+		if l < 255 {
+			size++
+		} else {
+			size += 3
+		}
+	}
+	return size
+}
+
+// CompressedSize returns the size of the compressed data that the
+// compressor of the algorithm algo produces for data. The function
+// finds the matches and computes the Huffman codes but it does not
+// create the compressed output.
+func CompressedSize(data []byte, algo Algorithm) (int, error) {
+	return new(Encoder).CompressedSize(data, algo)
+}
+
+// CompressedSize returns the size of the compressed data that the
+// encoder's compressor of the algorithm algo produces for data.
+func (enc *Encoder) CompressedSize(data []byte, algo Algorithm) (
+	int, error) {
+
+	switch algo {
+	case AlgorithmLZ77:
+		window := enc.window(lz77MaxOffset)
+		tokens, err := findTokens(enc.matchFinder(window), data, 0,
+			len(data), window, lz77MaxMatch, nil)
+		if err != nil {
+			return 0, err
+		}
+		return lz77Size(tokens) + enc.checksumSize(), nil
+
+	case AlgorithmLZ77Huffman:
+		tokens, err := enc.huffmanTokens(data)
+		if err != nil {
+			return 0, err
+		}
+		return enc.huffmanSize(tokens) + enc.checksumSize(), nil
+
+	case AlgorithmLZNT1:
+		// The LZNT1 compressor does not use the encoder parameters.
+		out, err := CompressLZNT1(data)
+		if err != nil {
+			return 0, err
+		}
+		return len(out), nil
+
+	default:
+		return 0, fmt.Errorf("Unsupported algorithm %s", algo)
+	}
+}
+
+// Canonicalize decompresses the data that is compressed with the
+// algorithm algo and compresses it again with the default encoder.
+// The default encoder is deterministic so the data that decompress
+// to the same plaintext have identical canonical forms.
+func Canonicalize(data []byte, algo Algorithm) ([]byte, error) {
+	plain, err := DecompressWithOptions(algo, data, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return compress(algo, plain)
+}
+
+// errUnsupportedAlgorithm is returned if the algorithm does not have
+// a compressor.
+var errUnsupportedAlgorithm = errors.New("Unsupported algorithm")
+
+// compress compresses data with the default encoder of the algorithm
+// algo.
+func compress(algo Algorithm, data []byte) ([]byte, error) {
+	switch algo {
+	case AlgorithmLZ77:
+		return CompressLZ77(data)
+
+	case AlgorithmLZ77Huffman:
+		return CompressLZ77Huffman(data, nil)
+
+	case AlgorithmLZNT1:
+		return CompressLZNT1(data)
+
+	default:
+		return nil, fmt.Errorf("%w %s", errUnsupportedAlgorithm, algo)
+	}
+}
+
+// Verify checks that the compressed data in the format decompresses
+// and that its decompressed data survives a round trip. The data must
+// decode to its end: the truncated streams fail. If the format has a
+// compressor, the function compresses the decompressed data and
+// checks that it decompresses to the same data. The error describes
+// the step that failed.
+func Verify(format Format, compressed []byte) error {
+	plain, err := Decompress(format, compressed, nil)
+	if err != nil {
+		return fmt.Errorf("%s: decompress: %w", format, err)
+	}
+	recompressed, err := compress(format, plain)
+	if errors.Is(err, errUnsupportedAlgorithm) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: recompress: %w", format, err)
+	}
+	out, err := Decompress(format, recompressed, nil)
+	if err != nil {
+		return fmt.Errorf("%s: decompress recompressed data: %w", format, err)
+	}
+	if len(out) != len(plain) {
+		return fmt.Errorf("%s: recompressed data decompresses to %d bytes, expected %d",
+			format, len(out), len(plain))
+	}
+	if !bytes.Equal(out, plain) {
+		return fmt.Errorf("%s: recompressed data decompresses to different data",
+			format)
+	}
+	return nil
+}
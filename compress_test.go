@@ -0,0 +1,678 @@
+//
+// compress_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	rnd := rand.New(rand.NewSource(seed))
+	data := make([]byte, n)
+	rnd.Read(data)
+	return data
+}
+
+func repeatedMatch(length int) []byte {
+	prefix := randomBytes(int64(length), 300)
+	data := append([]byte{}, prefix...)
+	for len(data) < len(prefix)+length {
+		data = append(data, prefix...)
+	}
+	return data[:len(prefix)+length]
+}
+
+var lz77MatchLengths = []int{
+	3, 9, 10, 24, 25, 270, 278, 65535, 65800,
+}
+
+func TestLZ77MatchLengthLadder(t *testing.T) {
+	for _, length := range lz77MatchLengths {
+		e := newLZ77Encoder(nil)
+		e.literal('a')
+		for l := length; l > 0; {
+			n := l
+			if n > lz77MaxMatch {
+				n = lz77MaxMatch
+				if l-n < lz77MinMatch {
+					n = l - lz77MinMatch
+				}
+			}
+			e.match(1, n)
+			l -= n
+		}
+		out, err := DecompressLZ77(e.finish())
+		if err != nil {
+			t.Fatalf("length %d: decompress failed: %s", length, err)
+		}
+		expected := bytes.Repeat([]byte{'a'}, length+1)
+		if !bytes.Equal(out, expected) {
+			t.Errorf("length %d: got %d bytes, expected %d",
+				length, len(out), len(expected))
+		}
+	}
+}
+
+func TestLZ77MatchLengthNibbles(t *testing.T) {
+	// Consecutive long matches share the length nibble byte.
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	e.match(1, 12)
+	e.match(1, 20)
+	e.match(1, 30)
+	e.literal('b')
+	e.match(1, 11)
+
+	out, err := DecompressLZ77(e.finish())
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	var expected []byte
+	expected = append(expected, bytes.Repeat([]byte{'a'}, 63)...)
+	expected = append(expected, bytes.Repeat([]byte{'b'}, 12)...)
+	if !bytes.Equal(out, expected) {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+}
+
+func TestCompressLZ77(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("abcabc"),
+		[]byte("abcdefghijklmnopqrstuvwxyz0123456789abcdefghijklmnopqrstuvwxyz"),
+		randomBytes(1, 100000),
+	}
+	for _, length := range lz77MatchLengths {
+		inputs = append(inputs, repeatedMatch(length))
+	}
+	for _, data := range inputs {
+		compressed, err := CompressLZ77(data)
+		if err != nil {
+			t.Fatalf("CompressLZ77 failed: %s", err)
+		}
+		out, err := DecompressLZ77(compressed)
+		if err != nil {
+			t.Fatalf("DecompressLZ77 failed: %s", err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("round trip failed for %d bytes", len(data))
+		}
+	}
+}
+
+func TestCompressLZ77Level(t *testing.T) {
+	text := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "),
+		500)
+	var data []byte
+	data = append(data, text...)
+	data = append(data, randomBytes(2, 20000)...)
+	data = append(data, make([]byte, 5000)...)
+	data = append(data, text[:3000]...)
+
+	sizes := make(map[int]int)
+	for level := LevelFastest; level <= LevelBest; level++ {
+		compressed, err := CompressLZ77Level(data, level)
+		if err != nil {
+			t.Fatalf("level %d: compress failed: %s", level, err)
+		}
+		out, err := DecompressLZ77(compressed)
+		if err != nil {
+			t.Fatalf("level %d: decompress failed: %s", level, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("level %d: round trip failed", level)
+		}
+		sizes[level] = len(compressed)
+	}
+	if sizes[LevelBest] >= sizes[LevelFastest] {
+		t.Errorf("best level %d bytes, fastest level %d bytes",
+			sizes[LevelBest], sizes[LevelFastest])
+	}
+	compressed, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) != sizes[LevelDefault] {
+		t.Errorf("default level %d bytes, CompressLZ77 %d bytes",
+			sizes[LevelDefault], len(compressed))
+	}
+	for _, level := range []int{-1, LevelBest + 1} {
+		if _, err := CompressLZ77Level(data, level); err == nil {
+			t.Errorf("level %d accepted", level)
+		}
+	}
+}
+
+func TestCompressLZ77Huffman(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("abcabc"),
+		[]byte("abcdefghijklmnopqrstuvwxyz0123456789abcdefghijklmnopqrstuvwxyz"),
+		randomBytes(1, 60000),
+		bytes.Repeat([]byte{0}, 60000),
+	}
+	for _, length := range lz77MatchLengths {
+		inputs = append(inputs, repeatedMatch(length))
+	}
+	for _, data := range inputs {
+		compressed, err := CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatalf("CompressLZ77Huffman failed: %s", err)
+		}
+		out, err := DecompressLZ77Huffman(compressed, nil)
+		if err != nil {
+			t.Fatalf("DecompressLZ77Huffman failed for %d bytes: %s",
+				len(data), err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("round trip failed for %d bytes", len(data))
+		}
+	}
+}
+
+func TestCompressLZ77HuffmanSmall(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("abcabdabeabf"),
+		[]byte("HKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Windows"),
+		[]byte("name=value\nkey=value\nother=thing\n"),
+		bytes.Repeat([]byte("xyz"), 100),
+		randomBytes(11, 500),
+	}
+	literals := &Encoder{
+		MatchFinder: literalFinder{},
+	}
+	for _, data := range inputs {
+		compressed, err := CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatalf("CompressLZ77Huffman failed: %s", err)
+		}
+		stored, err := literals.CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatalf("CompressLZ77Huffman failed: %s", err)
+		}
+		if len(compressed) > len(stored) {
+			t.Errorf("%q: compressed %d bytes, literals %d bytes",
+				data, len(compressed), len(stored))
+		}
+		out, err := DecompressLZ77Huffman(compressed, nil)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%q: round trip failed: %v", data, err)
+		}
+	}
+}
+
+func TestCompressedSize(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("abcabdabeabf"),
+		bytes.Repeat([]byte("xyz"), 100),
+		randomBytes(12, 500),
+		randomBytes(13, 50000),
+		bytes.Repeat([]byte{0}, 100000),
+	}
+	for _, length := range lz77MatchLengths {
+		inputs = append(inputs, repeatedMatch(length))
+	}
+	compressors := map[Algorithm]func([]byte) ([]byte, error){
+		AlgorithmLZ77: CompressLZ77,
+		AlgorithmLZ77Huffman: func(data []byte) ([]byte, error) {
+			return CompressLZ77Huffman(data, nil)
+		},
+		AlgorithmLZNT1: CompressLZNT1,
+	}
+	for algo, compress := range compressors {
+		for _, data := range inputs {
+			compressed, err := compress(data)
+			if err != nil {
+				t.Fatalf("%s: compress failed: %s", algo, err)
+			}
+			size, err := CompressedSize(data, algo)
+			if err != nil {
+				t.Fatalf("%s: CompressedSize failed: %s", algo, err)
+			}
+			if size != len(compressed) {
+				t.Errorf("%s: %d bytes: size %d, expected %d",
+					algo, len(data), size, len(compressed))
+			}
+		}
+	}
+
+	// The size includes the checksum trailer.
+	enc := &Encoder{
+		AppendChecksum: true,
+	}
+	checksummed := map[Algorithm]func([]byte) ([]byte, error){
+		AlgorithmLZ77: enc.CompressLZ77,
+		AlgorithmLZ77Huffman: func(data []byte) ([]byte, error) {
+			return enc.CompressLZ77Huffman(data, nil)
+		},
+	}
+	for algo, compress := range checksummed {
+		for _, data := range inputs {
+			compressed, err := compress(data)
+			if err != nil {
+				t.Fatalf("%s: compress failed: %s", algo, err)
+			}
+			size, err := enc.CompressedSize(data, algo)
+			if err != nil {
+				t.Fatalf("%s: CompressedSize failed: %s", algo, err)
+			}
+			if size != len(compressed) {
+				t.Errorf("%s: checksum: %d bytes: size %d, expected %d",
+					algo, len(data), size, len(compressed))
+			}
+		}
+	}
+}
+
+func TestCompressLZ77HuffmanTo(t *testing.T) {
+	var data []byte
+	for i := 0; i < 4; i++ {
+		data = append(data, randomBytes(int64(30+i), 40000)...)
+		data = append(data, repeatedMatch(30000+i)...)
+	}
+	expected, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := new(segmentWriter)
+	n, err := CompressLZ77HuffmanTo(w, data)
+	if err != nil {
+		t.Fatalf("CompressLZ77HuffmanTo failed: %s", err)
+	}
+	compressed := bytes.Join(w.segments, nil)
+	if n != len(compressed) || !bytes.Equal(compressed, expected) {
+		t.Fatalf("CompressLZ77HuffmanTo wrote %d bytes, expected %d",
+			n, len(expected))
+	}
+	if len(w.segments) < 2 {
+		t.Errorf("compressed data written in %d blocks", len(w.segments))
+	}
+
+	var out bytes.Buffer
+	m, err := DecompressLZ77HuffmanTo(&out, compressed)
+	if err != nil {
+		t.Fatalf("DecompressLZ77HuffmanTo failed: %s", err)
+	}
+	if m != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("round trip failed")
+	}
+
+	// The blocks end at the end of input and the matches cross the
+	// block boundaries.
+	for _, input := range [][]byte{
+		randomBytes(34, huffmanBlockSize),
+		randomBytes(35, 3*huffmanBlockSize),
+		bytes.Repeat([]byte{0}, 3*huffmanBlockSize+1),
+		append(randomBytes(36, huffmanBlockSize-10), repeatedMatch(1000)...),
+		[]byte(strings.Repeat("abcabdabe", 20000)),
+	} {
+		expected, err := CompressLZ77Huffman(input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var streamed bytes.Buffer
+		_, err = CompressLZ77HuffmanTo(&streamed, input)
+		if err != nil || !bytes.Equal(streamed.Bytes(), expected) {
+			t.Errorf("%d bytes: CompressLZ77HuffmanTo output differs: %v",
+				len(input), err)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := &Encoder{
+		Verify: true,
+	}
+	n, err = enc.CompressLZ77HuffmanTo(&buf, data[:1000])
+	if err != nil || n != buf.Len() {
+		t.Fatalf("CompressLZ77HuffmanTo failed: %v", err)
+	}
+	out.Reset()
+	_, err = DecompressLZ77HuffmanTo(&out, buf.Bytes())
+	if err != nil || !bytes.Equal(out.Bytes(), data[:1000]) {
+		t.Errorf("round trip failed: %v", err)
+	}
+}
+
+// shortWriter accepts the first n bytes and fails the write that
+// exceeds them.
+type shortWriter struct {
+	n       int
+	written bytes.Buffer
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		w.written.Write(p[:w.n])
+		n := w.n
+		w.n = 0
+		return n, errWriteFailed
+	}
+	w.n -= len(p)
+	w.written.Write(p)
+	return len(p), nil
+}
+
+func TestCompressLZ77HuffmanToShortWrite(t *testing.T) {
+	data := randomBytes(38, 5*huffmanBlockSize)
+	for _, limit := range []int{0, 1000, 100000} {
+		w := &shortWriter{
+			n: limit,
+		}
+		n, err := CompressLZ77HuffmanTo(w, data)
+		if err != errWriteFailed {
+			t.Errorf("limit %d: got %v, expected %v", limit, err,
+				errWriteFailed)
+		}
+		if n != limit || n != w.written.Len() {
+			t.Errorf("limit %d: returned %d, writer accepted %d bytes",
+				limit, n, w.written.Len())
+		}
+	}
+}
+
+func TestCompressLZ77HuffmanToAllocs(t *testing.T) {
+	data := randomBytes(37, 4<<20)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if _, err := CompressLZ77HuffmanTo(io.Discard, data); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	// The tokens of the whole input would take 24 bytes per literal.
+	// The streaming compressor keeps the tokens of one block.
+	allocated := after.TotalAlloc - before.TotalAlloc
+	if allocated > uint64(8*len(data)) {
+		t.Errorf("allocated %d bytes for %d bytes of input",
+			allocated, len(data))
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	data := append(repeatedMatch(3000), randomBytes(22, 2000)...)
+	data = append(data, bytes.Repeat([]byte("abcabd"), 500)...)
+
+	encoders := []*Encoder{
+		{},
+		{NoMatches: true},
+		{Effort: EffortBest},
+		{MaxOffset: 100},
+	}
+	for _, algo := range []Algorithm{AlgorithmLZ77, AlgorithmLZ77Huffman} {
+		var canonical []byte
+		for i, enc := range encoders {
+			var compressed []byte
+			var err error
+			if algo == AlgorithmLZ77 {
+				compressed, err = enc.CompressLZ77(data)
+			} else {
+				compressed, err = enc.CompressLZ77Huffman(data, nil)
+			}
+			if err != nil {
+				t.Fatalf("%s: compress failed: %s", algo, err)
+			}
+			c, err := Canonicalize(compressed, algo)
+			if err != nil {
+				t.Fatalf("%s: Canonicalize failed: %s", algo, err)
+			}
+			if i == 0 {
+				canonical = c
+				if !bytes.Equal(c, compressed) {
+					t.Errorf("%s: canonical form differs from default", algo)
+				}
+			} else if !bytes.Equal(c, canonical) {
+				t.Errorf("%s: encoder %d: canonical forms differ", algo, i)
+			}
+		}
+	}
+
+	// The uncompressed LZNT1 chunks have the canonical form of the
+	// default compressor.
+	var chunks []string
+	for i := 0; i < len(data); i += lznt1ChunkSize {
+		chunks = append(chunks, string(data[i:min(len(data), i+lznt1ChunkSize)]))
+	}
+	lznt1 := lznt1Uncompressed(chunks...)
+	c, err := Canonicalize(lznt1, AlgorithmLZNT1)
+	if err != nil {
+		t.Fatalf("%s: Canonicalize failed: %s", AlgorithmLZNT1, err)
+	}
+	expected, err := CompressLZNT1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c, expected) || bytes.Equal(c, lznt1) {
+		t.Errorf("%s: canonical form differs from default", AlgorithmLZNT1)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := append(repeatedMatch(3000), randomBytes(23, 2000)...)
+	lz77, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format     Format
+		compressed []byte
+	}{
+		{FormatLZ77, lz77},
+		{FormatLZ77Huffman, huffman},
+		{FormatLZNT1, lznt1Inputs[0]},
+	}
+	for _, test := range tests {
+		if err := Verify(test.format, test.compressed); err != nil {
+			t.Errorf("%s: Verify failed: %s", test.format, err)
+		}
+		truncated := test.compressed[:len(test.compressed)-1]
+		if err := Verify(test.format, truncated); !truncation(err) {
+			t.Errorf("%s: truncated data: got %v, expected %v",
+				test.format, err, TruncatedInput)
+		}
+	}
+	if err := Verify(Format(42), lz77); err == nil {
+		t.Errorf("unknown format verified")
+	}
+}
+
+func TestCompressZeroRuns(t *testing.T) {
+	zeros := make([]byte, 4<<20)
+	for algo, compress := range map[Algorithm]func([]byte) ([]byte, error){
+		AlgorithmLZ77: CompressLZ77,
+		AlgorithmLZ77Huffman: func(data []byte) ([]byte, error) {
+			return CompressLZ77Huffman(data, nil)
+		},
+	} {
+		compressed, err := compress(zeros)
+		if err != nil {
+			t.Fatalf("%s: compress failed: %s", algo, err)
+		}
+		// Each LZ77+Huffman block has a 256-byte Huffman table.
+		limit := len(zeros) / 5000
+		if algo == AlgorithmLZ77Huffman {
+			limit += len(zeros) / huffmanBlockSize * 256
+		}
+		if len(compressed) > limit {
+			t.Errorf("%s: %d zeros compressed to %d bytes",
+				algo, len(zeros), len(compressed))
+		}
+		tokens, err := Tokenize(compressed, algo)
+		if err != nil {
+			t.Fatalf("%s: Tokenize failed: %s", algo, err)
+		}
+		for _, token := range tokens[1:] {
+			if token.Offset != 1 {
+				t.Fatalf("%s: unexpected token %+v", algo, token)
+			}
+		}
+		out, err := DecompressWithOptions(algo, compressed, nil, nil)
+		if err != nil || !bytes.Equal(out, zeros) {
+			t.Errorf("%s: round trip failed: %v", algo, err)
+		}
+	}
+
+	// The zero runs after the first zero are single offset 1
+	// matches.
+	data := append(randomBytes(23, 1000), make([]byte, 5000)...)
+	data = append(data, randomBytes(24, 1000)...)
+	data = append(data, make([]byte, 7000)...)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := Tokenize(compressed, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []int
+	for _, token := range tokens {
+		if token.Offset == 1 {
+			runs = append(runs, token.Length)
+		}
+	}
+	if len(runs) != 2 || runs[0] != 4999 || runs[1] < 6900 {
+		t.Errorf("unexpected zero run matches %v", runs)
+	}
+}
+
+func TestCompressLZ77HuffmanEscapes(t *testing.T) {
+	enc := new(Encoder)
+	encode := func(prefix []byte, offset, length int) ([]byte, []byte) {
+		tokens := literalTokens(prefix)
+		tokens = append(tokens, lzToken{
+			offset: offset,
+			length: length,
+		})
+		data := append([]byte{}, prefix...)
+		for i := 0; i < length; i++ {
+			data = append(data, data[len(data)-offset])
+		}
+		return enc.huffmanEncode(tokens, nil, nil).flush(), data
+	}
+
+	// The match lengths around the escape ladder steps: the 4-bit
+	// length 15, the length byte 255, and the 16-bit length. The
+	// offsets of each offset bit class, up to the full window.
+	lengths := []int{3, 17, 18, 19, 272, 273, 274, 1000, huffmanMaxMatch}
+	offsets := []int{1, 2, 3, 4, 7, 8, 255, 256, 4097, 16383, 16384,
+		32767, MatchWindowSize}
+	for _, offset := range offsets {
+		prefix := randomBytes(int64(offset), offset)
+		for _, length := range lengths {
+			if offset == 1 && length == huffmanMinMatch {
+				// The match symbol is the end-of-stream marker.
+				continue
+			}
+			compressed, data := encode(prefix, offset, length)
+			tokens, err := Tokenize(compressed, AlgorithmLZ77Huffman)
+			if err != nil {
+				t.Fatalf("offset %d, length %d: Tokenize failed: %s",
+					offset, length, err)
+			}
+			last := tokens[len(tokens)-1]
+			if len(tokens) != offset+1 || last.Offset != offset ||
+				last.Length != length {
+				t.Errorf("offset %d, length %d: got %d tokens, last %v",
+					offset, length, len(tokens), last)
+			}
+			out, err := DecompressLZ77Huffman(compressed, nil)
+			if err != nil || !bytes.Equal(out, data) {
+				t.Errorf("offset %d, length %d: round trip failed: %v",
+					offset, length, err)
+			}
+		}
+	}
+
+	// The compressor encodes the matches with offset 1 and length 3
+	// as literals so that the decoder does not take them for the
+	// end-of-stream marker at the end of input.
+	for _, suffix := range []string{"aaaa", "aaaab", "aaaaaaa"} {
+		data := append(randomBytes(26, 2000), suffix...)
+		compressed, err := CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := DecompressLZ77Huffman(compressed, nil)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("suffix %q: round trip failed: %v", suffix, err)
+		}
+	}
+}
+
+func TestCompressLZ77Boundaries(t *testing.T) {
+	// The streams that end at and around the flag word boundaries.
+	for n := 0; n <= 70; n++ {
+		data := randomBytes(int64(n), n)
+		compressed, err := CompressLZ77(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := DecompressLZ77(compressed)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%d literals: round trip failed: %v", n, err)
+		}
+	}
+
+	// The streams that end with an unpaired length nibble.
+	for matches := 1; matches <= 4; matches++ {
+		var data []byte
+		for i := 0; i < matches; i++ {
+			data = append(data, randomBytes(int64(i), 20)...)
+			data = append(data, data[len(data)-20:len(data)-8]...)
+		}
+		compressed, err := CompressLZ77(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := DecompressLZ77(compressed)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%d matches: round trip failed: %v", matches, err)
+		}
+	}
+
+	// Several megabytes of mixed data.
+	var data []byte
+	for i := 0; len(data) < 6<<20; i++ {
+		data = append(data, randomBytes(int64(i), 1000+i%5000)...)
+		data = append(data, repeatedMatch(i%300+3)...)
+		data = append(data, make([]byte, i%70000)...)
+	}
+	compressed, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecompressLZ77(compressed)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("%d bytes: round trip failed: %v", len(data), err)
+	}
+	tokens, err := Tokenize(compressed, AlgorithmLZ77)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.Length != 0 && token.Length < lz77MinMatch {
+			t.Fatalf("match %+v shorter than minimum", token)
+		}
+	}
+}
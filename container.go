@@ -0,0 +1,98 @@
+//
+// container.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// ErrNoContainer is returned if the data does not start with a known
+// container header.
+var ErrNoContainer = errors.New("No container header")
+
+// MAM container header. The fourth byte holds the compression format
+// in the low nibble and the checksum flag in the high bit. The header
+// is followed by the uncompressed size as a little-endian uint32
+// and, if the checksum flag is set, by a CRC-32 checksum.
+var mamMagic = []byte("MAM")
+
+const (
+	mamChecksum = 0x80
+	mamFormat   = 0x0f
+)
+
+// The compression format values of the Windows compression API.
+var mamAlgorithms = map[byte]Algorithm{
+	2: AlgorithmLZNT1,
+	3: AlgorithmLZ77,
+	4: AlgorithmLZ77Huffman,
+}
+
+// StripContainer parses the container header from the beginning of
+// data and returns the compressed payload and its algorithm. The
+// function recognizes the MAM header of the Windows prefetch files.
+// The header's size and checksum fields are not verified. The
+// function returns ErrNoContainer if data does not have a known
+// container header.
+func StripContainer(data []byte) (payload []byte, algo Algorithm, err error) {
+	if len(data) < 4 || !bytes.HasPrefix(data, mamMagic) {
+		return nil, 0, ErrNoContainer
+	}
+	algo, ok := mamAlgorithms[data[3]&mamFormat]
+	if !ok || data[3]&^(mamFormat|mamChecksum) != 0 {
+		return nil, 0, ErrNoContainer
+	}
+	hdrLen := 8
+	if data[3]&mamChecksum != 0 {
+		hdrLen += 4
+	}
+	if len(data) < hdrLen {
+		return nil, 0, TruncatedInput
+	}
+	return data[hdrLen:], algo, nil
+}
+
+// The checksum trailer follows the compressed stream. The trailer has
+// the CRC-32 (IEEE) checksum of the uncompressed data as a
+// little-endian uint32 followed by the checksumMagic flag.
+var checksumMagic = []byte("XPCK")
+
+const checksumTrailerLen = 8
+
+// ErrChecksum is returned if the decompressed data does not match the
+// checksum of the checksum trailer.
+var ErrChecksum = corruption("Checksum mismatch")
+
+// ErrNoChecksum is returned if the checksum is verified but the data
+// does not end with the checksum trailer.
+var ErrNoChecksum = corruption("Missing checksum trailer")
+
+// appendChecksum appends the checksum trailer of data to out.
+func appendChecksum(out, data []byte) []byte {
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(data))
+	return append(out, checksumMagic...)
+}
+
+// stripChecksum removes the checksum trailer from the end of data.
+// The caller states that data has the trailer: the compressed streams
+// can end with the checksumMagic bytes so the trailer is never
+// guessed from the data. The function returns the data without the
+// trailer and the checksum of the trailer, or ErrNoChecksum if data
+// does not end with the trailer.
+func stripChecksum(data []byte) ([]byte, uint32, error) {
+	if len(data) < checksumTrailerLen ||
+		!bytes.HasSuffix(data, checksumMagic) {
+		return nil, 0, ErrNoChecksum
+	}
+	end := len(data) - checksumTrailerLen
+	return data[:end], binary.LittleEndian.Uint32(data[end:]), nil
+}
@@ -0,0 +1,164 @@
+//
+// container_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStripContainer(t *testing.T) {
+	payload := []byte{0x01, 0x02, 0x03}
+	tests := []struct {
+		header []byte
+		algo   Algorithm
+	}{
+		{[]byte("MAM\x02\x10\x00\x00\x00"), AlgorithmLZNT1},
+		{[]byte("MAM\x03\x10\x00\x00\x00"), AlgorithmLZ77},
+		{[]byte("MAM\x04\x10\x00\x00\x00"), AlgorithmLZ77Huffman},
+		{[]byte("MAM\x84\x10\x00\x00\x00\xaa\xbb\xcc\xdd"),
+			AlgorithmLZ77Huffman},
+	}
+	for _, test := range tests {
+		data := append(append([]byte{}, test.header...), payload...)
+		p, algo, err := StripContainer(data)
+		if err != nil {
+			t.Errorf("%x: %s", test.header, err)
+			continue
+		}
+		if algo != test.algo {
+			t.Errorf("%x: algorithm %s, expected %s", test.header, algo,
+				test.algo)
+		}
+		if !bytes.Equal(p, payload) {
+			t.Errorf("%x: payload %x, expected %x", test.header, p, payload)
+		}
+	}
+
+	for _, data := range [][]byte{
+		nil,
+		[]byte("MA"),
+		[]byte("MAM\x05\x10\x00\x00\x00"),
+		[]byte("MAM\x44\x10\x00\x00\x00"),
+		payload,
+	} {
+		if _, _, err := StripContainer(data); err != ErrNoContainer {
+			t.Errorf("%x: got %v, expected %v", data, err, ErrNoContainer)
+		}
+	}
+	_, _, err := StripContainer([]byte("MAM\x84\x10\x00\x00\x00"))
+	if err != TruncatedInput {
+		t.Errorf("got %v, expected %v", err, TruncatedInput)
+	}
+}
+
+func TestChecksumTrailer(t *testing.T) {
+	data := append(randomBytes(31, 5000), repeatedMatch(20000)...)
+	enc := &Encoder{
+		AppendChecksum: true,
+		Verify:         true,
+	}
+	lz77, err := enc.CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed bytes.Buffer
+	enc.Verify = false
+	if _, err := enc.CompressLZ77HuffmanTo(&streamed, data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), huffman) {
+		t.Errorf("CompressLZ77HuffmanTo output differs")
+	}
+	opts := &Options{
+		VerifyChecksum: true,
+	}
+	for algo, compressed := range map[Algorithm][]byte{
+		AlgorithmLZ77:        lz77,
+		AlgorithmLZ77Huffman: huffman,
+	} {
+		if !bytes.HasSuffix(compressed, checksumMagic) {
+			t.Fatalf("%s: trailer not written", algo)
+		}
+		out, err := DecompressWithOptions(algo, compressed, nil, opts)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%s: round trip failed: %v", algo, err)
+		}
+		var w bytes.Buffer
+		_, err = DecompressToWriter(algo, compressed, &w, opts)
+		if err != nil || !bytes.Equal(w.Bytes(), data) {
+			t.Errorf("%s: DecompressToWriter failed: %v", algo, err)
+		}
+
+		// The corrupt checksum.
+		corrupt := append([]byte(nil), compressed...)
+		corrupt[len(corrupt)-checksumTrailerLen] ^= 0x01
+		_, err = DecompressWithOptions(algo, corrupt, nil, opts)
+		if err != ErrChecksum {
+			t.Errorf("%s: got %v, expected %v", algo, err, ErrChecksum)
+		}
+		_, err = DecompressToWriter(algo, corrupt, &w, opts)
+		if err != ErrChecksum {
+			t.Errorf("%s: DecompressToWriter: got %v, expected %v",
+				algo, err, ErrChecksum)
+		}
+
+		// The stream without the trailer fails with VerifyChecksum
+		// and is decompressed without it.
+		plain := compressed[:len(compressed)-checksumTrailerLen]
+		_, err = DecompressWithOptions(algo, plain, nil, opts)
+		if err != ErrNoChecksum {
+			t.Errorf("%s: stream without trailer: got %v, expected %v",
+				algo, err, ErrNoChecksum)
+		}
+		_, err = DecompressToWriter(algo, plain, &w, opts)
+		if err != ErrNoChecksum {
+			t.Errorf("%s: DecompressToWriter: got %v, expected %v",
+				algo, err, ErrNoChecksum)
+		}
+		out, err = DecompressWithOptions(algo, plain, nil, nil)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%s: stream without trailer: %v", algo, err)
+		}
+	}
+
+	// The stream that ends with the trailer flag is not a trailer.
+	text := []byte("the quick brown fox says XPCK")
+	xpck, err := CompressLZ77(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(xpck, checksumMagic) {
+		t.Fatalf("stream does not end with the trailer flag")
+	}
+	out, err := DecompressWithOptions(AlgorithmLZ77, xpck, nil, nil)
+	if err != nil || !bytes.Equal(out, text) {
+		t.Errorf("stream ending with trailer flag: %v", err)
+	}
+	xpck, err = enc.CompressLZ77(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = DecompressWithOptions(AlgorithmLZ77, xpck, nil, opts)
+	if err != nil || !bytes.Equal(out, text) {
+		t.Errorf("stream ending with trailer flag and trailer: %v", err)
+	}
+
+	// The corrupt compressed data is detected with the checksum.
+	corrupt := append([]byte(nil), lz77...)
+	corrupt[len(corrupt)/2+1] ^= 0x01
+	out, err = DecompressWithOptions(AlgorithmLZ77, corrupt, nil, opts)
+	if err == nil {
+		t.Errorf("corrupt data decoded to %d bytes", len(out))
+	}
+}
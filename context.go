@@ -0,0 +1,46 @@
+//
+// context.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"context"
+)
+
+// DecompressLZ77HuffmanContext decompresses the LZ77+Huffman data
+// like DecompressLZ77Huffman. The decompression fails with the error
+// of ctx if ctx is done before the decompression completes.
+func DecompressLZ77HuffmanContext(ctx context.Context, data, out []byte) (
+	[]byte, error) {
+
+	return DecompressWithOptions(AlgorithmLZ77Huffman, data, out, &Options{
+		Context: ctx,
+	})
+}
+
+// DecompressLZ77Context decompresses the LZ77 data like
+// DecompressLZ77 and appends the output to out. See
+// DecompressLZ77HuffmanContext for the cancellation.
+func DecompressLZ77Context(ctx context.Context, data, out []byte) (
+	[]byte, error) {
+
+	return DecompressWithOptions(AlgorithmLZ77, data, out, &Options{
+		Context: ctx,
+	})
+}
+
+// DecompressLZNT1Context decompresses the LZNT1 data like
+// DecompressLZNT1 and appends the output to out. See
+// DecompressLZ77HuffmanContext for the cancellation.
+func DecompressLZNT1Context(ctx context.Context, data, out []byte) (
+	[]byte, error) {
+
+	return DecompressWithOptions(AlgorithmLZNT1, data, out, &Options{
+		Context: ctx,
+	})
+}
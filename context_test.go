@@ -0,0 +1,104 @@
+//
+// context_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// countingContext is a context that is canceled after its Err has
+// been called limit times.
+type countingContext struct {
+	context.Context
+	calls int
+	limit int
+}
+
+func (ctx *countingContext) Err() error {
+	ctx.calls++
+	if ctx.calls > ctx.limit {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestDecompressContext(t *testing.T) {
+	var data []byte
+	for i := 0; i < 20; i++ {
+		data = append(data, randomBytes(int64(70+i), 30000)...)
+		data = append(data, repeatedMatch(70000)...)
+	}
+	lz77, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lznt1 []byte
+	var lznt1Data []byte
+	for i := 0; i < 200; i++ {
+		chunk := randomBytes(int64(i), lznt1ChunkSize)
+		lznt1 = append(lznt1, lznt1Uncompressed(string(chunk))...)
+		lznt1Data = append(lznt1Data, chunk...)
+	}
+
+	tests := []struct {
+		name       string
+		decompress func(ctx context.Context, data, out []byte) ([]byte, error)
+		data       []byte
+		expected   []byte
+	}{
+		{"LZ77", DecompressLZ77Context, lz77, data},
+		{"LZ77+Huffman", DecompressLZ77HuffmanContext, huffman, data},
+		{"LZNT1", DecompressLZNT1Context, lznt1, lznt1Data},
+	}
+	for _, test := range tests {
+		out, err := test.decompress(context.Background(), test.data,
+			[]byte("xyz"))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !bytes.Equal(out, append([]byte("xyz"), test.expected...)) {
+			t.Errorf("%s: output mismatch", test.name)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = test.decompress(ctx, test.data, nil)
+		if err != context.Canceled {
+			t.Errorf("%s: canceled context: got %v", test.name, err)
+		}
+
+		// The context is checked after every checkSize bytes
+		// of output.
+		counting := &countingContext{
+			Context: context.Background(),
+			limit:   2,
+		}
+		_, err = test.decompress(counting, test.data, nil)
+		if err != context.Canceled {
+			t.Errorf("%s: canceled during decoding: got %v", test.name, err)
+		}
+		max := len(test.expected)/checkSize + 2
+		counting = &countingContext{
+			Context: context.Background(),
+			limit:   max,
+		}
+		if _, err := test.decompress(counting, test.data, nil); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		}
+		if counting.calls < 3 || counting.calls > max {
+			t.Errorf("%s: %d context checks", test.name, counting.calls)
+		}
+	}
+}
@@ -9,11 +9,72 @@
 package xpress
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 )
 
-var TruncatedInput = errors.New("Truncated input")
+// MatchWindowSize specifies the size of the LZ77+Huffman match
+// window. Match offsets can not exceed the window size.
+const MatchWindowSize = 32 * 1024
+
+// The decoding errors. The errors that mean that the input ended
+// before the end of the stream match ErrTruncatedInput with
+// errors.Is: the decoding can succeed with more data. The errors of
+// corrupt data match ErrCorrupt: the data does not decode with more
+// input. An LZ77+Huffman stream shorter than its Huffman table is
+// truncated. The errors of the caller's limits, such as
+// ErrOutputTooLarge, match neither.
+var (
+	ErrTruncatedInput = errors.New("Truncated input")
+	ErrCorrupt        = errors.New("Corrupt data")
+
+	ErrInvalidData           = corruption("Invalid data")
+	ErrInvalidHuffmanTable   = corruption("Invalid Huffman table")
+	ErrHuffmanTableUnderflow = fmt.Errorf("%w: underflow",
+		ErrInvalidHuffmanTable)
+	ErrOffsetExceedsWindow   = corruption("Match offset exceeds window")
+	ErrInvalidMatchOffset    = corruption("Match offset exceeds output")
+	ErrChunkOverrun          = corruption("Chunk exceeds output size")
+	ErrCrossResetReference   = corruption("Match crosses window reset point")
+	ErrTrailingBits          = corruption("Bits after end-of-stream marker")
+	ErrOutputTooLarge        = errors.New("Output too large")
+	ErrTooManyMatches        = errors.New("Too many matches")
+	ErrExpansionLimit        = errors.New("Output exceeds expansion limit")
+	ErrDisallowedOffset      = errors.New("Match offset not allowed")
+	ErrShortBuffer           = errors.New("Output buffer too short")
+	ErrTruncatedLengthNibble = fmt.Errorf("%w: match length nibble",
+		ErrTruncatedInput)
+
+	// TruncatedInput is the original name of ErrTruncatedInput.
+	TruncatedInput = ErrTruncatedInput
+)
+
+// categoryError is a sentinel error with its own message that matches
+// its error category with errors.Is.
+type categoryError struct {
+	msg      string
+	category error
+}
+
+func (e *categoryError) Error() string {
+	return e.msg
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.category
+}
+
+// corruption creates a sentinel error of the ErrCorrupt category.
+func corruption(msg string) error {
+	return &categoryError{
+		msg:      msg,
+		category: ErrCorrupt,
+	}
+}
 
 type SymbolLength []byte
 
@@ -31,26 +92,20 @@ type input struct {
 }
 
 func (in *input) ReadUint32() (uint32, error) {
-	if in.pos+4 > len(in.input) {
+	if len(in.input)-in.pos < 4 {
 		return 0, TruncatedInput
 	}
-	var val uint32
-	for i := 0; i < 4; i++ {
-		val |= (uint32(in.input[in.pos]) << uint(i*8))
-		in.pos++
-	}
+	val := binary.LittleEndian.Uint32(in.input[in.pos:])
+	in.pos += 4
 	return val, nil
 }
 
 func (in *input) ReadUint16() (uint16, error) {
-	if in.pos+2 > len(in.input) {
+	if len(in.input)-in.pos < 2 {
 		return 0, TruncatedInput
 	}
-	var val uint16
-	for i := 0; i < 2; i++ {
-		val |= (uint16(in.input[in.pos]) << uint(i*8))
-		in.pos++
-	}
+	val := binary.LittleEndian.Uint16(in.input[in.pos:])
+	in.pos += 2
 	return val, nil
 }
 
@@ -68,235 +123,1368 @@ func (in *input) Avail() int {
 
 const huffmanTableLength = 32768
 
-func DecompressLZ77Huffman(data []byte, out []byte) ([]byte, error) {
-	if len(data) < 256 {
-		return out, errors.New("Invalid data")
+// decoder implements the output of the decompressors. The
+// decompressors report their tokens with the literal and match
+// methods.
+type decoder struct {
+	out    []byte
+	start  int
+	maxOut int
+
+	// maxMatches limits the number of matches if it is not 0.
+	maxMatches int
+	matches    int
+
+	// maxExpansion limits the output to maxExpansion times the input
+	// length if it is not 0. The expansionLimit is the output limit
+	// of the current input.
+	maxExpansion   int
+	expansionLimit int
+
+	sized  bool
+	size   int
+	strict bool
+
+	// consumed is the input position where the LZ77+Huffman decoding
+	// stopped.
+	consumed int
+
+	profile Profile
+	lenient bool
+
+	// prefix truncates the tokens at size and ends the decoding
+	// without checking the data after the size.
+	prefix bool
+
+	// partial tells that the data can be a prefix of a larger stream.
+	partial bool
+
+	trace   io.Writer
+	resolve func(offset int) byte
+	resets  []int
+
+	// allowOffset tells if the match offset is legal. The value nil
+	// allows all offsets.
+	allowOffset func(offset int) bool
+
+	// discard counts the output bytes and tokens without storing
+	// the output.
+	discard   bool
+	discarded int
+	tokens    int
+
+	// maxOffset is the longest match offset seen.
+	maxOffset int
+
+	// record collects the decoded tokens.
+	record *[]Token
+
+	// records collects the machine-readable token trace. The bitPos
+	// is the input bit position of the current LZ77+Huffman token,
+	// or -1 for the byte-aligned tokens.
+	records *[]TokenRecord
+	bitPos  int
+
+	// onToken is called for each decoded token before its output is
+	// produced. The decoding fails with its error.
+	onToken func(t Token) error
+
+	// chunker receives the output for the content-defined chunking.
+	// The chunked is the length of the output that it has received.
+	chunker *Chunker
+	chunked int
+
+	// stats collects the token statistics.
+	stats *Stats
+
+	// sparse collects the zero-fill regions of the output.
+	sparse *[]SparseRegion
+
+	// histogram counts the decoded Huffman symbols.
+	histogram *[huffmanSymbols]int
+
+	// window is the circular output buffer of the streaming
+	// decoders. If window is set, the output is written to the
+	// window instead of out.
+	window *window
+
+	// fast enables the specialized decoding loops. The loops append
+	// the output directly to out and bypass the limits and hooks of
+	// the decoder, so only the decoders that have no limits or hooks
+	// set it.
+	fast bool
+
+	// flushChunks flushes the window at the end of each LZNT1 chunk.
+	flushChunks bool
+
+	// matchSymbols maps the LZ77+Huffman match symbols. The value nil
+	// is the standard mapping.
+	matchSymbols MatchSymbolMap
+
+	// ctx cancels the decoding and policy aborts it. The nextCheck
+	// is the produced output length at which they are checked next.
+	ctx       context.Context
+	policy    Policy
+	nextCheck int
+}
+
+// maxOutput is the largest output length that the platform's int
+// can hold. The tests lower it to check the output length guard.
+var maxOutput = int(^uint(0) >> 1)
+
+// reserve checks that the output limit and the output size allow n
+// more output bytes. An output that exactly fills the limit is
+// allowed. The output length must also fit into an int independent
+// of the output limit.
+func (d *decoder) reserve(n int) error {
+	if d.chunker != nil && d.window == nil &&
+		len(d.out)-d.chunked >= chunkFeedSize {
+		if err := d.feedChunker(); err != nil {
+			return err
+		}
+	}
+	if n > maxOutput-d.produced() {
+		return ErrOutputTooLarge
+	}
+	if d.maxOut > 0 && d.decoded()+n > d.maxOut {
+		return ErrOutputTooLarge
 	}
+	if d.expansionLimit > 0 && d.decoded()+n > d.expansionLimit {
+		return ErrExpansionLimit
+	}
+	if d.sized && d.decoded()+n > d.size {
+		return ErrChunkOverrun
+	}
+	return nil
+}
 
-	var symLen SymbolLength = data[0:256]
-	var currentTableEntry int
-	var decodingTable [huffmanTableLength]uint16
+// decoded returns the number of bytes the decoder has produced,
+// excluding the initial contents of the output buffer.
+func (d *decoder) decoded() int {
+	return d.produced() - d.start
+}
 
-	for bitLength := 1; bitLength <= 15; bitLength++ {
-		for symbol := 0; symbol < 512; symbol++ {
-			if symLen.Length(symbol) == bitLength {
-				entryCount := (1 << uint(15-bitLength))
-				for e := 0; e < entryCount; e++ {
-					if currentTableEntry >= huffmanTableLength {
-						return out, fmt.Errorf("Invalid Huffman table")
-					}
-					decodingTable[currentTableEntry] = uint16(symbol)
-					currentTableEntry++
-				}
+// done tests if the decoder has produced the expected output size.
+func (d *decoder) done() bool {
+	return d.sized && d.decoded() >= d.size
+}
+
+// flush writes the pending output of the window to its sink.
+func (d *decoder) flush() error {
+	if d.window == nil {
+		return nil
+	}
+	return d.window.flush()
+}
+
+func (d *decoder) literal(pos int, b byte) error {
+	if err := d.reserve(1); err != nil {
+		return err
+	}
+	if d.trace != nil {
+		_, err := fmt.Fprintf(d.trace, "%08x: literal %02x\n", pos, b)
+		if err != nil {
+			return err
+		}
+	}
+	if err := d.token(pos, d.decoded(), Token{Literal: b}); err != nil {
+		return err
+	}
+	if d.stats != nil {
+		d.stats.Literals++
+		d.stats.LiteralBytes++
+	}
+	if d.discard {
+		d.discarded++
+		d.tokens++
+		return nil
+	}
+	if d.window != nil {
+		return d.window.writeByte(b)
+	}
+	d.out = append(d.out, b)
+	return nil
+}
+
+// token reports the decoded token to the record, records, and
+// onToken hooks. The pos is the token's input offset and output its
+// output offset.
+func (d *decoder) token(pos, output int, t Token) error {
+	if d.record != nil {
+		*d.record = append(*d.record, t)
+	}
+	if d.records != nil {
+		*d.records = append(*d.records, d.tokenRecord(pos, output, t))
+	}
+	if d.onToken != nil {
+		return d.onToken(t)
+	}
+	return nil
+}
+
+// produced returns the number of output bytes, including the
+// discarded bytes and the bytes written to the window.
+func (d *decoder) produced() int {
+	n := len(d.out) + d.discarded
+	if d.window != nil {
+		n += d.window.pos
+	}
+	return n
+}
+
+func (d *decoder) literals(pos int, data []byte) error {
+	if d.prefix && d.sized && d.decoded()+len(data) > d.size {
+		data = data[:d.size-d.decoded()]
+	}
+	if err := d.reserve(len(data)); err != nil {
+		return err
+	}
+	if d.trace != nil {
+		for i, b := range data {
+			_, err := fmt.Fprintf(d.trace, "%08x: literal %02x\n", pos+i, b)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if d.record != nil || d.records != nil || d.onToken != nil {
+		for i, b := range data {
+			err := d.token(pos+i, d.decoded()+i, Token{Literal: b})
+			if err != nil {
+				return err
 			}
 		}
 	}
-	if currentTableEntry != huffmanTableLength {
-		return out, errors.New("Huffman table underflow")
+	if d.stats != nil {
+		d.stats.Literals += len(data)
+		d.stats.LiteralBytes += int64(len(data))
 	}
+	if d.discard {
+		d.discarded += len(data)
+		d.tokens += len(data)
+		return nil
+	}
+	if d.window != nil {
+		return d.window.write(data)
+	}
+	d.out = append(d.out, data...)
+	return nil
+}
 
-	// Inflate data.
-	in := &input{
-		input: data,
-		pos:   256,
+func (d *decoder) match(pos, offset, length int) error {
+	d.matches++
+	if d.maxMatches > 0 && d.matches > d.maxMatches {
+		return ErrTooManyMatches
+	}
+	if (d.lenient || d.prefix) && d.sized && d.decoded()+length > d.size {
+		// Copy the part of the final match that fits into the size.
+		length = d.size - d.decoded()
+	}
+	if err := d.reserve(length); err != nil {
+		return err
+	}
+	if d.trace != nil {
+		_, err := fmt.Fprintf(d.trace, "%08x: match offset=%d length=%d\n",
+			pos, offset, length)
+		if err != nil {
+			return err
+		}
+	}
+	if d.resets != nil {
+		// The match must not reference data before a reset point
+		// that precedes any of the match's output bytes.
+		src := d.produced() - offset
+		i := sort.SearchInts(d.resets, src+1)
+		if i < len(d.resets) && d.resets[i] < d.produced()+length {
+			return ErrCrossResetReference
+		}
+	}
+	if offset > d.produced() && d.resolve == nil {
+		return ErrInvalidMatchOffset
+	}
+	if d.allowOffset != nil && !d.allowOffset(offset) {
+		return fmt.Errorf("%w: offset %d", ErrDisallowedOffset, offset)
+	}
+	if offset > d.maxOffset {
+		d.maxOffset = offset
 	}
-	b, err := in.ReadUint16()
+	err := d.token(pos, d.decoded(), Token{
+		Offset: offset,
+		Length: length,
+	})
 	if err != nil {
-		return out, err
+		return err
 	}
-	nextBits := uint32(b) << 16
-	b, err = in.ReadUint16()
+	if d.stats != nil {
+		d.stats.match(offset, length)
+	}
+	if d.discard {
+		d.discarded += length
+		d.tokens++
+		return nil
+	}
+	if d.window != nil {
+		return d.windowMatch(offset, length)
+	}
+	if offset > len(d.out) {
+		for i := 0; i < length; i++ {
+			src := len(d.out) - offset
+			if src < 0 {
+				d.out = append(d.out, d.resolve(-src))
+			} else {
+				d.out = append(d.out, d.out[src])
+			}
+		}
+		return nil
+	}
+	if offset == 1 && d.out[len(d.out)-1] == 0 {
+		// Zero-fill run.
+		if d.sparse != nil {
+			d.addSparse(d.produced()-d.start, length)
+		}
+		d.grow(length)
+		run := d.out[len(d.out) : len(d.out)+length]
+		for i := range run {
+			run[i] = 0
+		}
+		d.out = d.out[:len(d.out)+length]
+		return nil
+	}
+	// Reserve the capacity before copying so that the source and
+	// destination are in the same backing array. A match that does
+	// not overlap its source is a single copy. The overlapping
+	// matches repeat the last offset bytes: each copy doubles the
+	// copied bytes that are a multiple of offset.
+	d.grow(length)
+	end := len(d.out) + length
+	dst := len(d.out)
+	src := dst - offset
+	d.out = d.out[:end]
+	for copied := 0; copied < length; {
+		copied += copy(d.out[dst+copied:end], d.out[src:dst+copied])
+	}
+	return nil
+}
+
+// windowMatch copies the match to the window. The matches that
+// reference data before the beginning of the output are resolved
+// byte by byte.
+func (d *decoder) windowMatch(offset, length int) error {
+	w := d.window
+	if offset > MatchWindowSize {
+		return ErrOffsetExceedsWindow
+	}
+	if offset <= w.pos {
+		return w.copyMatch(offset, length)
+	}
+	for i := 0; i < length; i++ {
+		b := w.byteAt(offset)
+		if offset > w.pos {
+			b = d.resolve(offset - w.pos)
+		}
+		if err := w.writeByte(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grow ensures that out has capacity for n more bytes. The capacity
+// does not exceed the output limit maxOut.
+func (d *decoder) grow(n int) {
+	if cap(d.out)-len(d.out) >= n {
+		return
+	}
+	size := 2*cap(d.out) + n
+	if d.maxOut > 0 {
+		if max := len(d.out) + d.maxOut - d.decoded(); size > max {
+			size = max
+		}
+	}
+	out := make([]byte, len(d.out), size)
+	copy(out, d.out)
+	d.out = out
+}
+
+// addSparse adds the zero-fill region to the sparse regions. The
+// adjacent regions are merged and the regions shorter than
+// SparseMinLength are dropped when a new region starts.
+func (d *decoder) addSparse(offset, length int) {
+	regions := *d.sparse
+	last := len(regions) - 1
+	if last >= 0 && regions[last].Offset+regions[last].Length == offset {
+		regions[last].Length += length
+		return
+	}
+	if last >= 0 && regions[last].Length < SparseMinLength {
+		regions = regions[:last]
+	}
+	*d.sparse = append(regions, SparseRegion{
+		Offset: offset,
+		Length: length,
+	})
+}
+
+func (d *decoder) chunk(pos int, compressed bool, length int) error {
+	if d.trace != nil {
+		_, err := fmt.Fprintf(d.trace, "%08x: chunk compressed=%v length=%d\n",
+			pos, compressed, length)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DecompressLZ77Huffman(data []byte, out []byte) ([]byte, error) {
+	if out == nil && len(data) == 0 {
+		return []byte{}, nil
+	}
+	d := &decoder{
+		out:   out,
+		start: len(out),
+		fast:  true,
+	}
+	err := d.lz77Huffman(data)
+	return d.out, err
+}
+
+// DecompressLZ77HuffmanTable decompresses the LZ77+Huffman stream
+// whose first Huffman table is stored apart from the bitstream. The
+// table must have 256 bytes and the bitstream starts with the first
+// bits of the first block. The following blocks, with their own
+// tables, follow the first block in bitstream. The function appends
+// the decompressed data to out. DecompressLZ77Huffman(data, out) is
+// DecompressLZ77HuffmanTable(data[:256], data[256:], out).
+func DecompressLZ77HuffmanTable(table, bitstream, out []byte) (
+	[]byte, error) {
+
+	d := &decoder{
+		out:   out,
+		start: len(out),
+		fast:  true,
+	}
+	err := d.lz77HuffmanTable(table, bitstream)
+	return d.out, err
+}
+
+// DecompressLZ77HuffmanStrict decompresses the LZ77+Huffman data
+// like DecompressLZ77Huffman and checks that the stream is complete.
+// The stream must decode to exactly expectedLen bytes and the bits
+// after the end-of-stream marker must be zero padding. A truncated
+// stream that happens to end at an end-of-stream marker fails with an
+// ErrTruncatedInput error, the streams with more output with
+// ErrOutputTooLarge, and the streams with data after the marker with
+// ErrTrailingBits. The function appends the decompressed data to out.
+func DecompressLZ77HuffmanStrict(data, out []byte, expectedLen int) (
+	[]byte, error) {
+
+	if expectedLen < 0 {
+		return nil, fmt.Errorf("Invalid expected length %d", expectedLen)
+	}
+	d := &decoder{
+		out:    out,
+		start:  len(out),
+		maxOut: expectedLen,
+		strict: true,
+	}
+	if expectedLen == 0 {
+		// The limit 0 is unlimited: the output is discarded and
+		// its length checked below.
+		d.discard = true
+	}
+	err := d.lz77Huffman(data)
 	if err != nil {
-		return out, err
+		return nil, err
 	}
-	nextBits |= uint32(b)
-	extraBits := 16
+	if d.decoded() > expectedLen {
+		return nil, ErrOutputTooLarge
+	}
+	if d.decoded() < expectedLen {
+		return nil, fmt.Errorf("%w: decoded %d bytes, expected %d",
+			ErrTruncatedInput, d.decoded(), expectedLen)
+	}
+	return d.out, nil
+}
 
-	// Loop until a terminating condition.
-	for {
-		next15Bits := nextBits >> (32 - 15)
-		huffmanSymbol := decodingTable[next15Bits]
-		huffmanSymbolBitLength := symLen.Length(int(huffmanSymbol))
+// WindowLimitMargin specifies how close to the match window edge a
+// match offset must be for DecompressLZ77HuffmanWindow to report it.
+const WindowLimitMargin = 1024
 
-		nextBits <<= uint(huffmanSymbolBitLength)
-		extraBits -= huffmanSymbolBitLength
+// DecompressLZ77HuffmanWindow decompresses the LZ77+Huffman data
+// like DecompressLZ77Huffman and reports if any match offset came
+// within WindowLimitMargin bytes of the MatchWindowSize. If
+// nearWindowLimit is false, the data can be decoded with a window of
+// MatchWindowSize-WindowLimitMargin bytes.
+func DecompressLZ77HuffmanWindow(data []byte, out []byte) (
+	result []byte, nearWindowLimit bool, err error) {
 
-		if extraBits < 0 {
-			b, err := in.ReadUint16()
-			if err != nil {
-				return out, err
+	d := &decoder{
+		out:   out,
+		start: len(out),
+	}
+	err = d.lz77Huffman(data)
+	nearWindowLimit = d.maxOffset > MatchWindowSize-WindowLimitMargin
+	return d.out, nearWindowLimit, err
+}
+
+// DecompressLZ77HuffmanHistogram decompresses the LZ77+Huffman data
+// like DecompressLZ77Huffman and returns the number of times each
+// Huffman symbol was decoded. The end-of-stream marker is not
+// counted so the histogram sums to the number of decoded literals
+// and matches.
+func DecompressLZ77HuffmanHistogram(data []byte, out []byte) (
+	[]byte, [512]int, error) {
+
+	var histogram [huffmanSymbols]int
+	d := &decoder{
+		out:       out,
+		start:     len(out),
+		histogram: &histogram,
+	}
+	err := d.lz77Huffman(data)
+	return d.out, histogram, err
+}
+
+// DecompressLZ77HuffmanTo decompresses the LZ77+Huffman data and
+// writes the decompressed data to w with bounded memory. The decoder
+// resolves the matches from a circular window of 2*MatchWindowSize
+// bytes and writes the bytes to w before the window overwrites them,
+// so the output is never kept in memory beyond the window. The
+// function returns the number of bytes written to w and the first
+// error of w.
+func DecompressLZ77HuffmanTo(w io.Writer, data []byte) (int64, error) {
+	return DecompressToWriter(AlgorithmLZ77Huffman, data, w, nil)
+}
+
+// DecompressLZ77HuffmanStream is an alias of DecompressLZ77HuffmanTo.
+//
+// Deprecated: use DecompressLZ77HuffmanTo.
+func DecompressLZ77HuffmanStream(w io.Writer, data []byte) (int64, error) {
+	return DecompressLZ77HuffmanTo(w, data)
+}
+
+// DecompressLZ77HuffmanChan decompresses the LZ77+Huffman data in a
+// new goroutine and sends the decompressed data on the returned data
+// channel in chunks of chunkSize bytes, except possibly the last
+// chunk. If chunkSize is 0, the chunks are sent as the decoder's
+// circular window fills. The data channel is closed when the
+// decoding ends. If the decoding fails, the error is sent on the
+// error channel after the data that was decoded before the error.
+// The error channel is closed after the data channel. The caller must
+// receive all chunks from the data channel.
+func DecompressLZ77HuffmanChan(data []byte, chunkSize int) (
+	<-chan []byte, <-chan error) {
+
+	out := make(chan []byte)
+	errc := make(chan error, 1)
+	if chunkSize < 0 {
+		close(out)
+		errc <- fmt.Errorf("Invalid chunk size %d", chunkSize)
+		close(errc)
+		return out, errc
+	}
+	go func() {
+		_, err := DecompressToWriter(AlgorithmLZ77Huffman, data,
+			chanWriter(out), &Options{
+				FlushSize: chunkSize,
+			})
+		close(out)
+		if err != nil {
+			errc <- err
+		}
+		close(errc)
+	}()
+	return out, errc
+}
+
+// chanWriter sends the written data on the channel.
+type chanWriter chan<- []byte
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- append([]byte{}, p...)
+	return len(p), nil
+}
+
+// DecompressLZ77HuffmanToWriterAt decompresses the LZ77+Huffman
+// data and writes the decompressed data to w starting from the
+// offset baseOffset. The decoder retains the match window in memory
+// so the matches do not read from w. The function returns the number
+// of bytes written to w.
+func DecompressLZ77HuffmanToWriterAt(data []byte, w io.WriterAt,
+	baseOffset int64) (int, error) {
+
+	var n int64
+	d := &decoder{
+		window: newWindow(writerSink(io.NewOffsetWriter(w, baseOffset),
+			&n), 0),
+	}
+	err := d.lz77Huffman(data)
+	if err == nil {
+		err = d.flush()
+	}
+	return int(n), err
+}
+
+func (d *decoder) lz77Huffman(data []byte) error {
+	if len(data) == 0 && !d.partial {
+		// The empty stream is the compression of empty data.
+		return nil
+	}
+	if len(data) < 256 {
+		if d.partial {
+			return TruncatedInput
+		}
+		return fmt.Errorf("%w: stream shorter than Huffman table",
+			ErrTruncatedInput)
+	}
+	in := &input{
+		input: data,
+	}
+	hd := huffmanDecoders.Get().(*HuffmanDecoder)
+	defer putHuffmanDecoder(hd)
+	err := d.huffmanBlocks(in, hd)
+	d.consumed = in.pos
+	return err
+}
+
+// lz77HuffmanTable decodes the LZ77+Huffman stream whose first block
+// has the Huffman table table and the bitstream that starts at the
+// beginning of bitstream. The following blocks are in bitstream after
+// the first block.
+func (d *decoder) lz77HuffmanTable(table, bitstream []byte) error {
+	if len(table) != huffmanSymbols/2 {
+		return fmt.Errorf("%w: length %d", ErrInvalidHuffmanTable,
+			len(table))
+	}
+	in := &input{
+		input: bitstream,
+	}
+	hd := huffmanDecoders.Get().(*HuffmanDecoder)
+	defer putHuffmanDecoder(hd)
+	if err := d.check(in); err != nil {
+		return err
+	}
+	if err := hd.init(table); err != nil {
+		return err
+	}
+	end, err := d.huffmanBits(in, hd)
+	if err != nil || end {
+		return err
+	}
+	return d.huffmanBlocks(in, hd)
+}
+
+// huffmanBlocks decodes the LZ77+Huffman blocks that start at the
+// current input position. The stream ends at a block boundary if its
+// output is a multiple of the block size.
+func (d *decoder) huffmanBlocks(in *input, hd *HuffmanDecoder) error {
+	for in.Avail() > 0 {
+		if in.Avail() < 256 {
+			return TruncatedInput
+		}
+		if err := d.check(in); err != nil {
+			return err
+		}
+		end, err := d.huffmanBlock(in, hd)
+		if err != nil || end {
+			return err
+		}
+	}
+	return nil
+}
+
+// huffmanBlockSize is the output size of the LZ77+Huffman blocks. Each
+// block starts with its own Huffman table (MS-XCA 2.2.4).
+const huffmanBlockSize = 64 * 1024
+
+// huffmanBlock decodes the LZ77+Huffman block that starts at the
+// current input position. The block ends when its output reaches
+// huffmanBlockSize bytes. The last match of the block can extend past
+// the block size and the next block is counted from the end of the
+// match. The function returns true if the stream ended inside the
+// block.
+func (d *decoder) huffmanBlock(in *input, hd *HuffmanDecoder) (
+	bool, error) {
+
+	if err := hd.init(in.input[in.pos : in.pos+256]); err != nil {
+		return false, err
+	}
+	in.pos += 256
+	return d.huffmanBits(in, hd)
+}
+
+// huffmanBits decodes the bitstream of the block that follows the
+// block's Huffman table. The function returns true if the stream
+// ended inside the block.
+func (d *decoder) huffmanBits(in *input, hd *HuffmanDecoder) (
+	bool, error) {
+
+	table := &hd.table
+	br := BitReader{
+		in: in,
+	}
+	end, err := d.huffmanStart(&br, table)
+	if err != nil || end {
+		return end, err
+	}
+	blockEnd := d.decoded() + huffmanBlockSize
+
+	if d.fast && literalsOnly(hd.symLen) {
+		return d.huffmanLiterals(&br, table, blockEnd)
+	}
+	return d.huffmanTokens(&br, table, blockEnd)
+}
+
+// huffmanStart starts the bitstream of the block that follows the
+// block's Huffman table. A block without token bytes decodes its
+// first symbol from zero bits, i.e. from the first canonical code.
+// The block is a valid empty block if that symbol is the
+// end-of-stream marker and the function returns true. Otherwise the
+// bit reader loads the first two words of the block. At the end of
+// the block, the decoder has read the two words ahead of the
+// consumed bits and the next block's table follows them.
+func (d *decoder) huffmanStart(br *BitReader, table *decodingTable) (
+	bool, error) {
+
+	if br.in.Avail() == 0 {
+		if sym, _ := table.lookup(0); sym == 256 {
+			return true, nil
+		}
+		return false, TruncatedInput
+	}
+	return false, br.load()
+}
+
+// literalsOnly tests if the Huffman table does not have any match
+// symbols. The symbol 256 is the end-of-stream marker.
+func literalsOnly(symLen SymbolLength) bool {
+	for symbol := 257; symbol < 512; symbol++ {
+		if symLen.Length(symbol) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// huffmanLiterals decodes a Huffman block that has only literal
+// symbols. The symbol 256 is the end-of-stream marker if it is seen
+// at the end of input. Otherwise it is a match with offset 1 and
+// length 3. The block ends when the decoded output reaches
+// blockEnd. The function returns true if the stream ended.
+func (d *decoder) huffmanLiterals(br *BitReader, table *decodingTable,
+	blockEnd int) (bool, error) {
+
+	in := br.in
+	for d.decoded() < blockEnd {
+		huffmanSymbol, huffmanSymbolBitLength := table.lookup(br.next)
+		br.consume(huffmanSymbolBitLength)
+
+		if br.extra < 0 {
+			if d.terminator(in, huffmanSymbol) {
+				return true, d.end(br, table, false)
+			}
+			if huffmanSymbol < 256 &&
+				d.endOfBits(in, table, br.next, br.valid()) {
+				d.out = append(d.out, byte(huffmanSymbol))
+				return true, d.end(br, table, true)
+			}
+			if err := br.refill(); err != nil {
+				return false, err
 			}
-			nextBits |= uint32(b) << uint(-extraBits)
-			extraBits += 16
 		}
 		if huffmanSymbol < 256 {
-			out = append(out, byte(huffmanSymbol))
-		} else if huffmanSymbol == 256 && in.Avail() == 0 {
-			return out, nil
+			d.out = append(d.out, byte(huffmanSymbol))
+		} else if d.terminator(in, huffmanSymbol) {
+			return true, d.end(br, table, false)
 		} else {
-			huffmanSymbol = huffmanSymbol - 256
-			matchLength := huffmanSymbol % 16
-			matchOffsetBitLength := huffmanSymbol / 16
-			if matchLength == 15 {
-				b, err := in.ReadByte()
-				if err != nil {
-					return out, err
-				}
-				matchLength = uint16(b)
-				if matchLength == 255 {
-					b, err := in.ReadUint16()
-					if err != nil {
-						return out, err
-					}
-					matchLength = b
-					if matchLength < 15 {
-						return out, errors.New("Invalid data")
-					}
-					matchLength -= 15
-				}
-				matchLength += 15
+			err := d.match(in.pos, 1, 3)
+			if err != nil {
+				return false, err
 			}
-			matchLength += 3
-			matchOffset := nextBits >> (32 - matchOffsetBitLength)
-			matchOffset += (1 << matchOffsetBitLength)
-			nextBits <<= matchOffsetBitLength
-			extraBits -= int(matchOffsetBitLength)
-			if extraBits < 0 {
-				b, err := in.ReadUint16()
-				if err != nil {
-					return out, err
+		}
+	}
+	return false, nil
+}
+
+// terminator tests if the Huffman symbol terminates the stream. The
+// symbol 256 is the end-of-stream marker when the input is consumed.
+// The decoder checks for the marker also before refilling its bit
+// buffer since the marker's bits are already in the buffer when the
+// refill would read past the end of input.
+func (d *decoder) terminator(in *input, huffmanSymbol uint16) bool {
+	return huffmanSymbol == 256 && in.Avail() == 0 &&
+		d.profile != Profile7Zip
+}
+
+// endOfBits tests if the valid bits that remain in the bit buffer at
+// the end of input are the end-of-stream marker. The stream can end
+// with fewer valid bits than the longest code. The bits after the
+// valid bits are zero padding and the decoder does not decode a
+// symbol from them: a code longer than the valid bits is a truncated
+// stream.
+func (d *decoder) endOfBits(in *input, table *decodingTable,
+	nextBits uint32, valid int) bool {
+
+	huffmanSymbol, huffmanSymbolBitLength := table.lookup(nextBits)
+	return huffmanSymbolBitLength <= valid && d.terminator(in, huffmanSymbol)
+}
+
+// end checks the end of the stream at the end-of-stream marker. If
+// marker is true, the marker is in the bit buffer and it is consumed
+// first. In the strict mode, the buffered bits after the marker must
+// be zero padding.
+func (d *decoder) end(br *BitReader, table *decodingTable,
+	marker bool) error {
+
+	if !d.strict {
+		return nil
+	}
+	if marker {
+		_, huffmanSymbolBitLength := table.lookup(br.next)
+		br.consume(huffmanSymbolBitLength)
+	}
+	if br.next != 0 {
+		return ErrTrailingBits
+	}
+	return nil
+}
+
+// huffmanTokens decodes a Huffman block. The block ends when the
+// decoded output reaches blockEnd. The function returns true if the
+// stream ended.
+func (d *decoder) huffmanTokens(br *BitReader, table *decodingTable,
+	blockEnd int) (bool, error) {
+
+	// Loop until a terminating condition.
+	for d.decoded() < blockEnd {
+		if d.done() {
+			return true, nil
+		}
+		end, err := d.huffmanToken(br, table)
+		if err != nil || end {
+			return end, err
+		}
+	}
+	return d.done(), nil
+}
+
+// huffmanToken decodes the next token of a Huffman block. The
+// function returns true if the stream ended.
+func (d *decoder) huffmanToken(br *BitReader, table *decodingTable) (
+	bool, error) {
+
+	in := br.in
+	pos := in.pos
+	if d.records != nil || d.trace != nil {
+		// The token is read from the byte of its first bit.
+		d.bitPos = br.bitOffset()
+		pos = d.bitPos / 8
+	}
+	huffmanSymbol, huffmanSymbolBitLength := table.lookup(br.next)
+	br.consume(huffmanSymbolBitLength)
+
+	if br.extra < 0 {
+		if d.terminator(in, huffmanSymbol) {
+			return true, d.end(br, table, false)
+		}
+		if huffmanSymbol < 256 &&
+			d.endOfBits(in, table, br.next, br.valid()) {
+			if d.histogram != nil {
+				d.histogram[huffmanSymbol]++
+			}
+			err := d.literal(pos, byte(huffmanSymbol))
+			if err != nil {
+				return true, err
+			}
+			return true, d.end(br, table, true)
+		}
+		if err := br.refill(); err != nil {
+			return false, err
+		}
+	}
+	if huffmanSymbol < 256 {
+		if d.histogram != nil {
+			d.histogram[huffmanSymbol]++
+		}
+		err := d.literal(pos, byte(huffmanSymbol))
+		if err != nil {
+			return false, err
+		}
+	} else if d.terminator(in, huffmanSymbol) {
+		return true, d.end(br, table, false)
+	} else {
+		if d.histogram != nil {
+			d.histogram[huffmanSymbol]++
+		}
+		matchLength, matchOffsetBitLength, err := d.matchSymbol(
+			huffmanSymbol)
+		if err != nil {
+			return false, err
+		}
+		var truncated bool
+		if matchLength == 15 {
+			matchLength, err = huffmanLongLength(in)
+			if err != nil {
+				if !d.lenient || !truncation(err) {
+					return false, err
 				}
-				nextBits |= uint32(b) << uint(-extraBits)
-				extraBits += 16
+				truncated = true
 			}
-			for i := 0; i < int(matchLength); i++ {
-				b := out[len(out)-int(matchOffset)]
-				out = append(out, b)
+		}
+		matchLength += 3
+		// The offset is encoded without its most significant
+		// bit (MS-XCA 2.2.4). The symbol's high nibble is the
+		// position of the offset's highest set bit and the
+		// stream has the offset's lower bits. The offsets with
+		// n bits are in the range [1<<n...(1<<(n+1))-1] so the
+		// decoder adds the implicit 1<<n base to the read bits.
+		// The high nibble is at most 15 so the shift counts are
+		// in the range [17...32]. With 0 bits, the shift is 32:
+		// the shift of a uint32 by 32 gives 0 in Go and the
+		// offset is 1.
+		matchOffset := br.next >> (32 - matchOffsetBitLength)
+		matchOffset += (1 << matchOffsetBitLength)
+		br.consume(int(matchOffsetBitLength))
+		if !truncated {
+			if err := br.refill(); err != nil {
+				return false, err
 			}
 		}
+		if matchOffset > MatchWindowSize {
+			return false, ErrOffsetExceedsWindow
+		}
+		err = d.match(pos, int(matchOffset), matchLength)
+		if err != nil || truncated {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// matchSymbol returns the match length header and the number of
+// offset bits of the match symbol. The standard mapping has the
+// length header in the low nibble of the symbol and the offset bits
+// in the high nibble.
+func (d *decoder) matchSymbol(huffmanSymbol uint16) (int, uint16, error) {
+	if d.matchSymbols == nil {
+		huffmanSymbol -= 256
+		return int(huffmanSymbol % 16), huffmanSymbol / 16, nil
+	}
+	length, offsetBits := d.matchSymbols(int(huffmanSymbol))
+	if length < 0 || length > 15 || offsetBits < 0 || offsetBits > 15 {
+		return 0, 0, fmt.Errorf("%w: match symbol %d: length %d, %d offset bits",
+			ErrInvalidData, huffmanSymbol, length, offsetBits)
 	}
+	return length, uint16(offsetBits), nil
+}
+
+// truncation tests if the error err means that the input ended
+// before the end of the stream.
+func truncation(err error) bool {
+	return errors.Is(err, ErrTruncatedInput)
+}
+
+// huffmanLongLength reads the extended match length bytes and
+// returns the match length minus 3. If the input ends before the
+// length bytes, the function returns the shortest length that the
+// read bytes allow and TruncatedInput. The length is computed as an
+// int: the 16-bit length is the full length minus 3 and the caller's
+// addition of 3 can not wrap. The 16-bit lengths below 15 are
+// invalid.
+func huffmanLongLength(in *input) (int, error) {
+	b, err := in.ReadByte()
+	if err != nil {
+		return 15, err
+	}
+	if b != 255 {
+		return int(b) + 15, nil
+	}
+	l, err := in.ReadUint16()
+	if err != nil {
+		return 255 + 15, err
+	}
+	if l < 15 {
+		return 0, fmt.Errorf("%w: match length %d", ErrInvalidData, l)
+	}
+	return int(l), nil
 }
 
 func DecompressLZ77(data []byte) ([]byte, error) {
-	out := make([]byte, 0, len(data)*3)
+	return AppendDecompressLZ77(nil, data)
+}
+
+// AppendDecompressLZ77 decompresses the LZ77 data and appends the
+// decompressed data to dst. If dst is nil, the output is allocated
+// for the typical expansion of the data.
+func AppendDecompressLZ77(dst, data []byte) ([]byte, error) {
+	if dst == nil {
+		dst = make([]byte, 0, len(data)*3)
+	}
+	d := &decoder{
+		out:   dst,
+		start: len(dst),
+	}
+	err := d.lz77(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+func (d *decoder) lz77(data []byte) error {
+	if len(data) == 0 && !d.partial {
+		// The empty stream is the compression of empty data.
+		return nil
+	}
 	in := &input{
 		input: data,
 	}
+	var st lz77State
+	_, err := d.lz77Tokens(in, &st, 0, 0)
+	return err
+}
+
+// lz77State holds the LZ77 decoding state between the calls of
+// lz77Tokens. The flags are the buffered flag bits and count the
+// number of bits left. The nibble is the pending length nibble, see
+// lz77LongLength.
+type lz77State struct {
+	flags  uint32
+	count  uint
+	nibble int
+}
+
+// lz77Nibble flags the pending shared length nibble byte.
+const lz77Nibble = 0x100
+
+// lz77Tokens decodes the LZ77 tokens from in until the stream ends,
+// the input has less than reserve bytes, or the decoder has produced
+// limit bytes. The limit 0 does not limit the output. The function
+// returns true if the stream ended.
+func (d *decoder) lz77Tokens(in *input, st *lz77State, reserve, limit int) (
+	bool, error) {
+
 	var err error
 
-	var bufferedFlags uint32
-	var bufferedFlagCount uint
-	var lastLengthHalfByte int
+	bufferedFlags := st.flags
+	bufferedFlagCount := st.count
+	defer func() {
+		st.flags = bufferedFlags
+		st.count = bufferedFlagCount
+	}()
 
 	// Loop until break instruction or error
-	for {
+	for !d.done() {
+		if in.Avail() < reserve || limit > 0 && d.produced() >= limit {
+			return false, nil
+		}
+		// The flags are read as a little-endian 32-bit word that
+		// precedes the 32 tokens it describes. The flags are consumed
+		// from the most significant bit to the least significant bit
+		// (MS-XCA 2.4.4): bit 31 of the word describes the first
+		// token. A zero bit is a literal and a one bit is a match.
 		if bufferedFlagCount == 0 {
+			if err = d.check(in); err != nil {
+				return false, err
+			}
 			bufferedFlags, err = in.ReadUint32()
 			if err != nil {
-				return nil, err
+				return false, err
 			}
 			bufferedFlagCount = 32
 		}
 		bufferedFlagCount--
+		pos := in.pos
 		if (bufferedFlags & (1 << bufferedFlagCount)) == 0 {
 			// Copy 1 byte from input to output
 			b, err := in.ReadByte()
 			if err != nil {
-				return nil, err
+				return false, err
+			}
+			err = d.literal(pos, b)
+			if err != nil {
+				return false, err
 			}
-			out = append(out, b)
 		} else {
 			if in.Avail() == 0 {
-				return out, nil
+				return true, nil
 			}
 			matchBytes, err := in.ReadUint16()
 			if err != nil {
-				return nil, err
+				return false, err
 			}
-			matchLength := matchBytes % 8
+			matchLength := int(matchBytes % 8)
 			matchOffset := (matchBytes / 8) + 1
 
+			var truncated bool
 			if matchLength == 7 {
-				if lastLengthHalfByte == 0 {
-					b, err := in.ReadByte()
-					if err != nil {
-						return nil, err
-					}
-					matchLength = uint16(b % 16)
-					lastLengthHalfByte = in.pos - 1
-				} else {
-					b := in.input[lastLengthHalfByte]
-					matchLength = uint16(b / 16)
-					lastLengthHalfByte = 0
-				}
-				if matchLength == 15 {
-					b, err := in.ReadByte()
-					if err != nil {
-						return nil, err
-					}
-					matchLength = uint16(b)
-					if matchLength == 255 {
-						matchLength, err = in.ReadUint16()
-						if err != nil {
-							return nil, err
-						}
-						if matchLength < 15+7 {
-							return nil, errors.New("!=15+7")
-						}
-						matchLength -= (15 + 7)
+				var l uint16
+				l, err = lz77LongLength(in, &st.nibble)
+				matchLength = int(l)
+				if err != nil {
+					if !d.lenient || !truncation(err) {
+						return false, err
 					}
-					matchLength += 15
+					truncated = true
 				}
-				matchLength += 7
 			}
 			matchLength += 3
-			for i := 0; i < int(matchLength); i++ {
-				if int(matchOffset) > len(out) {
-					fmt.Printf("outputPosition=%d, matchOffset=%d\n",
-						len(out), matchOffset)
-					continue
+			err = d.match(pos, int(matchOffset), matchLength)
+			if err != nil || truncated {
+				return truncated, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// lz77LongLength reads the extended match length and returns the
+// match length minus 3. The nibble is the shared length nibble byte
+// or'ed with lz77Nibble, or 0 if there is no pending nibble. If
+// the input ends before the length bytes, the function returns the
+// shortest length that the read bytes allow and
+// ErrTruncatedLengthNibble or TruncatedInput. The nibble is updated
+// only when the nibble byte was read.
+func lz77LongLength(in *input, nibble *int) (uint16, error) {
+	var length uint16
+	if *nibble == 0 {
+		b, err := in.ReadByte()
+		if err != nil {
+			return 7, ErrTruncatedLengthNibble
+		}
+		length = uint16(b % 16)
+		*nibble = lz77Nibble | int(b)
+	} else {
+		length = uint16(*nibble&0xff) / 16
+		*nibble = 0
+	}
+	if length != 15 {
+		return length + 7, nil
+	}
+	b, err := in.ReadByte()
+	if err != nil {
+		return 15 + 7, err
+	}
+	if b != 255 {
+		return uint16(b) + 15 + 7, nil
+	}
+	l, err := in.ReadUint16()
+	if err != nil {
+		return 255 + 15 + 7, err
+	}
+	if l < 15+7 {
+		return 0, fmt.Errorf("%w: match length %d", ErrInvalidData, l)
+	}
+	return l, nil
+}
+
+// lznt1Tokens decodes the tokens of the compressed LZNT1 chunk data
+// that starts from the input offset offset. Each flag byte describes
+// the 8 tokens that follow it, starting from the least significant
+// bit. A zero bit is a literal and a one bit is a 16-bit match token.
+// The matches reference the output of the chunk. The token's split
+// between the match offset and length bits depends on the chunk's
+// output position so that the offset bits can address the complete
+// output of the chunk (MS-XCA 2.5.1.1).
+func (d *decoder) lznt1Tokens(offset int, data []byte) error {
+	in := &input{
+		input: data,
+	}
+	var pos int
+	for in.Avail() > 0 && !d.done() {
+		flags, err := in.ReadByte()
+		if err != nil {
+			return err
+		}
+		for bit := uint(0); bit < 8 && in.Avail() > 0 && !d.done(); bit++ {
+			tokenPos := offset + in.pos
+			if flags&(1<<bit) == 0 {
+				if pos+1 > lznt1ChunkSize {
+					return errLZNT1ChunkSize
+				}
+				b, err := in.ReadByte()
+				if err != nil {
+					return err
+				}
+				err = d.literal(tokenPos, b)
+				if err != nil {
+					return err
 				}
-				b := out[len(out)-int(matchOffset)]
-				out = append(out, b)
+				pos++
+				continue
 			}
+			token, err := in.ReadUint16()
+			if err != nil {
+				return err
+			}
+			lengthBits := lznt1LengthBits(pos)
+			matchLength := int(token&(1<<lengthBits-1)) + 3
+			matchOffset := int(token>>lengthBits) + 1
+			if matchOffset > pos {
+				return ErrInvalidMatchOffset
+			}
+			if pos+matchLength > lznt1ChunkSize {
+				return errLZNT1ChunkSize
+			}
+			err = d.match(tokenPos, matchOffset, matchLength)
+			if err != nil {
+				return err
+			}
+			pos += matchLength
 		}
 	}
+	return nil
 }
 
+var errLZNT1ChunkSize = fmt.Errorf("%w: LZNT1 chunk exceeds %d bytes",
+	ErrInvalidData, lznt1ChunkSize)
+
 func DecompressLZNT1(data []byte) ([]byte, error) {
-	out := make([]byte, 0, len(data))
+	return AppendDecompressLZNT1(nil, data)
+}
+
+// AppendDecompressLZNT1 decompresses the LZNT1 data and appends the
+// decompressed data to dst. If dst is nil, the output is allocated
+// for the size of the data.
+func AppendDecompressLZNT1(dst, data []byte) ([]byte, error) {
+	if dst == nil {
+		dst = make([]byte, 0, len(data))
+	}
+	d := &decoder{
+		out:   dst,
+		start: len(dst),
+	}
+	err := d.lznt1(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// DecompressLZNT1Sized decompresses exactly size bytes of LZNT1
+// data. The function returns ErrChunkOverrun if a chunk would produce
+// more than the remaining output size and TruncatedInput if data ends
+// before size bytes were produced. Any input after the last chunk is
+// ignored.
+func DecompressLZNT1Sized(data []byte, size int) ([]byte, error) {
+	d := &decoder{
+		out:   make([]byte, 0, size),
+		sized: true,
+		size:  size,
+	}
+	err := d.lznt1(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// DecompressLZNT1To decompresses the LZNT1 data and writes the
+// decompressed data to w one chunk at a time. The chunks are
+// independent so the decoder keeps only the current chunk in memory.
+// The function returns the number of bytes written to w and the
+// first error of w.
+func DecompressLZNT1To(w io.Writer, data []byte) (int64, error) {
+	var n int64
+	d := &decoder{
+		window:      newWindow(writerSink(w, &n), 0),
+		flushChunks: true,
+	}
+	err := d.lznt1(data)
+	if err == nil {
+		err = d.flush()
+	}
+	return n, err
+}
+
+// DecompressLZNT1Into decompresses the LZNT1 data into dst and returns
+// the number of bytes written. The function fails with ErrShortBuffer
+// if the output would exceed len(dst). The function never grows dst:
+// the output is written to dst[:n].
+func DecompressLZNT1Into(dst, data []byte) (int, error) {
+	return decompressInto(AlgorithmLZNT1, dst, data)
+}
+
+// DecompressLZ77Into decompresses the plain LZ77 data into dst like
+// DecompressLZNT1Into.
+func DecompressLZ77Into(dst, data []byte) (int, error) {
+	return decompressInto(AlgorithmLZ77, dst, data)
+}
+
+// DecompressLZ77HuffmanInto decompresses the LZ77+Huffman data into
+// dst like DecompressLZNT1Into.
+func DecompressLZ77HuffmanInto(dst, data []byte) (int, error) {
+	return decompressInto(AlgorithmLZ77Huffman, dst, data)
+}
+
+// decompressInto decompresses data with the algorithm algo into dst.
+// The output limit of len(dst) bytes keeps the output in the capacity
+// of dst so the output slice is never reallocated. The limit 0 means
+// no limit so an empty dst is decoded without output and any output
+// is an error.
+func decompressInto(algo Algorithm, dst, data []byte) (int, error) {
+	d := &decoder{
+		out:     dst[:0:len(dst)],
+		maxOut:  len(dst),
+		discard: len(dst) == 0,
+	}
+	err := d.decode(algo, data)
+	if errors.Is(err, ErrOutputTooLarge) || err == nil && d.discarded > 0 {
+		err = ErrShortBuffer
+	}
+	return len(d.out), err
+}
+
+func (d *decoder) lznt1(data []byte) error {
 	in := &input{
 		input: data,
 	}
 
-	for in.Avail() > 0 {
-		hdr, err := in.ReadUint16()
+	for index := 0; in.Avail() > 0 && !lznt1End(in); index++ {
+		if d.done() {
+			return nil
+		}
+		if err := d.check(in); err != nil {
+			return err
+		}
+		chunk, err := lznt1Chunk(in, index)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		format := (hdr >> 12) & 0x7
-		len := int(hdr & 0xfff)
-
-		var compressed bool
+		err = d.chunk(chunk.Offset, chunk.Compressed, chunk.Length)
+		if err != nil {
+			return err
+		}
+		length := chunk.Length
 
-		if (hdr & 0x8000) != 0 {
-			compressed = true
-			if format != 3 {
-				return nil, fmt.Errorf("Invalid compression format %d", format)
+		if chunk.Compressed {
+			if in.Avail() < length {
+				return TruncatedInput
+			}
+			err = d.lznt1Tokens(in.pos, in.input[in.pos:in.pos+length])
+			if err != nil {
+				return err
 			}
+			in.pos += length
 		} else {
-			len += 3
+			if in.Avail() < length {
+				return TruncatedInput
+			}
+			err = d.literals(in.pos, in.input[in.pos:in.pos+length])
+			if err != nil {
+				return err
+			}
+			in.pos += length
 		}
-
-		if compressed {
-			return nil, errors.New("Compressed LZNT1")
-		} else {
-			if in.Avail() < len {
-				return nil, TruncatedInput
+		if d.flushChunks {
+			if err := d.flush(); err != nil {
+				return err
 			}
-			out = append(out, in.input[in.pos:in.pos+len]...)
-			in.pos += len
 		}
 	}
-	return out, nil
+	if d.sized && !d.prefix && !d.done() {
+		return TruncatedInput
+	}
+	return nil
 }
@@ -9,9 +9,12 @@
 package xpress
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"testing"
 )
 
@@ -44,6 +47,48 @@ func TestLZ77(t *testing.T) {
 	}
 }
 
+func TestLZ77InvalidMatchOffset(t *testing.T) {
+	inputs := [][]byte{
+		// A match with offset 5 at the start of the output.
+		{0x00, 0x00, 0x00, 0x80, 0x20, 0x00},
+		// A literal followed by a match with offset 2.
+		{0x00, 0x00, 0x00, 0x40, 'a', 0x08, 0x00},
+	}
+	for i, data := range inputs {
+		_, err := DecompressLZ77(data)
+		if err != ErrInvalidMatchOffset {
+			t.Errorf("input %d: got %v, expected %v",
+				i, err, ErrInvalidMatchOffset)
+		}
+	}
+}
+
+func TestLZ77FlagBitOrder(t *testing.T) {
+	// Flags 0x0000003f: bits 31-6 are 26 literals and bit 5 is the
+	// end-of-input match flag.
+	out, err := DecompressLZ77(lz77Inputs[0])
+	if err != nil {
+		t.Fatalf("DecompressLZ77 failed: %s", err)
+	}
+	if string(out) != "abcdefghijklmnopqrstuvwxyz" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	// Flags 0x3fffffff: two literals followed by a match with offset
+	// 2 and length 3. Reading the flags from the least significant
+	// bit would start with a match and produce different output.
+	data := []byte{
+		0xff, 0xff, 0xff, 0x3f, 'a', 'b', 0x08, 0x00,
+	}
+	out, err = DecompressLZ77(data)
+	if err != nil {
+		t.Fatalf("DecompressLZ77 failed: %s", err)
+	}
+	if string(out) != "ababa" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
 var lz77HuffmanInputs = [][]byte{
 	[]byte{
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
@@ -98,6 +143,448 @@ func TestLZ77Huffman(t *testing.T) {
 	}
 }
 
+func huffmanTable(lengths map[int]int) []byte {
+	table := make([]byte, 256)
+	for sym, l := range lengths {
+		if sym%2 == 0 {
+			table[sym/2] |= byte(l)
+		} else {
+			table[sym/2] |= byte(l << 4)
+		}
+	}
+	return table
+}
+
+// huffmanOffsetStream creates a stream where symbol 256 has code 0
+// and symbol 496 (match length 3, 15 offset bits) has code 1. The
+// stream is a single match with the offset bits followed by the
+// terminator.
+func huffmanOffsetStream(offsetBits uint16) []byte {
+	data := huffmanTable(map[int]int{
+		256: 1,
+		496: 1,
+	})
+	word := 0x8000 | offsetBits
+	return append(data, byte(word), byte(word>>8), 0x00, 0x00, 0x00, 0x00)
+}
+
+func TestLZ77HuffmanOffsetExceedsWindow(t *testing.T) {
+	// Offset bits 0x7fff give offset 0xffff.
+	data := huffmanOffsetStream(0x7fff)
+
+	_, err := DecompressLZ77Huffman(data, make([]byte, 0x10000))
+	if err != ErrOffsetExceedsWindow {
+		t.Errorf("expected ErrOffsetExceedsWindow, got %v", err)
+	}
+	_, err = DecompressLZ77Huffman(data, nil)
+	if err != ErrOffsetExceedsWindow {
+		t.Errorf("expected ErrOffsetExceedsWindow, got %v", err)
+	}
+
+	// Offset bits 0x0000 give offset 0x8000 that is inside the window
+	// but beyond the output.
+	_, err = DecompressLZ77Huffman(huffmanOffsetStream(0x0000), nil)
+	if err != ErrInvalidMatchOffset {
+		t.Errorf("expected ErrInvalidMatchOffset, got %v", err)
+	}
+	out, err := DecompressLZ77Huffman(huffmanOffsetStream(0x0000),
+		make([]byte, 0x8000))
+	if err != nil || len(out) != 0x8003 {
+		t.Errorf("DecompressLZ77Huffman failed: %v", err)
+	}
+}
+
+func TestLZ77HuffmanRandomTokens(t *testing.T) {
+	// A valid table with literal and match symbols followed by random
+	// token bits. The decoder must fail without panicking when a
+	// match references data before the output.
+	data := append(randomBytes(9, 2000), randomBytes(9, 2000)...)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var invalid int
+	for seed := int64(0); seed < 500; seed++ {
+		input := append(append([]byte(nil), compressed[:256]...),
+			randomBytes(seed, 4+int(seed))...)
+		for _, fast := range []bool{true, false} {
+			d := &decoder{
+				fast: fast,
+			}
+			err := d.lz77Huffman(input)
+			if err == ErrInvalidMatchOffset {
+				invalid++
+			}
+		}
+	}
+	if invalid == 0 {
+		t.Errorf("no invalid match offsets in random tokens")
+	}
+}
+
+func TestLZ77HuffmanWindowLimit(t *testing.T) {
+	far := randomBytes(7, MatchWindowSize-500)
+	far = append(far, far[:200]...)
+	tests := []struct {
+		data []byte
+		near bool
+	}{
+		{repeatedMatch(270), false},
+		{randomBytes(8, 2*MatchWindowSize), false},
+		{far, true},
+	}
+	for idx, test := range tests {
+		compressed, err := CompressLZ77Huffman(test.data, nil)
+		if err != nil {
+			t.Fatalf("test %d: compress failed: %s", idx, err)
+		}
+		out, near, err := DecompressLZ77HuffmanWindow(compressed, nil)
+		if err != nil {
+			t.Fatalf("test %d: decompress failed: %s", idx, err)
+		}
+		if !bytes.Equal(out, test.data) {
+			t.Errorf("test %d: output mismatch", idx)
+		}
+		if near != test.near {
+			t.Errorf("test %d: nearWindowLimit=%v, expected %v",
+				idx, near, test.near)
+		}
+	}
+
+	// The longest offset the window allows.
+	_, near, err := DecompressLZ77HuffmanWindow(huffmanOffsetStream(0x0000),
+		make([]byte, MatchWindowSize))
+	if err != nil || !near {
+		t.Errorf("offset %d: nearWindowLimit=%v, err=%v",
+			MatchWindowSize, near, err)
+	}
+}
+
+func TestLZ77HuffmanHistogram(t *testing.T) {
+	// The first symbol 256 is a match with offset 1 and length 3.
+	symbols := []int{'a', 'b', 'a', huffmanEOF, 'c', huffmanEOF}
+	data := huffmanSymbolStream(literalLengths(), symbols)
+
+	out, histogram, err := DecompressLZ77HuffmanHistogram(data, nil)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if string(out) != "abaaaac" {
+		t.Errorf("got %q, expected %q", out, "abaaaac")
+	}
+	var sum int
+	for _, count := range histogram {
+		sum += count
+	}
+	if sum != len(symbols)-1 {
+		t.Errorf("histogram sum %d, expected %d", sum, len(symbols)-1)
+	}
+	expected := map[int]int{'a': 2, 'b': 1, 'c': 1, huffmanEOF: 1}
+	for sym, count := range expected {
+		if histogram[sym] != count {
+			t.Errorf("symbol %d: count %d, expected %d",
+				sym, histogram[sym], count)
+		}
+	}
+}
+
+func TestLZ77HuffmanLongTerminator(t *testing.T) {
+	// Symbols 0...13 have lengths 1...14 and symbols 14 and 256 have
+	// the longest 15-bit codes.
+	var lengths [huffmanSymbols]uint8
+	for i := 0; i < 14; i++ {
+		lengths[i] = uint8(i + 1)
+	}
+	lengths[14] = huffmanMaxLength
+	lengths[huffmanEOF] = huffmanMaxLength
+	symLen := packSymbolLength(&lengths)
+	codes := huffmanCodes(symLen)
+
+	// The symbol 1 and the terminator fill 17 bits of the two
+	// lookahead words so the decoder would refill after the
+	// terminator. The stream has no trailing bytes.
+	bits := uint32(codes[1])<<30 | uint32(codes[huffmanEOF])<<15
+	data := append([]byte{}, symLen...)
+	data = append(data, byte(bits>>16), byte(bits>>24), byte(bits),
+		byte(bits>>8))
+
+	for _, fast := range []bool{true, false} {
+		d := &decoder{
+			fast: fast,
+		}
+		if err := d.lz77Huffman(data); err != nil {
+			t.Fatalf("fast=%v: decode failed: %s", fast, err)
+		}
+		if !bytes.Equal(d.out, []byte{1}) {
+			t.Errorf("fast=%v: got %x, expected 01", fast, d.out)
+		}
+	}
+}
+
+func TestLZ77HuffmanShortTerminator(t *testing.T) {
+	// The terminator has a 1-bit code and symbols 0...13 have lengths
+	// 2...15. The symbol 14 has the longest 15-bit code too.
+	var lengths [huffmanSymbols]uint8
+	for i := 0; i < 14; i++ {
+		lengths[i] = uint8(i + 2)
+	}
+	lengths[14] = huffmanMaxLength
+	lengths[huffmanEOF] = 1
+	symLen := packSymbolLength(&lengths)
+	codes := huffmanCodes(symLen)
+
+	stream := func(bits uint32) []byte {
+		data := append([]byte{}, symLen...)
+		return append(data, byte(bits>>16), byte(bits>>24), byte(bits),
+			byte(bits>>8))
+	}
+
+	// Two 15-bit literals leave 2 valid bits for the terminator. The
+	// decoder can not refill its bit buffer after the second literal.
+	valid := stream(uint32(codes[13])<<17 | uint32(codes[13])<<2 |
+		uint32(codes[huffmanEOF])<<1)
+
+	// The 2 bits after the literals are the prefix of the 3-bit code
+	// of the symbol 1. The code extends to the zero padding.
+	truncated := stream(uint32(codes[13])<<17 | uint32(codes[13])<<2 | 3)
+
+	for _, fast := range []bool{true, false} {
+		d := &decoder{
+			fast: fast,
+		}
+		if err := d.lz77Huffman(valid); err != nil {
+			t.Fatalf("fast=%v: decode failed: %s", fast, err)
+		}
+		if !bytes.Equal(d.out, []byte{13, 13}) {
+			t.Errorf("fast=%v: got %x, expected 0d0d", fast, d.out)
+		}
+		d = &decoder{
+			fast: fast,
+		}
+		if err := d.lz77Huffman(truncated); err != TruncatedInput {
+			t.Errorf("fast=%v: truncated stream: got %v, expected %v",
+				fast, err, TruncatedInput)
+		}
+	}
+
+	out, err := io.ReadAll(NewHuffmanReader(bytes.NewReader(valid)))
+	if err != nil {
+		t.Fatalf("HuffmanReader failed: %s", err)
+	}
+	if !bytes.Equal(out, []byte{13, 13}) {
+		t.Errorf("HuffmanReader: got %x, expected 0d0d", out)
+	}
+	_, err = io.ReadAll(NewHuffmanReader(bytes.NewReader(truncated)))
+	if err != TruncatedInput {
+		t.Errorf("HuffmanReader: truncated stream: got %v, expected %v",
+			err, TruncatedInput)
+	}
+}
+
+func TestLZ77HuffmanOffsetBase(t *testing.T) {
+	// The codes are 00=a, 01=b, 10=c, 110=256, and 111=272 (match
+	// length 3 with 1 offset bit). The bits are a, b, c, 272 with
+	// the offset bit 1, and the terminator: 00 01 10 111 1 110 00.
+	// The offset is (1<<1)+1 = 3.
+	data := huffmanTable(map[int]int{
+		'a': 2,
+		'b': 2,
+		'c': 2,
+		256: 3,
+		272: 3,
+	})
+	data = append(data, 0xf0, 0x1b, 0x00, 0x00)
+
+	out, err := DecompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if string(out) != "abcabc" {
+		t.Errorf("got %q, expected %q", out, "abcabc")
+	}
+}
+
+type memWriterAt struct {
+	buf []byte
+}
+
+func (w *memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	end := int(off) + len(p)
+	for len(w.buf) < end {
+		w.buf = append(w.buf, 0)
+	}
+	copy(w.buf[off:], p)
+	return len(p), nil
+}
+
+func TestLZ77HuffmanToWriterAt(t *testing.T) {
+	var data []byte
+	for i := 0; i < 10; i++ {
+		data = append(data, randomBytes(int64(i), 10000)...)
+		data = append(data, repeatedMatch(20000+i)...)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const base = 100
+	w := new(memWriterAt)
+	n, err := DecompressLZ77HuffmanToWriterAt(compressed, w, base)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if n != len(data) {
+		t.Errorf("wrote %d bytes, expected %d", n, len(data))
+	}
+	if len(w.buf) != base+len(data) || !bytes.Equal(w.buf[base:], data) {
+		t.Errorf("output mismatch")
+	}
+}
+
+func TestLZ77HuffmanEmptyBlock(t *testing.T) {
+	// The terminator has the all-zero code.
+	out, err := DecompressLZ77Huffman(huffmanTable(map[int]int{
+		256: 1,
+		257: 1,
+	}), nil)
+	if err != nil || len(out) != 0 {
+		t.Errorf("empty block: got %x, %v", out, err)
+	}
+
+	// The all-zero code is a literal.
+	_, err = DecompressLZ77Huffman(huffmanTable(map[int]int{
+		'a': 1,
+		256: 1,
+	}), nil)
+	if err != TruncatedInput {
+		t.Errorf("got %v, expected %v", err, TruncatedInput)
+	}
+}
+
+func TestLZ77HuffmanBlocks(t *testing.T) {
+	enc := &Encoder{
+		NoMatches: true,
+	}
+	// The first block has 1-bit codes and the next table follows the
+	// 65536 bits of its literals.
+	const tableOffset = 256 + 2*(2+(huffmanBlockSize-1)/16)
+
+	data := bytes.Repeat([]byte{'a'}, huffmanBlockSize)
+	compressed, err := enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The full block at the end of the stream does not have the
+	// end-of-stream marker.
+	if len(compressed) != tableOffset {
+		t.Errorf("full block: got %d bytes, expected %d",
+			len(compressed), tableOffset)
+	}
+	data = append(data, "bbbb"...)
+	compressed, err = enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var symLen SymbolLength = compressed[tableOffset : tableOffset+256]
+	if symLen.Length('a') != 0 || symLen.Length('b') == 0 {
+		t.Errorf("second block table does not match its literals")
+	}
+
+	inputs := [][]byte{
+		data,
+		data[:huffmanBlockSize],
+		append(randomBytes(7, 3*huffmanBlockSize),
+			repeatedMatch(huffmanBlockSize+100)...),
+	}
+	for i, input := range inputs {
+		for _, enc := range []*Encoder{enc, {}} {
+			compressed, err := enc.CompressLZ77Huffman(input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := DecompressLZ77Huffman(compressed, nil)
+			if err != nil {
+				t.Fatalf("input %d: %s", i, err)
+			}
+			if !bytes.Equal(out, input) {
+				t.Errorf("input %d: output mismatch", i)
+			}
+		}
+	}
+
+	// A truncated table of the next block.
+	_, err = DecompressLZ77Huffman(compressed[:tableOffset+100], nil)
+	if err != TruncatedInput {
+		t.Errorf("got %v, expected %v", err, TruncatedInput)
+	}
+}
+
+func TestLZ77HuffmanEmptyFinalBlock(t *testing.T) {
+	data := bytes.Repeat([]byte{'a'}, huffmanBlockSize)
+	full, err := (&Encoder{NoMatches: true}).CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The empty block without token bytes and the empty block with
+	// the terminator ('a' has code 0 and 256 has code 1).
+	tableOnly := huffmanTable(map[int]int{
+		256: 1,
+		257: 1,
+	})
+	terminator := append(huffmanTable(map[int]int{
+		'a': 1,
+		256: 1,
+	}), 0x00, 0x80, 0x00, 0x00)
+
+	for i, block := range [][]byte{tableOnly, terminator} {
+		stream := append(append([]byte(nil), full...), block...)
+		out, err := DecompressLZ77Huffman(stream, nil)
+		if err != nil {
+			t.Fatalf("block %d: %s", i, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("block %d: got %d bytes, expected %d",
+				i, len(out), len(data))
+		}
+		tokens, err := Tokenize(stream, AlgorithmLZ77Huffman)
+		if err != nil {
+			t.Fatalf("block %d: Tokenize failed: %s", i, err)
+		}
+		if len(tokens) != len(data) {
+			t.Errorf("block %d: got %d tokens, expected %d",
+				i, len(tokens), len(data))
+		}
+		out, err = DecompressWithOptions(AlgorithmLZ77Huffman, stream, nil,
+			&Options{
+				Size: len(data),
+			})
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("block %d: sized decode failed: %v", i, err)
+		}
+	}
+}
+
+func TestLZ77TruncatedLengthNibble(t *testing.T) {
+	// The match token declares an extended length and the input ends
+	// at the nibble byte.
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	e.flag(1)
+	e.putUint16(7)
+	_, err := DecompressLZ77(e.finish())
+	if err != ErrTruncatedLengthNibble {
+		t.Errorf("got %v, expected %v", err, ErrTruncatedLengthNibble)
+	}
+
+	// The failed read does not leave a pending nibble.
+	var nibble int
+	_, err = lz77LongLength(&input{}, &nibble)
+	if err != ErrTruncatedLengthNibble || nibble != 0 {
+		t.Errorf("got %v, nibble %d", err, nibble)
+	}
+}
+
 var lznt1Inputs = [][]byte{
 	[]byte{
 		0x38, 0xb0, 0x88, 0x46, 0x23, 0x20, 0x00, 0x20,
@@ -125,6 +612,256 @@ func testLZNT1(t *testing.T) {
 	}
 }
 
+func TestLZNT1Compressed(t *testing.T) {
+	expected := "F# F# G A A G F# E D D E F# F# E E F# F# G A A G F# E D D " +
+		"E F# E D D E E F# D E F# G F# D E F# G F# E D E A F# F# G A A " +
+		"G F# E D D E F# E D D\x00"
+	out, err := DecompressLZNT1(lznt1Inputs[0])
+	if err != nil {
+		t.Fatalf("DecompressLZNT1 failed: %s", err)
+	}
+	if string(out) != expected {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+
+	// The compressed chunks are decoded after the uncompressed chunks.
+	data := append(lznt1Uncompressed("abcdef"), lznt1Inputs[0]...)
+	data = append(data, lznt1Uncompressed("ghi")...)
+	out, err = DecompressLZNT1(data)
+	if err != nil || string(out) != "abcdef"+expected+"ghi" {
+		t.Errorf("mixed chunks: got %q, %v", out, err)
+	}
+
+	// After 20 literals, the match tokens have 11 length bits.
+	plain := []byte("abcdefghijklmnopqrst")
+	chunk := []byte{0x00}
+	chunk = append(chunk, plain[:8]...)
+	chunk = append(chunk, 0x00)
+	chunk = append(chunk, plain[8:16]...)
+	chunk = append(chunk, 0x10)
+	chunk = append(chunk, plain[16:]...)
+	token := (20-1)<<11 | (25 - 3)
+	chunk = append(chunk, byte(token), byte(token>>8))
+	hdr := 0xb000 | (len(chunk) - 1)
+	out, err = DecompressLZNT1(append([]byte{byte(hdr), byte(hdr >> 8)},
+		chunk...))
+	if err != nil {
+		t.Fatalf("DecompressLZNT1 failed: %s", err)
+	}
+	if string(out) != string(plain)+string(plain)+"abcde" {
+		t.Errorf("got %q", out)
+	}
+
+	// The match references data before the chunk.
+	bad := []byte{0x03, 0xb0, 0x02, 'a', 0x00, 0x10}
+	if _, err := DecompressLZNT1(bad); err != ErrInvalidMatchOffset {
+		t.Errorf("expected ErrInvalidMatchOffset, got %v", err)
+	}
+	truncated := lznt1Inputs[0][:len(lznt1Inputs[0])-1]
+	if _, err := DecompressLZNT1(truncated); err != TruncatedInput {
+		t.Errorf("expected TruncatedInput, got %v", err)
+	}
+}
+
+func lznt1Uncompressed(chunks ...string) []byte {
+	var data []byte
+	for _, chunk := range chunks {
+		hdr := 0x3000 | (len(chunk) - 1)
+		data = append(data, byte(hdr), byte(hdr>>8))
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+func TestLZNT1Sized(t *testing.T) {
+	data := lznt1Uncompressed("abcdef", "ghijkl")
+
+	out, err := DecompressLZNT1Sized(data, 12)
+	if err != nil {
+		t.Fatalf("DecompressLZNT1Sized failed: %s", err)
+	}
+	if string(out) != "abcdefghijkl" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	// The final chunk claims more bytes than the remaining size.
+	_, err = DecompressLZNT1Sized(data, 10)
+	if err != ErrChunkOverrun {
+		t.Errorf("expected ErrChunkOverrun, got %v", err)
+	}
+
+	// Trailing data after the size is ignored.
+	out, err = DecompressLZNT1Sized(data, 6)
+	if err != nil {
+		t.Fatalf("DecompressLZNT1Sized failed: %s", err)
+	}
+	if string(out) != "abcdef" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	_, err = DecompressLZNT1Sized(data, 13)
+	if err != TruncatedInput {
+		t.Errorf("expected TruncatedInput, got %v", err)
+	}
+}
+
+// failingWriter fails the writes after accepting n writes.
+type failingWriter struct {
+	n int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errWriteFailed
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestDecompressInto(t *testing.T) {
+	input := append(randomBytes(44, 3000), repeatedMatch(9000)...)
+	for _, test := range []struct {
+		algo       Algorithm
+		decompress func(dst, data []byte) (int, error)
+	}{
+		{AlgorithmLZNT1, DecompressLZNT1Into},
+		{AlgorithmLZ77, DecompressLZ77Into},
+		{AlgorithmLZ77Huffman, DecompressLZ77HuffmanInto},
+	} {
+		data := input
+		var compressed []byte
+		var err error
+		if test.algo == AlgorithmLZNT1 {
+			compressed = append(lznt1Uncompressed(string(input[:4096])),
+				lznt1Inputs[0]...)
+			data, err = DecompressLZNT1(compressed)
+		} else {
+			compressed, err = compress(test.algo, input)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, size := range []int{len(data), len(data) + 100} {
+			dst := make([]byte, size)
+			n, err := test.decompress(dst, compressed)
+			if err != nil {
+				t.Fatalf("%s: size %d: %s", test.algo, size, err)
+			}
+			if n != len(data) || !bytes.Equal(dst[:n], data) {
+				t.Errorf("%s: size %d: got %d bytes", test.algo, size, n)
+			}
+		}
+
+		// The bytes after the short buffer are not written.
+		buf := bytes.Repeat([]byte{0xaa}, len(data)+10)
+		n, err := test.decompress(buf[:len(data)-1], compressed)
+		if err != ErrShortBuffer {
+			t.Errorf("%s: got %v, expected %v", test.algo, err, ErrShortBuffer)
+		}
+		if n >= len(data) || !bytes.Equal(buf[:n], data[:n]) {
+			t.Errorf("%s: short buffer: %d bytes", test.algo, n)
+		}
+		if !bytes.Equal(buf[len(data)-1:], bytes.Repeat([]byte{0xaa}, 11)) {
+			t.Errorf("%s: bytes written past the buffer", test.algo)
+		}
+
+		n, err = test.decompress(nil, compressed)
+		if n != 0 || err != ErrShortBuffer {
+			t.Errorf("%s: empty buffer: %d, %v", test.algo, n, err)
+		}
+	}
+
+	n, err := DecompressLZ77HuffmanInto(nil, nil)
+	if n != 0 || err != nil {
+		t.Errorf("empty stream: %d, %v", n, err)
+	}
+}
+
+func TestDecompressLZNT1To(t *testing.T) {
+	data, plain := lznt1ManyChunks(10)
+	data = append(data, lznt1Inputs[0]...)
+	expected, err := DecompressLZNT1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := new(segmentWriter)
+	n, err := DecompressLZNT1To(w, data)
+	if err != nil {
+		t.Fatalf("DecompressLZNT1To failed: %s", err)
+	}
+	if n != int64(len(expected)) {
+		t.Errorf("wrote %d bytes, expected %d", n, len(expected))
+	}
+	if len(w.segments) != 11 {
+		t.Errorf("got %d writes, expected one per chunk", len(w.segments))
+	}
+	for i := 0; i < 10 && i < len(w.segments); i++ {
+		if !bytes.Equal(w.segments[i], plain[i*100:(i+1)*100]) {
+			t.Errorf("chunk %d mismatch", i)
+		}
+	}
+	if !bytes.Equal(bytes.Join(w.segments, nil), expected) {
+		t.Errorf("output mismatch")
+	}
+
+	// The writer's error stops the decoding at the failing chunk.
+	n, err = DecompressLZNT1To(&failingWriter{n: 3}, data)
+	if err != errWriteFailed {
+		t.Errorf("got %v, expected %v", err, errWriteFailed)
+	}
+	if n != 300 {
+		t.Errorf("wrote %d bytes, expected 300", n)
+	}
+
+	_, err = DecompressLZNT1To(w, data[:len(data)-1])
+	if err != TruncatedInput {
+		t.Errorf("truncated input: got %v, expected %v", err, TruncatedInput)
+	}
+}
+
+// lznt1ManyChunks returns LZNT1 data with n uncompressed chunks and
+// its decompressed data.
+func lznt1ManyChunks(n int) ([]byte, []byte) {
+	plain := randomBytes(25, n*100)
+	var chunks []string
+	for i := 0; i < n; i++ {
+		chunks = append(chunks, string(plain[i*100:(i+1)*100]))
+	}
+	return lznt1Uncompressed(chunks...), plain
+}
+
+func TestLZNT1ChunkAllocs(t *testing.T) {
+	// The decoder reads all chunks with one input and allocates
+	// only the output buffer.
+	data, plain := lznt1ManyChunks(1000)
+	out, err := DecompressLZNT1(data)
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatalf("DecompressLZNT1 failed: %v", err)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		DecompressLZNT1(data)
+	})
+	if allocs != 1 {
+		t.Errorf("DecompressLZNT1 allocated %v times, expected 1", allocs)
+	}
+}
+
+func BenchmarkLZNT1Chunks(b *testing.B) {
+	data, plain := lznt1ManyChunks(1000)
+	b.SetBytes(int64(len(plain)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecompressLZNT1(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 var xp1 = `ggQRA5eQCAAAkAmQiQAAAAAJAAAFBgAJAACXZ3hoh5gJmXaWcIiZCHmAeIcHaAeYAJCQAFB3RodXmFVWllZVmWkAAAAAAAAAAAAJkAAJAAAAAAAAAJAJAACAAJAAAJCQAJAAkAAACAAAAAAAAAkAAAAACQCQAAAAkAAJkAAAAAAAAAAACZkAAAAAAACQAAAAAAAAAJgAAAAAAAAAlpCAAAAAAJCHCIAAAIAAAJaHkAmYAACAhomQAAAAAIB3B5mIAAAAAIeQCQAAAACQl5kACQAAAIAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAADrkWjhrh6JDM4XIfAvMqTve3Nv3HM3n4c7O52T4uPzd1T7vboF35f/zrPM7fLH4lc89o/+iQ+anKKZCsrc07aazQJBM5m2h59UMK1mi1QtNeGVWc2DNRYxNvlNXEenfEdNd+CmQEKo0tENAebUgZzKnKRJw+wOoKzSMXNH5D0HOmhCawLJnTLuPb3zf1HdS7VlLFNQ4IF81ZmaVaFoOjq9JDal7eL4k2oz51PaLKhjz6wFGRbcX14Z7ix7055yuA10tMx1aj9O8cdSqWWVCPc5BscRQQ6z47ghQqVp2cHCDWqbnKkkKBddkMhk5C+pIilFGuZU3TBPcOARHSk8ARN0AWZzXQi7TdvnqQCL1rog/o5Vk/ghXCkF9voJqr7hAjP0Qexy0MrDDy22o7vUDWsZfwktucxz9IjTIsF7xg7NanVPJuXcIamVHWUqQHHNZ8abudwBvRcDRkmzFptUL9anS3ywFGUFy7og/d9QbRsDOZEDCAssVGYbFkkfZMIuiwq06msmsBKJPcUiUlHCp6BrvqTJMiUVKVTjKhSE1M+TCKUuOWZYWHFjOYzGgOoW4NqoVxEC9xz0WxOfyEn7iGlw8DvSLFVf3ubmJ8Z2utoYO/o/Cmv+LX30+b8E9aHHXJVwDohBYheU16wXhYmhUz3A3TKsaqMrezEiSa+f7I37Bfrgf2lf43VCl0/4/ZgAACAAAA==`
 
 var xp2 = `bgBuAAAAAAAAAAAJAAAAAAAAAAADAAAAWgEAAAAEEAQAP68a6aMMxEGrN6vSD7XEj3ajVjKHR15OtVAwg5iDvvjvu788Uz5QUyBDOlxVc2Vyc1xBZG1pbmlzdHJhdG9yXERvY3VtZW50cyZndDsgPC9TPg==`
@@ -159,3 +896,951 @@ func TestDecompress(t *testing.T) {
 	}
 	fmt.Printf("Decompressed data:\n%s", hex.Dump(bytes))
 }
+
+// huffmanSymbolStream encodes the Huffman symbols with the symbol
+// lengths. The symbols must not contain matches that need extra
+// length bytes or offset bits.
+func huffmanSymbolStream(lengths *[huffmanSymbols]uint8, symbols []int) []byte {
+	symLen := packSymbolLength(lengths)
+	codes := huffmanCodes(symLen)
+	w := newBitWriter(append([]byte{}, symLen...))
+	for _, sym := range symbols {
+		w.writeBits(uint32(codes[sym]), uint(lengths[sym]))
+	}
+	return w.flush()
+}
+
+// literalLengths returns symbol lengths for a literals-only table.
+func literalLengths() *[huffmanSymbols]uint8 {
+	var lengths [huffmanSymbols]uint8
+	for i := 0; i < 255; i++ {
+		lengths[i] = 8
+	}
+	lengths[255] = 9
+	lengths[huffmanEOF] = 9
+	return &lengths
+}
+
+func literalStream(size int) ([]byte, []byte) {
+	plain := randomBytes(42, size)
+	var symbols []int
+	for _, b := range plain {
+		symbols = append(symbols, int(b))
+	}
+	symbols = append(symbols, huffmanEOF)
+	return huffmanSymbolStream(literalLengths(), symbols), plain
+}
+
+func TestDecompressFormat(t *testing.T) {
+	data := append(randomBytes(41, 3000), repeatedMatch(5000)...)
+	lz77, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format   Format
+		data     []byte
+		expected []byte
+	}{
+		{FormatLZ77, lz77, data},
+		{FormatLZ77Huffman, huffman, data},
+		{FormatLZNT1, lznt1Uncompressed("abc", "def"), []byte("abcdef")},
+	}
+	for _, test := range tests {
+		out, err := Decompress(test.format, test.data, []byte("xyz"))
+		if err != nil {
+			t.Fatalf("%s: %s", test.format, err)
+		}
+		if !bytes.Equal(out, append([]byte("xyz"), test.expected...)) {
+			t.Errorf("%s: output mismatch", test.format)
+		}
+	}
+	if _, err := Decompress(Format(42), lz77, nil); err == nil {
+		t.Errorf("unknown format accepted")
+	}
+}
+
+func TestDecompressorFor(t *testing.T) {
+	for _, test := range []struct {
+		format Format
+		vector string
+		dec    Decompressor
+	}{
+		{FormatLZ77, "lz77-repeated", LZ77{}},
+		{FormatLZ77Huffman, "lz77huffman-literals", LZ77Huffman{}},
+		{FormatLZNT1, "lznt1-text", LZNT1{}},
+	} {
+		dec, err := DecompressorFor(test.format)
+		if err != nil {
+			t.Fatalf("%s: DecompressorFor failed: %s", test.format, err)
+		}
+		if dec != test.dec {
+			t.Errorf("%s: got %T, expected %T", test.format, dec, test.dec)
+		}
+		compressed, expected, _ := DecodeKnownVector(test.vector)
+		out, err := dec.Decompress(compressed, []byte(">"))
+		if err != nil {
+			t.Fatalf("%s: Decompress failed: %s", test.format, err)
+		}
+		if !bytes.Equal(out, append([]byte(">"), expected...)) {
+			t.Errorf("%s: got %q", test.format, out)
+		}
+	}
+	if _, err := DecompressorFor(Format(42)); err == nil {
+		t.Errorf("DecompressorFor accepted an unknown format")
+	}
+}
+
+func TestAppendDecompress(t *testing.T) {
+	data := append(randomBytes(45, 3000), repeatedMatch(5000)...)
+	lz77, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lznt1 := lznt1Uncompressed(string(data[:4096]), string(data[4096:4200]))
+
+	tests := []struct {
+		name       string
+		decompress func(dst, data []byte) ([]byte, error)
+		data       []byte
+		expected   []byte
+	}{
+		{"LZ77", AppendDecompressLZ77, lz77, data},
+		{"LZNT1", AppendDecompressLZNT1, lznt1, data[:4200]},
+	}
+	for _, test := range tests {
+		buf := make([]byte, 0, 2*len(test.expected))
+		out, err := test.decompress(append(buf, "xyz"...), test.data)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !bytes.Equal(out, append([]byte("xyz"), test.expected...)) {
+			t.Errorf("%s: output mismatch", test.name)
+		}
+		if &out[0] != &buf[:1][0] {
+			t.Errorf("%s: output buffer not reused", test.name)
+		}
+		allocs := testing.AllocsPerRun(10, func() {
+			test.decompress(buf[:0], test.data)
+		})
+		if allocs > 2 {
+			t.Errorf("%s: %v allocations per call", test.name, allocs)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	data := append(randomBytes(42, 3000), repeatedMatch(5000)...)
+	huffman, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lz77, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oversubscribed := make([]byte, 300)
+	for i := range oversubscribed[:256] {
+		oversubscribed[i] = 0x11
+	}
+	// A compressed LZNT1 chunk with the compression format 1.
+	badFormat := []byte{0x02, 0x90, 0x00, 'a', 'b', 'c'}
+
+	tests := []struct {
+		algo     Algorithm
+		data     []byte
+		expected error
+	}{
+		{AlgorithmLZ77Huffman, huffman[:len(huffman)/2], ErrTruncatedInput},
+		{AlgorithmLZ77Huffman, huffman[:100], ErrTruncatedInput},
+		{AlgorithmLZ77Huffman, make([]byte, 300), ErrInvalidHuffmanTable},
+		{AlgorithmLZ77Huffman, oversubscribed, ErrInvalidHuffmanTable},
+		{AlgorithmLZ77, lz77[:len(lz77)-1], ErrTruncatedInput},
+		{AlgorithmLZNT1, badFormat, ErrInvalidData},
+	}
+	for i, test := range tests {
+		_, err := DecompressWithOptions(test.algo, test.data, nil, nil)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("test %d: got %v, expected %v", i, err, test.expected)
+		}
+	}
+	if !errors.Is(ErrTruncatedLengthNibble, ErrTruncatedInput) {
+		t.Errorf("ErrTruncatedLengthNibble is not ErrTruncatedInput")
+	}
+	if TruncatedInput != ErrTruncatedInput {
+		t.Errorf("TruncatedInput is not ErrTruncatedInput")
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+	data := append(randomBytes(43, 1500), repeatedMatch(2000)...)
+	huffman, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lz77, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lznt1, _ := lznt1ManyChunks(5)
+	lznt1 = append(lznt1, lznt1Inputs[0]...)
+
+	// The prefixes of the valid streams are truncated, or they decode
+	// when they end at a token boundary.
+	for _, test := range []struct {
+		algo Algorithm
+		data []byte
+		min  int
+	}{
+		{AlgorithmLZ77Huffman, huffman, 1},
+		{AlgorithmLZ77, lz77, 0},
+		{AlgorithmLZNT1, lznt1, 0},
+	} {
+		for l := test.min; l < len(test.data); l++ {
+			_, err := DecompressWithOptions(test.algo, test.data[:l], nil, nil)
+			if err != nil && (!truncation(err) || errors.Is(err, ErrCorrupt)) {
+				t.Fatalf("%s: prefix %d: got %v, expected %v", test.algo, l,
+					err, ErrTruncatedInput)
+			}
+		}
+	}
+
+	// A plain LZ77 match before the beginning of the output.
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	e.match(2, 3)
+	badOffset := e.finish()
+	// An LZNT1 chunk whose match references data before the chunk.
+	badChunk := []byte{0x02, 0xb0, 0x01, 0x00, 0x10}
+
+	for i, test := range []struct {
+		algo Algorithm
+		data []byte
+	}{
+		{AlgorithmLZ77Huffman, make([]byte, 300)},
+		{AlgorithmLZ77, badOffset},
+		{AlgorithmLZNT1, []byte{0x02, 0x90, 0x00, 'a', 'b', 'c'}},
+		{AlgorithmLZNT1, badChunk},
+	} {
+		_, err := DecompressWithOptions(test.algo, test.data, nil, nil)
+		if !errors.Is(err, ErrCorrupt) || truncation(err) {
+			t.Errorf("test %d: %s: got %v, expected %v", i, test.algo, err,
+				ErrCorrupt)
+		}
+	}
+
+	for _, err := range []error{
+		ErrInvalidData, ErrInvalidHuffmanTable, ErrHuffmanTableUnderflow,
+		ErrOffsetExceedsWindow, ErrInvalidMatchOffset, ErrChunkOverrun,
+		ErrCrossResetReference, ErrChecksum, ErrNoChecksum, ErrTrailingBits,
+	} {
+		if !errors.Is(err, ErrCorrupt) || truncation(err) {
+			t.Errorf("%v is not a corruption error", err)
+		}
+	}
+	for _, err := range []error{ErrTruncatedLengthNibble, ErrIncomplete} {
+		if !truncation(err) || errors.Is(err, ErrCorrupt) {
+			t.Errorf("%v is not a truncation error", err)
+		}
+	}
+}
+
+func TestLZ77HuffmanLiterals(t *testing.T) {
+	data, plain := literalStream(10000)
+
+	for _, fast := range []bool{true, false} {
+		d := &decoder{
+			fast: fast,
+		}
+		if err := d.lz77Huffman(data); err != nil {
+			t.Fatalf("decode failed: %s", err)
+		}
+		if !bytes.Equal(d.out, plain) {
+			t.Errorf("fast=%v: output mismatch", fast)
+		}
+	}
+
+	// Symbol 256 before the end of input is a match with offset 1 and
+	// length 3.
+	data = huffmanSymbolStream(literalLengths(),
+		[]int{'a', huffmanEOF, 'b', huffmanEOF})
+	for _, fast := range []bool{true, false} {
+		d := &decoder{
+			fast: fast,
+		}
+		if err := d.lz77Huffman(data); err != nil {
+			t.Fatalf("decode failed: %s", err)
+		}
+		if string(d.out) != "aaaab" {
+			t.Errorf("fast=%v: unexpected output %q", fast, d.out)
+		}
+	}
+}
+
+func benchmarkLZ77HuffmanLiterals(b *testing.B, fast bool) {
+	// The stream is a single Huffman block.
+	data, plain := literalStream(huffmanBlockSize - 1)
+	b.SetBytes(int64(len(plain)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := &decoder{
+			out:  make([]byte, 0, len(plain)),
+			fast: fast,
+		}
+		if err := d.lz77Huffman(data); err != nil {
+			b.Fatalf("decode failed: %s", err)
+		}
+	}
+}
+
+func BenchmarkLZ77HuffmanLiterals(b *testing.B) {
+	benchmarkLZ77HuffmanLiterals(b, true)
+}
+
+func BenchmarkLZ77HuffmanLiteralsGeneral(b *testing.B) {
+	benchmarkLZ77HuffmanLiterals(b, false)
+}
+
+// smallHuffmanInputs returns small LZ77+Huffman streams whose tables
+// have codes longer than the primary decoding table bits.
+func smallHuffmanInputs(tb testing.TB) (inputs [][]byte, size int) {
+	enc := &Encoder{
+		NoMatches: true,
+	}
+	for i := 0; i < 16; i++ {
+		// The symbol frequencies halve so the rare symbols have long
+		// codes.
+		var data []byte
+		for k := 0; k < 12; k++ {
+			data = append(data, bytes.Repeat([]byte{byte(i + k)},
+				1<<uint(12-k))...)
+		}
+		compressed, err := enc.CompressLZ77Huffman(data, nil)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		inputs = append(inputs, compressed)
+		if len(data) > size {
+			size = len(data)
+		}
+	}
+	return
+}
+
+func TestLZ77HuffmanTableAllocs(t *testing.T) {
+	// The decoding tables are reused from a pool.
+	inputs, size := smallHuffmanInputs(t)
+	out := make([]byte, 0, size)
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, data := range inputs {
+			DecompressLZ77Huffman(data, out)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("decoding %d inputs allocated %v times", len(inputs), allocs)
+	}
+}
+
+func BenchmarkLZ77HuffmanSmallInputs(b *testing.B) {
+	inputs, size := smallHuffmanInputs(b)
+	out := make([]byte, 0, size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecompressLZ77Huffman(inputs[i%len(inputs)],
+			out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLZ77Large(b *testing.B) {
+	var data []byte
+	for i := 0; len(data) < 4<<20; i++ {
+		data = append(data, randomBytes(int64(i), 1000)...)
+		data = append(data, data[len(data)-500:]...)
+	}
+	compressed, err := CompressLZ77(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	out := make([]byte, 0, len(data))
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := &decoder{
+			out: out,
+		}
+		if err := d.lz77(compressed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMatchCopy(b *testing.B) {
+	prefix := randomBytes(46, MatchWindowSize)
+	for _, bench := range []struct {
+		name   string
+		offset int
+		length int
+	}{
+		{"NonOverlapping", MatchWindowSize, 30000},
+		{"Overlapping", 3, 30000},
+		{"Short", 100, 8},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			out := make([]byte, 0, len(prefix)+1000*bench.length)
+			b.SetBytes(int64(1000 * bench.length))
+			for i := 0; i < b.N; i++ {
+				d := &decoder{
+					out: append(out, prefix...),
+				}
+				for j := 0; j < 1000; j++ {
+					if err := d.match(0, bench.offset, bench.length); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMatchCopyOverlapping(t *testing.T) {
+	prefix := []byte("0123456789")
+	for offset := 1; offset <= len(prefix); offset++ {
+		for _, length := range []int{3, 4, 7, 16, 100, 1000, 70000} {
+			expected := append([]byte{}, prefix...)
+			for i := 0; i < length; i++ {
+				expected = append(expected, expected[len(expected)-offset])
+			}
+
+			d := &decoder{
+				out: append([]byte{}, prefix...),
+			}
+			if err := d.match(0, offset, length); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(d.out, expected) {
+				t.Errorf("offset %d, length %d: output mismatch", offset,
+					length)
+			}
+
+			var out []byte
+			d = &decoder{
+				window: newWindow(func(p []byte) error {
+					out = append(out, p...)
+					return nil
+				}, 0),
+			}
+			if err := d.literals(0, prefix); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.match(0, offset, length); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.window.flush(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, expected) {
+				t.Errorf("offset %d, length %d: window output mismatch",
+					offset, length)
+			}
+		}
+	}
+}
+
+// logLines returns n similar log lines.
+func logLines(n int) []byte {
+	var data []byte
+	for i := 0; i < n; i++ {
+		data = fmt.Appendf(data,
+			"2026-10-14T12:%02d:%02dZ INFO request id=%06d status=200 %s\n",
+			i/60%60, i%60, i, bytes.Repeat([]byte("-"), i%97))
+	}
+	return data
+}
+
+func BenchmarkLZ77HuffmanRepetitive(b *testing.B) {
+	data := logLines(50000)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	out := make([]byte, 0, len(data))
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecompressLZ77Huffman(compressed, out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzDecompress(f *testing.F) {
+	for _, data := range lz77Inputs {
+		f.Add(data)
+	}
+	for _, data := range lz77HuffmanInputs {
+		f.Add(data)
+	}
+	for _, data := range lznt1Inputs {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// The decoders must return an error for malformed data and
+		// never panic.
+		DecompressLZ77(data)
+		DecompressLZ77Huffman(data, nil)
+		DecompressLZNT1(data)
+		DecompressLZNT1Sized(data, 3*len(data))
+		for _, algo := range []Algorithm{AlgorithmLZ77,
+			AlgorithmLZ77Huffman, AlgorithmLZNT1} {
+			DecompressWithOptions(algo, data, nil, &Options{
+				Size:    len(data),
+				Lenient: true,
+			})
+			DecompressToWriter(algo, data, io.Discard, nil)
+		}
+		io.Copy(io.Discard, NewHuffmanReader(bytes.NewReader(data)))
+	})
+}
+
+func TestMatchCapacityBoundary(t *testing.T) {
+	for _, offset := range []int{1, 2, 7, 50, 90} {
+		for _, length := range []int{3, 10, 11, 1000} {
+			prefix := randomBytes(int64(offset), 90)
+			d := &decoder{
+				out: append(make([]byte, 0, 100), prefix...),
+			}
+			if err := d.match(0, offset, length); err != nil {
+				t.Fatalf("match(%d, %d): %s", offset, length, err)
+			}
+			if err := d.literal(0, 'x'); err != nil {
+				t.Fatalf("literal: %s", err)
+			}
+			expected := append([]byte{}, prefix...)
+			for i := 0; i < length; i++ {
+				expected = append(expected, expected[len(expected)-offset])
+			}
+			expected = append(expected, 'x')
+			if !bytes.Equal(d.out, expected) {
+				t.Errorf("match(%d, %d): output mismatch", offset, length)
+			}
+		}
+	}
+}
+
+func TestLZ77MaxMatchLength(t *testing.T) {
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	e.match(1, lz77MaxMatch)
+	data := e.finish()
+
+	out, err := DecompressLZ77(data)
+	if err != nil {
+		t.Fatalf("DecompressLZ77 failed: %s", err)
+	}
+	if !bytes.Equal(out, bytes.Repeat([]byte{'a'}, 1+lz77MaxMatch)) {
+		t.Errorf("got %d bytes, expected %d", len(out), 1+lz77MaxMatch)
+	}
+
+	// The flags, literal, match token, nibble, and length byte
+	// precede the 16-bit length field. One over the maximum wraps
+	// the field to 0 that is shorter than the length ladder.
+	if data[9] != 0xff || data[10] != 0xff {
+		t.Fatalf("unexpected length field %x", data[9:11])
+	}
+	data[9] = 0
+	data[10] = 0
+	if _, err := DecompressLZ77(data); err == nil {
+		t.Errorf("length field 0 accepted")
+	}
+}
+
+func TestLZ77HuffmanMaxMatchLength(t *testing.T) {
+	plain := bytes.Repeat([]byte{'a'}, 1+huffmanMaxMatch)
+	data, err := CompressLZ77Huffman(plain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := Tokenize(data, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatalf("Tokenize failed: %s", err)
+	}
+	if len(tokens) != 2 || tokens[1].Length != huffmanMaxMatch {
+		t.Fatalf("unexpected tokens %v", tokens)
+	}
+	out, err := DecompressLZ77Huffman(data, nil)
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatalf("DecompressLZ77Huffman failed: %v", err)
+	}
+
+	// The length byte 255 is followed by the 16-bit length field.
+	i := bytes.Index(data[256:], []byte{0xff, 0xff, 0xff})
+	if i < 0 {
+		t.Fatalf("length field not found")
+	}
+	i += 256 + 1
+	data[i] = 0
+	data[i+1] = 0
+	if _, err := DecompressLZ77Huffman(data, nil); err == nil {
+		t.Errorf("length field 0 accepted")
+	}
+}
+
+func TestOutputLengthGuard(t *testing.T) {
+	data, err := CompressLZ77Huffman(bytes.Repeat([]byte{'a'}, 100000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lz77, err := CompressLZ77(bytes.Repeat([]byte{'a'}, 100000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := maxOutput
+	defer func() {
+		maxOutput = saved
+	}()
+	maxOutput = 50000
+
+	if _, err := DecompressLZ77Huffman(data, nil); err != ErrOutputTooLarge {
+		t.Errorf("LZ77+Huffman: expected ErrOutputTooLarge, got %v", err)
+	}
+	if _, err := DecompressLZ77(lz77); err != ErrOutputTooLarge {
+		t.Errorf("LZ77: expected ErrOutputTooLarge, got %v", err)
+	}
+	maxOutput = 100000
+	if _, err := DecompressLZ77Huffman(data, nil); err != nil {
+		t.Errorf("output that fills the length failed: %v", err)
+	}
+	// The initial output counts toward the length.
+	_, err = DecompressLZ77Huffman(data, make([]byte, 1))
+	if err != ErrOutputTooLarge {
+		t.Errorf("expected ErrOutputTooLarge, got %v", err)
+	}
+}
+
+func TestLZ77HuffmanChan(t *testing.T) {
+	var data []byte
+	for i := 0; i < 4; i++ {
+		data = append(data, randomBytes(int64(40+i), 30000)...)
+		data = append(data, repeatedMatch(50000+i)...)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunkSize := range []int{0, 1000, 65536} {
+		chunks, errc := DecompressLZ77HuffmanChan(compressed, chunkSize)
+		var out []byte
+		var n int
+		for chunk := range chunks {
+			if chunkSize > 0 && n > 0 && len(out)%chunkSize != 0 {
+				t.Errorf("chunk size %d: short chunk before the last",
+					chunkSize)
+			}
+			out = append(out, chunk...)
+			n++
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("chunk size %d: %s", chunkSize, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("chunk size %d: output mismatch", chunkSize)
+		}
+		if n < 2 {
+			t.Errorf("chunk size %d: got %d chunks", chunkSize, n)
+		}
+	}
+
+	// The data decoded before the error is sent before the error. The
+	// input is truncated inside a Huffman block.
+	chunks, errc := DecompressLZ77HuffmanChan(
+		compressed[:len(compressed)/2+1000], 1000)
+	var out []byte
+	for chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	if err := <-errc; err == nil {
+		t.Errorf("truncated data decoded without errors")
+	}
+	if len(out) == 0 || !bytes.Equal(out, data[:len(out)]) {
+		t.Errorf("got %d bytes before the error", len(out))
+	}
+	if _, ok := <-errc; ok {
+		t.Errorf("error channel not closed")
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	decoders := map[string]func([]byte) ([]byte, error){
+		"LZ77": DecompressLZ77,
+		"LZ77+Huffman": func(data []byte) ([]byte, error) {
+			return DecompressLZ77Huffman(data, nil)
+		},
+		"LZNT1": DecompressLZNT1,
+	}
+	for name, decompress := range decoders {
+		for _, data := range [][]byte{nil, {}} {
+			out, err := decompress(data)
+			if err != nil {
+				t.Errorf("%s: empty input failed: %s", name, err)
+			}
+			if out == nil || len(out) != 0 {
+				t.Errorf("%s: got %v, expected empty output", name, out)
+			}
+		}
+	}
+	for _, algo := range []Algorithm{
+		AlgorithmLZ77, AlgorithmLZ77Huffman, AlgorithmLZNT1,
+	} {
+		out, err := DecompressWithOptions(algo, nil, nil, nil)
+		if err != nil || len(out) != 0 {
+			t.Errorf("%s: got %v, %v, expected empty output", algo, out, err)
+		}
+	}
+}
+
+func TestDecompressLZ77HuffmanToWindow(t *testing.T) {
+	// The overlapping runs have matches with offsets shorter than
+	// their lengths and the repeated blocks have matches near the
+	// maximum offset that the window must resolve after it wraps.
+	var data []byte
+	for i := 0; i < 20; i++ {
+		block := randomBytes(int64(i), MatchWindowSize-100)
+		data = append(data, block...)
+		data = append(data, block[:5000]...)
+		data = append(data, bytes.Repeat([]byte("ab"), 10000+i)...)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := new(segmentWriter)
+	n, err := DecompressLZ77HuffmanTo(w, compressed)
+	if err != nil {
+		t.Fatalf("DecompressLZ77HuffmanTo failed: %s", err)
+	}
+	out := bytes.Join(w.segments, nil)
+	if n != int64(len(data)) || !bytes.Equal(out, data) {
+		t.Fatalf("got %d bytes, expected %d", n, len(data))
+	}
+	for _, segment := range w.segments {
+		if len(segment) > windowSize {
+			t.Fatalf("write of %d bytes exceeds the %d byte window",
+				len(segment), windowSize)
+		}
+	}
+
+	n, err = DecompressLZ77HuffmanTo(&failingWriter{n: 2}, compressed)
+	if err != errWriteFailed {
+		t.Errorf("got %v, expected %v", err, errWriteFailed)
+	}
+	if n >= int64(len(data)) {
+		t.Errorf("failed stream wrote %d bytes", n)
+	}
+	_, err = DecompressLZ77HuffmanTo(new(segmentWriter),
+		compressed[:len(compressed)/2])
+	if err == nil {
+		t.Errorf("truncated input decoded")
+	}
+}
+
+func TestDecompressLZ77HuffmanTable(t *testing.T) {
+	data := append(randomBytes(7, 3*huffmanBlockSize/2),
+		bytes.Repeat([]byte("table"), 30000)...)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := append([]byte{}, compressed[:256]...)
+	bitstream := append([]byte{}, compressed[256:]...)
+
+	out, err := DecompressLZ77HuffmanTable(table, bitstream, []byte(">"))
+	if err != nil {
+		t.Fatalf("DecompressLZ77HuffmanTable failed: %s", err)
+	}
+	if !bytes.Equal(out, append([]byte(">"), data...)) {
+		t.Errorf("output mismatch")
+	}
+
+	_, err = DecompressLZ77HuffmanTable(table[:255], bitstream, nil)
+	if !errors.Is(err, ErrInvalidHuffmanTable) {
+		t.Errorf("short table: got %v, expected %v", err,
+			ErrInvalidHuffmanTable)
+	}
+	_, err = DecompressLZ77HuffmanTable(make([]byte, 256), bitstream, nil)
+	if !errors.Is(err, ErrInvalidHuffmanTable) {
+		t.Errorf("zero table: got %v, expected %v", err,
+			ErrInvalidHuffmanTable)
+	}
+	_, err = DecompressLZ77HuffmanTable(table, bitstream[:len(bitstream)/2],
+		nil)
+	if !errors.Is(err, ErrTruncatedInput) {
+		t.Errorf("truncated bitstream: got %v, expected %v", err,
+			ErrTruncatedInput)
+	}
+}
+
+func TestDecompressLZ77HuffmanStrict(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("a"),
+		[]byte("strict strict strict"),
+		append(randomBytes(8, huffmanBlockSize),
+			bytes.Repeat([]byte("strict"), 20000)...),
+	}
+	for i, data := range inputs {
+		compressed, err := CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := DecompressLZ77HuffmanStrict(compressed, nil, len(data))
+		if err != nil {
+			t.Fatalf("input %d: DecompressLZ77HuffmanStrict failed: %s", i, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("input %d: output mismatch", i)
+		}
+		_, err = DecompressLZ77HuffmanStrict(compressed, nil, len(data)+1)
+		if !errors.Is(err, ErrTruncatedInput) {
+			t.Errorf("input %d: longer length: got %v, expected %v", i, err,
+				ErrTruncatedInput)
+		}
+		_, err = DecompressLZ77HuffmanStrict(compressed, nil, len(data)-1)
+		if err != ErrOutputTooLarge {
+			t.Errorf("input %d: shorter length: got %v, expected %v", i, err,
+				ErrOutputTooLarge)
+		}
+	}
+
+	compressed, expected, _ := DecodeKnownVector("lz77huffman-literals")
+	out, err := DecompressLZ77HuffmanStrict(compressed, nil, len(expected))
+	if err != nil || !bytes.Equal(out, expected) {
+		t.Errorf("known vector: %q, %v", out, err)
+	}
+
+	// A padding bit after the marker is data that the default
+	// decoder ignores.
+	padded := append([]byte{}, compressed...)
+	padded[len(padded)-2] |= 1
+	if out, err := DecompressLZ77Huffman(padded, nil); err != nil ||
+		!bytes.Equal(out, expected) {
+		t.Fatalf("padding bit: %q, %v", out, err)
+	}
+	_, err = DecompressLZ77HuffmanStrict(padded, nil, len(expected))
+	if err != ErrTrailingBits {
+		t.Errorf("padding bit: got %v, expected %v", err, ErrTrailingBits)
+	}
+
+	// The symbol 256 is a match with offset 1 and length 3 inside the
+	// stream. The truncated streams that end at such a match decode to
+	// a prefix of the data as if the match was the marker. The stream
+	// ends with literals so that only its last symbol is the marker.
+	var lengths [huffmanSymbols]uint8
+	lengths['a'] = 1
+	lengths['b'] = 2
+	lengths[huffmanEOF] = 2
+	symbols := []int{'a'}
+	data := []byte{'a'}
+	for i, b := range randomBytes(9, 2000) {
+		sym := []int{'a', 'b', huffmanEOF}[b%3]
+		if i >= 1950 {
+			sym = []int{'a', 'b'}[b%2]
+		}
+		symbols = append(symbols, sym)
+		if sym == huffmanEOF {
+			data = append(data, bytes.Repeat(data[len(data)-1:], 3)...)
+		} else {
+			data = append(data, byte(sym))
+		}
+	}
+	compressed = huffmanSymbolStream(&lengths, append(symbols, huffmanEOF))
+	out, err = DecompressLZ77HuffmanStrict(compressed, nil, len(data))
+	if err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("marker matches: %v", err)
+	}
+	var lucky int
+	for l := 258; l < len(compressed); l += 2 {
+		out, err := DecompressLZ77Huffman(compressed[:l], nil)
+		if err != nil || len(out) == len(data) {
+			continue
+		}
+		lucky++
+		// The bits of the following symbols remain after the
+		// false marker.
+		_, err = DecompressLZ77HuffmanStrict(compressed[:l], nil, len(data))
+		if err != ErrTrailingBits && !errors.Is(err, ErrTruncatedInput) {
+			t.Errorf("prefix %d: got %v, expected %v", l, err,
+				ErrTrailingBits)
+		}
+	}
+	if lucky == 0 {
+		t.Errorf("no truncated stream decoded")
+	}
+}
+
+// huffmanLongMatchStream returns a stream with the literal 'a' and an
+// offset 1 match with the extended length bytes ext.
+func huffmanLongMatchStream(ext ...byte) []byte {
+	var lengths [huffmanSymbols]uint8
+	lengths['a'] = 1
+	lengths[huffmanEOF] = 2
+	lengths[256+15] = 2
+	symLen := packSymbolLength(&lengths)
+	codes := huffmanCodes(symLen)
+	w := newBitWriter(append([]byte{}, symLen...))
+	w.writeBits(uint32(codes['a']), 1)
+	w.writeBits(uint32(codes[256+15]), 2)
+	for _, b := range ext {
+		w.writeByte(b)
+	}
+	w.writeBits(uint32(codes[huffmanEOF]), 2)
+	return w.flush()
+}
+
+func TestLZ77HuffmanLongLengthEscape(t *testing.T) {
+	for _, test := range []struct {
+		ext    []byte
+		length int
+	}{
+		{[]byte{0}, 18},
+		{[]byte{254}, 254 + 15 + 3},
+		{[]byte{255, 15, 0}, 18},
+		{[]byte{255, 0xfe, 0xff}, 65534 + 3},
+		{[]byte{255, 0xff, 0xff}, 65535 + 3},
+	} {
+		out, err := DecompressLZ77Huffman(huffmanLongMatchStream(test.ext...),
+			nil)
+		if err != nil {
+			t.Fatalf("length bytes %x: %s", test.ext, err)
+		}
+		if len(out) != 1+test.length ||
+			!bytes.Equal(out, bytes.Repeat([]byte{'a'}, len(out))) {
+			t.Errorf("length bytes %x: got %d bytes, expected %d", test.ext,
+				len(out), 1+test.length)
+		}
+	}
+
+	// The 16-bit lengths below 15 do not wrap to long matches.
+	for _, l := range []uint16{0, 1, 14} {
+		data := huffmanLongMatchStream(255, byte(l), byte(l>>8))
+		_, err := DecompressLZ77Huffman(data, nil)
+		if !errors.Is(err, ErrInvalidData) {
+			t.Errorf("length %d: got %v, expected %v", l, err, ErrInvalidData)
+		}
+	}
+
+	// The longest match is checked against the output limit.
+	_, err := DecompressWithOptions(AlgorithmLZ77Huffman,
+		huffmanLongMatchStream(255, 0xff, 0xff), nil, &Options{
+			MaxOutput: 65535 + 3,
+		})
+	if err != ErrOutputTooLarge {
+		t.Errorf("got %v, expected %v", err, ErrOutputTooLarge)
+	}
+}
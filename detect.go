@@ -0,0 +1,196 @@
+//
+// detect.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrUnknownFormat is returned if the compression algorithm of the
+// data can not be detected.
+var ErrUnknownFormat = errors.New("Unknown compression format")
+
+// DetectSize specifies how many leading bytes of the input
+// DetectAlgorithm inspects.
+const DetectSize = 64 * 1024
+
+// DetectAlgorithm detects the compression algorithm of data. The
+// function inspects the first DetectSize bytes of data and checks
+// that they decode as a valid prefix of the algorithm's stream. The
+// algorithms are tried in the order LZ77+Huffman, LZNT1, and LZ77. If
+// data is valid for both LZNT1 and LZ77, the function returns the
+// algorithm that matches data more closely. The function returns
+// ErrUnknownFormat if data is not valid for any of the algorithms.
+func DetectAlgorithm(data []byte) (Algorithm, error) {
+	if len(data) > DetectSize {
+		data = data[:DetectSize]
+	}
+	if len(data) == 0 {
+		return 0, ErrUnknownFormat
+	}
+	if detectLZ77Huffman(data) {
+		return AlgorithmLZ77Huffman, nil
+	}
+	// The plain LZ77 flag words can look like LZNT1 chunk headers.
+	// The LZNT1 chunk structure is preferred if data has several
+	// chunks or if it ends at the end of its only chunk.
+	lznt1, chunks, aligned := detectLZNT1(data)
+	if lznt1 && (chunks > 1 || chunks == 1 && aligned) {
+		return AlgorithmLZNT1, nil
+	}
+	if detectLZ77(data) {
+		return AlgorithmLZ77, nil
+	}
+	if lznt1 {
+		return AlgorithmLZNT1, nil
+	}
+	return 0, ErrUnknownFormat
+}
+
+// detectPrefix tests if the decoding error err is valid for a
+// decoded prefix of the stream.
+func detectPrefix(err error) bool {
+	return err == nil || truncation(err) || err == ErrOutputTooLarge
+}
+
+// detectLZ77Huffman tests if data is a valid prefix of an
+// LZ77+Huffman stream. All prefixes shorter than the Huffman table
+// are valid so data must have the whole table.
+func detectLZ77Huffman(data []byte) bool {
+	if len(data) < huffmanSymbols/2 {
+		return false
+	}
+	d := &decoder{
+		discard: true,
+	}
+	return detectPrefix(d.lz77Huffman(data))
+}
+
+// detectLZNT1 tests if data is a valid prefix of an LZNT1 stream. All
+// chunks must have the LZNT1 compression format and the uncompressed
+// chunks must not exceed the chunk size. The uncompressed chunks
+// before the last chunk must have the full chunk size. The function
+// returns the number of complete chunks and tells if data ends at a
+// chunk boundary.
+func detectLZNT1(data []byte) (valid bool, chunks int, aligned bool) {
+	in := &input{
+		input: data,
+	}
+	last := -1
+	for index := 0; in.Avail() > 0; index++ {
+		// The zero header ends the data after the first chunk.
+		if index > 0 && lznt1End(in) {
+			break
+		}
+		chunk, err := lznt1Chunk(in, index)
+		if errors.Is(err, TruncatedInput) {
+			return true, chunks, false
+		}
+		if err != nil {
+			return false, 0, false
+		}
+		if last >= 0 && last != lznt1ChunkSize {
+			return false, 0, false
+		}
+		if chunk.Compressed {
+			last = lznt1ChunkSize
+		} else if chunk.Length > lznt1ChunkSize {
+			return false, 0, false
+		} else {
+			last = chunk.Length
+		}
+		if in.Avail() < chunk.Length {
+			return true, chunks, false
+		}
+		in.pos += chunk.Length
+		chunks++
+	}
+	return true, chunks, true
+}
+
+func detectLZ77(data []byte) bool {
+	// The match references before the beginning of the output are
+	// reported with the resolver.
+	var invalid bool
+	d := &decoder{
+		maxOut: 4 * DetectSize,
+		resolve: func(offset int) byte {
+			invalid = true
+			return 0
+		},
+	}
+	return detectPrefix(d.lz77(data)) && !invalid
+}
+
+// The IsLikelyCompressed heuristic needs at least minEntropySample
+// bytes. The data is compressed if its byte entropy is within
+// entropyMargin bits of the entropy of random data.
+const (
+	minEntropySample = 256
+	entropyMargin    = 0.5
+)
+
+// IsLikelyCompressed tests if data is likely compressed or encrypted
+// data that does not benefit from compression. The function computes
+// the byte entropy of the first DetectSize bytes of data and returns
+// true if it is close to the entropy of random bytes. The function
+// returns false for inputs shorter than 256 bytes.
+func IsLikelyCompressed(data []byte) bool {
+	if len(data) > DetectSize {
+		data = data[:DetectSize]
+	}
+	if len(data) < minEntropySample {
+		return false
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	n := float64(len(data))
+	var entropy float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			entropy -= p * math.Log2(p)
+		}
+	}
+	// The entropy of a sample of random bytes is below 8 bits by the
+	// bias of the sample size.
+	random := 8 - 255/(2*n*math.Ln2)
+	return entropy > random-entropyMargin
+}
+
+// NewAutoReader creates a reader that returns the decompressed data
+// of r if r contains compressed data. The reader detects the
+// algorithm from the first DetectSize bytes of r with
+// DetectAlgorithm. The compressed data is decoded with the streaming
+// decompressor of the algorithm as the reader is read. If the
+// algorithm is not detected, the reader returns the data of r as
+// is.
+func NewAutoReader(r io.Reader) (io.Reader, error) {
+	peek := make([]byte, DetectSize)
+	n, err := io.ReadFull(r, peek)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	peek = peek[:n]
+	stream := io.MultiReader(bytes.NewReader(peek), r)
+
+	algo, err := DetectAlgorithm(peek)
+	if err != nil {
+		return stream, nil
+	}
+	if algo == AlgorithmLZ77Huffman {
+		return NewHuffmanReader(stream), nil
+	}
+	return newStreamReader(stream, algo), nil
+}
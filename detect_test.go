@@ -0,0 +1,219 @@
+//
+// detect_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDetectAlgorithm(t *testing.T) {
+	text := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "),
+		2000)
+
+	lz77, err := CompressLZ77(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(text, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lznt1 := lznt1Uncompressed(string(text[:4096]), string(text[4096:8192]))
+
+	tests := []struct {
+		data     []byte
+		algo     Algorithm
+		expected []byte
+	}{
+		{lz77, AlgorithmLZ77, text},
+		{huffman, AlgorithmLZ77Huffman, text},
+		{lznt1, AlgorithmLZNT1, text[:8192]},
+	}
+	for _, test := range tests {
+		algo, err := DetectAlgorithm(test.data)
+		if err != nil {
+			t.Errorf("%s: %s", test.algo, err)
+		} else if algo != test.algo {
+			t.Errorf("detected %s, expected %s", algo, test.algo)
+		}
+
+		r, err := NewAutoReader(bytes.NewReader(test.data))
+		if err != nil {
+			t.Fatalf("%s: NewAutoReader failed: %s", test.algo, err)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: read failed: %s", test.algo, err)
+		}
+		if !bytes.Equal(out, test.expected) {
+			t.Errorf("%s: output mismatch", test.algo)
+		}
+	}
+
+	// Raw data passes through.
+	for _, raw := range [][]byte{text, randomBytes(15, 3*DetectSize)} {
+		if algo, err := DetectAlgorithm(raw); err != ErrUnknownFormat {
+			t.Errorf("raw data detected as %s", algo)
+		}
+		r, err := NewAutoReader(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("NewAutoReader failed: %s", err)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(out, raw) {
+			t.Errorf("raw pass-through failed: %v", err)
+		}
+	}
+
+	// The short inputs are truncated LZ77+Huffman streams but they
+	// do not have a Huffman table to detect.
+	for _, short := range [][]byte{text[:100], make([]byte, 255)} {
+		if algo, err := DetectAlgorithm(short); err == nil &&
+			algo == AlgorithmLZ77Huffman {
+			t.Errorf("%d bytes detected as %s", len(short), algo)
+		}
+	}
+}
+
+func TestAutoReaderStream(t *testing.T) {
+	data := append(randomBytes(3, 200000), repeatedMatch(100000)...)
+	data = append(data, bytes.Repeat([]byte("lorem ipsum "), 5000)...)
+
+	huffman, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, algo := range []Algorithm{
+		AlgorithmLZ77, AlgorithmLZ77Huffman, AlgorithmLZNT1,
+	} {
+		var compressed []byte
+		switch algo {
+		case AlgorithmLZ77:
+			compressed, err = CompressLZ77(data)
+		case AlgorithmLZ77Huffman:
+			compressed = huffman
+		case AlgorithmLZNT1:
+			compressed, err = CompressLZNT1(data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The reader does not read the stream before it is read.
+		in := bytes.NewReader(compressed)
+		r, err := NewAutoReader(in)
+		if err != nil {
+			t.Fatalf("%s: NewAutoReader failed: %s", algo, err)
+		}
+		var buf [100]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			t.Fatalf("%s: read failed: %s", algo, err)
+		}
+		if in.Len() < len(compressed)/2 {
+			t.Errorf("%s: read %d bytes of %d", algo,
+				len(compressed)-in.Len(), len(compressed))
+		}
+
+		r, err = NewAutoReader(iotest.OneByteReader(
+			bytes.NewReader(compressed)))
+		if err != nil {
+			t.Fatalf("%s: NewAutoReader failed: %s", algo, err)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: read failed: %s", algo, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("%s: output mismatch", algo)
+		}
+
+		// The plain LZ77 stream can end at any match token so only
+		// the other streams detect the truncation.
+		if algo == AlgorithmLZ77 {
+			continue
+		}
+		r, err = NewAutoReader(bytes.NewReader(
+			compressed[:len(compressed)-100]))
+		if err != nil {
+			t.Fatalf("%s: NewAutoReader failed: %s", algo, err)
+		}
+		if _, err := io.ReadAll(r); err != TruncatedInput {
+			t.Errorf("%s: got %v, expected %v", algo, err, TruncatedInput)
+		}
+	}
+}
+
+func TestDetectLZ77AndLZNT1(t *testing.T) {
+	var lz77 [][]byte
+	for i := 0; i < 300; i++ {
+		data := randomBytes(int64(i), 10+i*7)
+		data = append(data, data[:10]...)
+		compressed, err := CompressLZ77(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lz77 = append(lz77, compressed)
+	}
+	for i, data := range lz77 {
+		algo, err := DetectAlgorithm(data)
+		if err != nil || algo != AlgorithmLZ77 {
+			t.Errorf("LZ77 sample %d: detected %s, %v", i, algo, err)
+		}
+	}
+
+	text := bytes.Repeat([]byte("lorem ipsum dolor sit amet "), 1000)
+	full := lznt1Uncompressed(string(text[:4096]), string(text[4096:8192]),
+		string(text[8192:9000]))
+	lznt1 := [][]byte{
+		lznt1Uncompressed(string(text[:4096])),
+		lznt1Uncompressed(string(text[:100])),
+		full,
+		full[:5000],
+		append(lznt1Uncompressed(string(text[:4096])), lznt1Inputs[0]...),
+	}
+	for i, data := range lznt1 {
+		algo, err := DetectAlgorithm(data)
+		if err != nil || algo != AlgorithmLZNT1 {
+			t.Errorf("LZNT1 sample %d: detected %s, %v", i, algo, err)
+		}
+	}
+
+	// The uncompressed chunks before the last chunk have the full
+	// chunk size.
+	short := lznt1Uncompressed(string(text[:100]), string(text[:100]))
+	if algo, err := DetectAlgorithm(short); err == nil &&
+		algo == AlgorithmLZNT1 {
+		t.Errorf("short uncompressed chunks detected as LZNT1")
+	}
+}
+
+func TestIsLikelyCompressed(t *testing.T) {
+	for _, n := range []int{minEntropySample, 300, 1000, 3 * DetectSize} {
+		if !IsLikelyCompressed(randomBytes(int64(n), n)) {
+			t.Errorf("%d random bytes not detected", n)
+		}
+	}
+	text := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "),
+		2000)
+	plain := [][]byte{
+		text[:100],
+		text[:minEntropySample],
+		text,
+		make([]byte, 4096),
+		randomBytes(1, minEntropySample-1),
+	}
+	for i, data := range plain {
+		if IsLikelyCompressed(data) {
+			t.Errorf("plain input %d detected as compressed", i)
+		}
+	}
+}
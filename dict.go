@@ -0,0 +1,209 @@
+//
+// dict.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// dictWindow returns the end of dict that the matches can reach.
+func dictWindow(dict []byte) []byte {
+	return dict[max(0, len(dict)-MatchWindowSize):]
+}
+
+// CompressLZ77HuffmanDict compresses data with the LZ77+Huffman
+// algorithm and the dictionary dict. The matches can reference dict
+// as if it preceded data so the small inputs that share content with
+// dict compress better. Only the last MatchWindowSize bytes of dict
+// are used. The data is decompressed with DecompressLZ77HuffmanDict
+// and the same dictionary.
+func CompressLZ77HuffmanDict(dict, data []byte) ([]byte, error) {
+	dict = dictWindow(dict)
+	buf := make([]byte, 0, len(dict)+len(data))
+	buf = append(buf, dict...)
+	buf = append(buf, data...)
+
+	enc := new(Encoder)
+	window := enc.window(MatchWindowSize)
+	tokens, err := findTokens(enc.matchFinder(window), buf, len(dict),
+		len(buf), window, huffmanMaxMatch, nil)
+	if err != nil {
+		return nil, err
+	}
+	tokens = dropTerminatorMatches(data, tokens)
+	literals := literalTokens(data)
+	if enc.huffmanSize(literals) < enc.huffmanSize(tokens) {
+		tokens = literals
+	}
+	return enc.huffmanEncode(tokens, nil, nil).flush(), nil
+}
+
+// DecompressLZ77HuffmanDict decompresses the LZ77+Huffman data that
+// is compressed with CompressLZ77HuffmanDict and the dictionary dict.
+func DecompressLZ77HuffmanDict(dict, data []byte) ([]byte, error) {
+	dict = dictWindow(dict)
+	out := make([]byte, 0, len(dict)+len(data)*3)
+	d := &decoder{
+		out:   append(out, dict...),
+		start: len(dict),
+	}
+	err := d.lz77Huffman(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.out[len(dict):], nil
+}
+
+// DecompressLZ77Dict decompresses the plain LZ77 data that is
+// compressed with the preset dictionary dict and appends the
+// decompressed data to out. The dictionary precedes out in the match
+// history so the matches that point before the beginning of out
+// resolve against the end of dict. The dictionary is not included in
+// the output.
+func DecompressLZ77Dict(data, dict, out []byte) ([]byte, error) {
+	dict = dict[max(0, len(dict)-lz77MaxOffset):]
+	history := len(dict) + len(out)
+	buf := make([]byte, 0, history+len(data)*3)
+	buf = append(buf, dict...)
+	buf = append(buf, out...)
+	d := &decoder{
+		out:   buf,
+		start: history,
+	}
+	err := d.lz77(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, d.out[history:]...), nil
+}
+
+// The dictionary trainer scores the segments of dictSegmentSize
+// bytes by the dictGram byte n-grams that they cover.
+const (
+	dictGram        = 8
+	dictSegmentSize = 64
+)
+
+// TrainDictionary builds a dictionary of at most maxSize bytes for
+// CompressLZ77HuffmanDict from the sample inputs. The dictionary has
+// the segments of the samples whose 8-byte sequences occur in the
+// most samples. Each selected segment discounts the sequences that it
+// covers from the remaining segments. The best segments are at the
+// end of the dictionary where their match offsets are shortest. The
+// size is limited to MatchWindowSize bytes.
+func TrainDictionary(samples [][]byte, maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("Invalid dictionary size %d", maxSize)
+	}
+	maxSize = min(maxSize, MatchWindowSize)
+
+	// Count the samples that contain each n-gram.
+	freq := make(map[string]int)
+	for _, sample := range samples {
+		seen := make(map[string]bool)
+		for i := 0; i+dictGram <= len(sample); i++ {
+			gram := string(sample[i : i+dictGram])
+			if !seen[gram] {
+				seen[gram] = true
+				freq[gram]++
+			}
+		}
+	}
+
+	// The segments are selected lazily: a segment's score is
+	// recomputed when it reaches the top of the heap and it is
+	// selected if it is still the best.
+	score := func(seg []byte) int {
+		var sum int
+		seen := make(map[string]bool)
+		for i := 0; i+dictGram <= len(seg); i++ {
+			gram := string(seg[i : i+dictGram])
+			if f := freq[gram]; f > 1 && !seen[gram] {
+				seen[gram] = true
+				sum += f
+			}
+		}
+		return sum
+	}
+	h := new(segmentHeap)
+	for _, sample := range samples {
+		for i := 0; i+dictGram <= len(sample); i++ {
+			seg := sample[i:min(len(sample), i+dictSegmentSize)]
+			if s := score(seg); s > 0 {
+				h.segments = append(h.segments, dictSegment{seg, s})
+			}
+		}
+	}
+	heap.Init(h)
+
+	var selected [][]byte
+	var size int
+	for h.Len() > 0 && size < maxSize {
+		top := &h.segments[0]
+		s := score(top.data)
+		if s == 0 {
+			heap.Pop(h)
+			continue
+		}
+		if s < top.score {
+			top.score = s
+			heap.Fix(h, 0)
+			continue
+		}
+		seg := heap.Pop(h).(dictSegment).data
+		seg = seg[:min(len(seg), maxSize-size)]
+		selected = append(selected, seg)
+		size += len(seg)
+		for i := 0; i+dictGram <= len(seg); i++ {
+			delete(freq, string(seg[i:i+dictGram]))
+		}
+	}
+
+	dict := make([]byte, 0, size)
+	for i := len(selected) - 1; i >= 0; i-- {
+		dict = append(dict, selected[i]...)
+	}
+	return dict, nil
+}
+
+// dictSegment is a candidate segment of TrainDictionary.
+type dictSegment struct {
+	data  []byte
+	score int
+}
+
+// segmentHeap implements heap.Interface for the candidate segments,
+// the highest score first.
+type segmentHeap struct {
+	segments []dictSegment
+}
+
+func (h *segmentHeap) Len() int {
+	return len(h.segments)
+}
+
+func (h *segmentHeap) Less(i, j int) bool {
+	return h.segments[i].score > h.segments[j].score
+}
+
+func (h *segmentHeap) Swap(i, j int) {
+	h.segments[i], h.segments[j] = h.segments[j], h.segments[i]
+}
+
+func (h *segmentHeap) Push(x any) {
+	h.segments = append(h.segments, x.(dictSegment))
+}
+
+func (h *segmentHeap) Pop() any {
+	n := len(h.segments) - 1
+	x := h.segments[n]
+	h.segments = h.segments[:n]
+	return x
+}
@@ -0,0 +1,107 @@
+//
+// dict_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// jsonRecords returns n similar JSON records.
+func jsonRecords(seed int64, n int) [][]byte {
+	rnd := rand.New(rand.NewSource(seed))
+	statuses := []string{"active", "suspended", "pending"}
+	var records [][]byte
+	for i := 0; i < n; i++ {
+		records = append(records, []byte(fmt.Sprintf(
+			`{"id":%d,"user":"user%04d","status":"%s","roles":["reader","writer"],`+
+				`"created":"2026-%02d-%02dT%02d:%02d:00Z","quota":%d}`,
+			rnd.Intn(100000), rnd.Intn(10000), statuses[rnd.Intn(3)],
+			1+rnd.Intn(12), 1+rnd.Intn(28), rnd.Intn(24), rnd.Intn(60),
+			rnd.Intn(1<<20))))
+	}
+	return records
+}
+
+func TestTrainDictionary(t *testing.T) {
+	dict, err := TrainDictionary(jsonRecords(1, 200), 1024)
+	if err != nil {
+		t.Fatalf("TrainDictionary failed: %s", err)
+	}
+	if len(dict) == 0 || len(dict) > 1024 {
+		t.Fatalf("dictionary has %d bytes", len(dict))
+	}
+
+	var plain, trained int
+	for i, data := range jsonRecords(2, 50) {
+		compressed, err := CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain += len(compressed)
+
+		compressed, err = CompressLZ77HuffmanDict(dict, data)
+		if err != nil {
+			t.Fatalf("record %d: CompressLZ77HuffmanDict failed: %s", i, err)
+		}
+		trained += len(compressed)
+		out, err := DecompressLZ77HuffmanDict(dict, compressed)
+		if err != nil {
+			t.Fatalf("record %d: DecompressLZ77HuffmanDict failed: %s", i, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("record %d: round trip failed", i)
+		}
+	}
+	if trained >= plain {
+		t.Errorf("dictionary compressed to %d bytes, without dictionary %d",
+			trained, plain)
+	}
+
+	if _, err := TrainDictionary(nil, 0); err == nil {
+		t.Errorf("zero dictionary size accepted")
+	}
+	dict, err = TrainDictionary(nil, 100)
+	if err != nil || len(dict) != 0 {
+		t.Errorf("no samples: %d bytes, %v", len(dict), err)
+	}
+}
+
+func TestDecompressLZ77Dict(t *testing.T) {
+	dict := []byte("preset dictionary: hello, world")
+
+	// The first match references the dictionary and the second one
+	// spans the dictionary and the output prefix.
+	e := newLZ77Encoder(nil)
+	e.match(len("hello, world")+len(">>"), 5)
+	e.literal('!')
+	e.match(len("world")+len(">>")+len("hello!"), 10)
+	data := e.finish()
+
+	out, err := DecompressLZ77Dict(data, dict, []byte(">>"))
+	if err != nil {
+		t.Fatalf("DecompressLZ77Dict failed: %s", err)
+	}
+	expected := ">>hello!world>>hel"
+	if string(out) != expected {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+
+	_, err = DecompressLZ77Dict(data, dict[len(dict)-5:], nil)
+	if err != ErrInvalidMatchOffset {
+		t.Errorf("short dictionary: got %v, expected %v", err,
+			ErrInvalidMatchOffset)
+	}
+	if _, err := DecompressLZ77(data); err != ErrInvalidMatchOffset {
+		t.Errorf("no dictionary: got %v, expected %v", err,
+			ErrInvalidMatchOffset)
+	}
+}
@@ -0,0 +1,375 @@
+//
+// encoder.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"fmt"
+	"time"
+)
+
+// MatchFinder finds LZ77 matches for the compressors. Find returns
+// the offset and length of a match for window[pos:] that starts
+// offset bytes before pos. The length 0 means that the compressor
+// emits a literal. The compressors call Find for increasing
+// positions of the same input, skipping the positions that the
+// matches cover.
+type MatchFinder interface {
+	Find(window []byte, pos int) (offset, length int)
+}
+
+// Effort specifies the compression effort.
+type Effort int
+
+// Compression effort levels.
+const (
+	// EffortDefault builds the Huffman tables with a fast heuristic.
+	EffortDefault Effort = iota
+	// EffortBest builds optimal length-limited Huffman tables with
+	// the package-merge algorithm.
+	EffortBest
+)
+
+// ContentProfile tunes the compressor for a type of input data.
+type ContentProfile int
+
+// Content profiles.
+const (
+	// ProfileGeneric uses the greedy hash-chain match finder.
+	ProfileGeneric ContentProfile = iota
+	// ProfileExecutable tunes the match finder for PE and ELF
+	// binaries. The binaries have many short matches that overlap a
+	// longer match at the next position. The finder searches longer
+	// hash chains and defers a match by one position if the next
+	// position has a longer match.
+	ProfileExecutable
+)
+
+var contentProfileNames = map[ContentProfile]string{
+	ProfileGeneric:    "Generic",
+	ProfileExecutable: "Executable",
+}
+
+func (p ContentProfile) String() string {
+	name, ok := contentProfileNames[p]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{ContentProfile %d}", p)
+}
+
+// executableChainLen is the hash chain length of the
+// ProfileExecutable match finder.
+const executableChainLen = 256
+
+// Encoder implements compressors with optional parameters. The zero
+// Encoder uses the default parameters.
+type Encoder struct {
+	// MatchFinder finds the matches. If MatchFinder is nil, the
+	// compressors use the finder of NewHashChainMatchFinder. The
+	// compressors fail with ErrInvalidMatch if the finder returns an
+	// offset beyond the algorithm's match window.
+	MatchFinder MatchFinder
+
+	// Effort specifies the compression effort. The zero value is
+	// EffortDefault.
+	Effort Effort
+
+	// MaxOffset limits the match offsets. The compressors do not
+	// emit offsets beyond MaxOffset so the streams can be decoded
+	// with a MaxOffset byte window. The value 0 means the algorithm's
+	// maximum offset.
+	MaxOffset int
+
+	// NoMatches disables the matches. The compressors emit only
+	// literals and the LZ77+Huffman output is a pure Huffman coding
+	// of the input. The MatchFinder is not used.
+	NoMatches bool
+
+	// Content tunes the default match finder for the type of the
+	// input data. The Content is not used if MatchFinder is set.
+	Content ContentProfile
+
+	// RecentOffsets makes the default match finder try the recently
+	// used match offsets. A match at a recent offset is used if it
+	// is longer than the match of the hash chains, or as long and
+	// without more offset bits. The recent offsets find the repeated
+	// structures that the hash chain search does not reach. The
+	// RecentOffsets is not used if MatchFinder is set.
+	RecentOffsets bool
+
+	// TimeLimit limits the time that a compressor call spends
+	// searching for matches. When the limit is exceeded, the
+	// compressor encodes the rest of the input with a greedy match
+	// finder that tries only the latest position of each hash
+	// chain. The output is a valid stream but it compresses less.
+	// The limit applies also to the MatchFinder: the compressor
+	// switches from it to the greedy finder. The value 0 means no
+	// limit.
+	TimeLimit time.Duration
+
+	// AppendChecksum appends a checksum trailer to each compressed
+	// stream. The trailer has the CRC-32 checksum of the uncompressed
+	// data and a flag that marks the trailer. The streams with the
+	// trailer are decompressed with Options.VerifyChecksum.
+	AppendChecksum bool
+
+	// Verify decompresses each compressed block and compares it with
+	// the source data. The compressors return a VerifyError if the
+	// block does not decompress to its source data.
+	Verify bool
+
+	// corrupt modifies the compressed block before the verification.
+	// The tests use it for fault injection.
+	corrupt func(block []byte)
+}
+
+// VerifyError describes a compressed block that did not decompress
+// to its source data.
+type VerifyError struct {
+	// Offset is the offset of the block in the input data.
+	Offset int
+	// Pos is the offset of the first differing byte in the block.
+	Pos int
+	// Err is the decompression error or nil if the block decompressed
+	// to different data.
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Verify failed: block at offset %d: %s",
+			e.Offset, e.Err)
+	}
+	return fmt.Sprintf("Verify failed: block at offset %d differs at %d",
+		e.Offset, e.Pos)
+}
+
+// verify checks that the compressed block decompresses to data.
+func (enc *Encoder) verify(algo Algorithm, block, data []byte) error {
+	if enc.corrupt != nil {
+		enc.corrupt(block)
+	}
+	if !enc.Verify {
+		return nil
+	}
+	out, err := DecompressWithOptions(algo, block, nil, nil)
+	if err != nil {
+		return &VerifyError{
+			Err: err,
+		}
+	}
+	var pos int
+	for pos < len(out) && pos < len(data) && out[pos] == data[pos] {
+		pos++
+	}
+	if pos != len(out) || pos != len(data) {
+		return &VerifyError{
+			Pos: pos,
+		}
+	}
+	return nil
+}
+
+// checksum appends the checksum trailer of data to out if the encoder
+// appends checksums.
+func (enc *Encoder) checksum(out, data []byte) []byte {
+	if !enc.AppendChecksum {
+		return out
+	}
+	return appendChecksum(out, data)
+}
+
+// checksumSize returns the size of the encoder's checksum trailer.
+func (enc *Encoder) checksumSize() int {
+	if !enc.AppendChecksum {
+		return 0
+	}
+	return checksumTrailerLen
+}
+
+// window returns the match window size for the algorithm with the
+// maximum offset max.
+func (enc *Encoder) window(max int) int {
+	if enc.MaxOffset > 0 && enc.MaxOffset < max {
+		return enc.MaxOffset
+	}
+	return max
+}
+
+// matchFinder returns the match finder for the algorithm with the
+// match window size window.
+func (enc *Encoder) matchFinder(window int) MatchFinder {
+	if enc.NoMatches {
+		return literalsOnlyFinder{}
+	}
+	f := enc.MatchFinder
+	if f == nil {
+		if enc.Content == ProfileExecutable {
+			f = &lazyMatcher{
+				m:   newMatcher(window, huffmanMaxMatch, executableChainLen),
+				pos: noPosition,
+			}
+		} else {
+			f = newMatcher(window, huffmanMaxMatch, maxChainLen)
+		}
+		if enc.RecentOffsets {
+			f = &recentMatcher{
+				f:      f,
+				window: window,
+			}
+		}
+	}
+	if enc.TimeLimit > 0 {
+		f = &deadlineMatcher{
+			f:        f,
+			window:   window,
+			deadline: time.Now().Add(enc.TimeLimit),
+		}
+	}
+	return f
+}
+
+// huffmanLengths computes the Huffman code lengths for the symbol
+// frequencies according to the compression effort.
+func (enc *Encoder) huffmanLengths(freq []uint64) []uint8 {
+	if enc.Effort >= EffortBest {
+		return huffmanLengthsOptimal(freq, huffmanMaxLength)
+	}
+	return huffmanLengths(freq, huffmanMaxLength)
+}
+
+// literalsOnlyFinder is a MatchFinder that does not find any
+// matches.
+type literalsOnlyFinder struct{}
+
+func (f literalsOnlyFinder) Find(window []byte, pos int) (int, int) {
+	return 0, 0
+}
+
+// lazyMatcher is a MatchFinder that defers a match by one position
+// if the next position has a longer match. The deferred position is
+// emitted as a literal.
+type lazyMatcher struct {
+	m *matcher
+
+	// pos, offset, and length cache the match of the next position.
+	pos    int
+	offset int
+	length int
+}
+
+func (f *lazyMatcher) Find(window []byte, pos int) (int, int) {
+	var offset, length int
+	if pos == f.pos {
+		offset, length = f.offset, f.length
+	} else {
+		offset, length = f.m.Find(window, pos)
+	}
+	f.pos = noPosition
+	if length == 0 || length >= f.m.maxLen || pos+1 >= len(window) {
+		return offset, length
+	}
+	f.offset, f.length = f.m.Find(window, pos+1)
+	f.pos = pos + 1
+	if f.length > length {
+		return 0, 0
+	}
+	return offset, length
+}
+
+// recentOffsets is the number of recently used match offsets that
+// the recentMatcher tries.
+const recentOffsets = 4
+
+// recentMatcher is a MatchFinder that prefers the recently used
+// match offsets over the longest match of its finder.
+type recentMatcher struct {
+	f      MatchFinder
+	window int
+
+	// recent are the recently used offsets, the most recent first.
+	// The value 0 is an unused slot.
+	recent [recentOffsets]int
+}
+
+func (f *recentMatcher) Find(window []byte, pos int) (int, int) {
+	offset, length := f.f.Find(window, pos)
+	if length > 0 && f.recent[0] == offset {
+		return offset, length
+	}
+	maxLen := len(window) - pos
+	if maxLen > huffmanMaxMatch {
+		maxLen = huffmanMaxMatch
+	}
+	var recent, recentLength int
+	for _, r := range f.recent {
+		if r == 0 || r > pos || r > f.window {
+			continue
+		}
+		var l int
+		for l < maxLen && window[pos+l-r] == window[pos+l] {
+			l++
+		}
+		if l > recentLength {
+			recent = r
+			recentLength = l
+		}
+	}
+	if recentLength >= lz77MinMatch && (recentLength > length ||
+		recentLength == length && offsetBits(recent) <= offsetBits(offset)) {
+		offset, length = recent, recentLength
+	}
+	if length >= lz77MinMatch {
+		f.use(offset)
+	}
+	return offset, length
+}
+
+// offsetBits returns the number of offset bits of the LZ77+Huffman
+// match symbol for the offset.
+func offsetBits(offset int) uint {
+	_, bits := huffmanMatchSymbol(offset, huffmanMinMatch)
+	return bits
+}
+
+// use moves the offset to the front of the recent offsets.
+func (f *recentMatcher) use(offset int) {
+	i := 0
+	for i < recentOffsets-1 && f.recent[i] != offset {
+		i++
+	}
+	copy(f.recent[1:i+1], f.recent[:i])
+	f.recent[0] = offset
+}
+
+// deadlineCheckInterval is the number of Find calls between the
+// deadlineMatcher's clock reads.
+const deadlineCheckInterval = 1024
+
+// deadlineMatcher is a MatchFinder that switches from its finder to
+// a greedy single-candidate finder when the deadline passes.
+type deadlineMatcher struct {
+	f        MatchFinder
+	window   int
+	deadline time.Time
+	calls    int
+	expired  bool
+}
+
+func (f *deadlineMatcher) Find(window []byte, pos int) (int, int) {
+	if !f.expired {
+		if f.calls%deadlineCheckInterval == 0 &&
+			time.Now().After(f.deadline) {
+			f.expired = true
+			f.f = newMatcher(f.window, huffmanMaxMatch, 1)
+		}
+		f.calls++
+	}
+	return f.f.Find(window, pos)
+}
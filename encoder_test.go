@@ -0,0 +1,458 @@
+//
+// encoder_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type literalFinder struct{}
+
+func (f literalFinder) Find(window []byte, pos int) (int, int) {
+	return 0, 0
+}
+
+type badFinder struct{}
+
+func (f badFinder) Find(window []byte, pos int) (int, int) {
+	return 1, 3
+}
+
+func TestMatchFinder(t *testing.T) {
+	data := append(randomBytes(5, 1000), repeatedMatch(5000)...)
+
+	finders := []MatchFinder{
+		nil,
+		literalFinder{},
+		NewHashChainMatchFinder(lz77MaxOffset),
+	}
+	for idx, f := range finders {
+		enc := &Encoder{
+			MatchFinder: f,
+		}
+		compressed, err := enc.CompressLZ77(data)
+		if err != nil {
+			t.Fatalf("finder %d: CompressLZ77 failed: %s", idx, err)
+		}
+		out, err := DecompressLZ77(compressed)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("finder %d: LZ77 round trip failed: %v", idx, err)
+		}
+
+		compressed, err = enc.CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatalf("finder %d: CompressLZ77Huffman failed: %s", idx, err)
+		}
+		out, err = DecompressLZ77Huffman(compressed, nil)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("finder %d: LZ77+Huffman round trip failed: %v",
+				idx, err)
+		}
+	}
+
+	enc := &Encoder{
+		MatchFinder: badFinder{},
+	}
+	_, err := enc.CompressLZ77([]byte("abcdef"))
+	if err != ErrInvalidMatch {
+		t.Errorf("got %v, expected %v", err, ErrInvalidMatch)
+	}
+}
+
+func TestEncoderVerify(t *testing.T) {
+	data := append(randomBytes(14, 3000), repeatedMatch(3000)...)
+
+	enc := &Encoder{
+		Verify: true,
+	}
+	if _, err := enc.CompressLZ77(data); err != nil {
+		t.Errorf("CompressLZ77 failed: %s", err)
+	}
+	if _, err := enc.CompressLZ77Huffman(data, nil); err != nil {
+		t.Errorf("CompressLZ77Huffman failed: %s", err)
+	}
+
+	// Corrupt the third block.
+	const blockSize = 1000
+	var blocks int
+	enc.corrupt = func(block []byte) {
+		if blocks == 2 {
+			block[len(block)-3] ^= 0x55
+		}
+		blocks++
+	}
+	_, _, err := enc.CompressLZ77HuffmanWithManifest(data, blockSize)
+	verr, ok := err.(*VerifyError)
+	if !ok {
+		t.Fatalf("got %v, expected VerifyError", err)
+	}
+	if verr.Offset != 2*blockSize {
+		t.Errorf("VerifyError at offset %d, expected %d", verr.Offset,
+			2*blockSize)
+	}
+
+	blocks = 0
+	enc.corrupt = func(block []byte) {
+		block[len(block)/2] ^= 0x55
+	}
+	if _, err := enc.CompressLZ77(data); err == nil {
+		t.Errorf("CompressLZ77 verify did not detect corruption")
+	}
+
+	// The verification is off by default.
+	enc.Verify = false
+	if _, err := enc.CompressLZ77(data); err != nil {
+		t.Errorf("CompressLZ77 failed: %s", err)
+	}
+}
+
+func TestEncoderNoMatches(t *testing.T) {
+	data := append(repeatedMatch(5000), randomBytes(16, 1000)...)
+	enc := &Encoder{
+		NoMatches: true,
+	}
+	compressed, err := enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, histogram, err := DecompressLZ77HuffmanHistogram(compressed, nil)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+	for sym := 256; sym < huffmanSymbols; sym++ {
+		if histogram[sym] != 0 {
+			t.Errorf("match symbol %d decoded %d times", sym, histogram[sym])
+		}
+	}
+	symLen := SymbolLength(compressed[:256])
+	for sym := 257; sym < huffmanSymbols; sym++ {
+		if symLen.Length(sym) != 0 {
+			t.Errorf("match symbol %d has a code", sym)
+		}
+	}
+
+	compressed, err = enc.CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) != lz77Size(literalTokens(data)) {
+		t.Errorf("LZ77 output has matches")
+	}
+	out, err = DecompressLZ77(compressed)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("LZ77 round trip failed: %v", err)
+	}
+}
+
+// decodeWindow decodes the tokens through a circular window of size
+// bytes. The matches read only from the window so the decoding fails
+// if a match reaches beyond it.
+func decodeWindow(tokens []Token, size int) ([]byte, error) {
+	window := make([]byte, size)
+	var out []byte
+	for _, token := range tokens {
+		if token.Length == 0 {
+			window[len(out)%size] = token.Literal
+			out = append(out, token.Literal)
+			continue
+		}
+		if token.Offset > size || token.Offset > len(out) {
+			return nil, fmt.Errorf("offset %d beyond window", token.Offset)
+		}
+		for i := 0; i < token.Length; i++ {
+			b := window[(len(out)-token.Offset)%size]
+			window[len(out)%size] = b
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func TestEncoderMaxOffset(t *testing.T) {
+	// The data repeats at distances of 2000 and 20000 bytes.
+	block := randomBytes(17, 2000)
+	var data []byte
+	for i := 0; i < 10; i++ {
+		data = append(data, block...)
+	}
+	data = append(data, data...)
+
+	for _, maxOffset := range []int{1024, 4096} {
+		enc := &Encoder{
+			MaxOffset: maxOffset,
+		}
+		for algo, compress := range map[Algorithm]func([]byte) (
+			[]byte, error){
+			AlgorithmLZ77: enc.CompressLZ77,
+			AlgorithmLZ77Huffman: func(data []byte) ([]byte, error) {
+				return enc.CompressLZ77Huffman(data, nil)
+			},
+		} {
+			compressed, err := compress(data)
+			if err != nil {
+				t.Fatalf("%s: compress failed: %s", algo, err)
+			}
+			tokens, err := Tokenize(compressed, algo)
+			if err != nil {
+				t.Fatalf("%s: Tokenize failed: %s", algo, err)
+			}
+			var max int
+			for _, token := range tokens {
+				if token.Offset > max {
+					max = token.Offset
+				}
+			}
+			if max > maxOffset {
+				t.Errorf("%s: MaxOffset %d: max offset %d",
+					algo, maxOffset, max)
+			}
+			if maxOffset >= len(block) && max != len(block) {
+				t.Errorf("%s: MaxOffset %d: max offset %d, expected %d",
+					algo, maxOffset, max, len(block))
+			}
+			out, err := decodeWindow(tokens, maxOffset)
+			if err != nil || !bytes.Equal(out, data) {
+				t.Errorf("%s: MaxOffset %d: round trip failed: %v",
+					algo, maxOffset, err)
+			}
+			if max > 1 {
+				if _, err := decodeWindow(tokens, max-1); err == nil {
+					t.Errorf("%s: MaxOffset %d: decoded with %d byte window",
+						algo, maxOffset, max-1)
+				}
+			}
+			size, err := enc.CompressedSize(data, algo)
+			if err != nil || size != len(compressed) {
+				t.Errorf("%s: MaxOffset %d: CompressedSize %d, expected %d",
+					algo, maxOffset, size, len(compressed))
+			}
+		}
+	}
+}
+
+// executableSample generates an executable-like sample of n bytes.
+// The code section has x86-64 functions that are built from a small
+// set of instruction templates with varying registers, immediates,
+// and relative call targets. The data section has a pointer table and
+// a string table.
+func executableSample(seed int64, n int) []byte {
+	rnd := rand.New(rand.NewSource(seed))
+	le32 := func(v uint32) []byte {
+		return binary.LittleEndian.AppendUint32(nil, v)
+	}
+	templates := []func() []byte{
+		// push rbp; mov rbp, rsp; sub rsp, imm8
+		func() []byte {
+			return []byte{0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xec,
+				byte(rnd.Intn(16) * 8)}
+		},
+		// mov reg, imm32
+		func() []byte {
+			return append([]byte{0xb8 + byte(rnd.Intn(8))},
+				le32(uint32(rnd.Intn(4096)))...)
+		},
+		// call rel32
+		func() []byte {
+			return append([]byte{0xe8}, le32(uint32(-rnd.Intn(65536)))...)
+		},
+		// mov reg, [rbp-disp8]
+		func() []byte {
+			return []byte{0x48, 0x8b, 0x45 + byte(rnd.Intn(8))<<3,
+				byte(-8 * (1 + rnd.Intn(8)))}
+		},
+		// test eax, eax; je rel8
+		func() []byte {
+			return []byte{0x85, 0xc0, 0x74, byte(rnd.Intn(64))}
+		},
+		// leave; ret; int3 padding
+		func() []byte {
+			return []byte{0xc9, 0xc3, 0xcc, 0xcc, 0xcc, 0xcc}
+		},
+	}
+	var data []byte
+	for len(data) < n*3/4 {
+		data = append(data, templates[0]()...)
+		for i := 4 + rnd.Intn(20); i > 0; i-- {
+			data = append(data, templates[1+rnd.Intn(4)]()...)
+		}
+		data = append(data, templates[5]()...)
+	}
+	base := uint32(0x401000)
+	for len(data) < n*7/8 {
+		base += uint32(16 * (1 + rnd.Intn(16)))
+		data = append(data, le32(base)...)
+		data = append(data, 0, 0, 0, 0)
+	}
+	names := []string{"init", "read", "write", "close", "alloc", "free"}
+	for len(data) < n {
+		data = append(data, "lib_"+names[rnd.Intn(len(names))]+"_"+
+			names[rnd.Intn(len(names))]...)
+		data = append(data, 0)
+	}
+	return data[:n]
+}
+
+func TestEncoderExecutable(t *testing.T) {
+	data := executableSample(1, 1<<20)
+	enc := &Encoder{
+		Content: ProfileExecutable,
+	}
+	for _, algo := range []Algorithm{AlgorithmLZ77, AlgorithmLZ77Huffman} {
+		generic, err := CompressedSize(data, algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := enc.CompressedSize(data, algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size > generic {
+			t.Errorf("%s: %s profile %d bytes, %s %d bytes", algo,
+				enc.Content, size, ProfileGeneric, generic)
+		}
+	}
+	compressed, err := enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecompressLZ77Huffman(compressed, nil)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("round trip failed: %v", err)
+	}
+}
+
+func TestEncoderRecentOffsets(t *testing.T) {
+	// The rows repeat the previous row with a few changes. The input
+	// has only two symbols so the hash chains are long and the chain
+	// search does not reach the previous row. The recent offset
+	// continues the row matches after the changes.
+	rnd := rand.New(rand.NewSource(1))
+	row := make([]byte, 600)
+	for i := range row {
+		row[i] = "ab"[rnd.Intn(2)]
+	}
+	var data []byte
+	for i := 0; i < 500; i++ {
+		row = append([]byte{}, row...)
+		for j := 0; j < 4; j++ {
+			row[rnd.Intn(len(row))] = "ab"[rnd.Intn(2)]
+		}
+		data = append(data, row...)
+	}
+	enc := &Encoder{
+		RecentOffsets: true,
+	}
+	generic, err := CompressedSize(data, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := enc.CompressedSize(data, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size >= generic {
+		t.Errorf("recent offsets %d bytes, default %d bytes", size, generic)
+	}
+
+	compressed, err := enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecompressLZ77Huffman(compressed, nil)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("LZ77+Huffman round trip failed: %v", err)
+	}
+	compressed, err = enc.CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = DecompressLZ77(compressed)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("LZ77 round trip failed: %v", err)
+	}
+}
+
+func TestEncoderTimeLimit(t *testing.T) {
+	var data []byte
+	for i := 0; i < 10; i++ {
+		data = append(data, randomBytes(int64(i), 20000)...)
+		data = append(data, repeatedMatch(30000+i)...)
+	}
+	enc := &Encoder{
+		TimeLimit: time.Nanosecond,
+	}
+	f := enc.matchFinder(MatchWindowSize)
+	time.Sleep(time.Millisecond)
+	f.Find(data, 0)
+	if !f.(*deadlineMatcher).expired {
+		t.Errorf("deadline did not expire")
+	}
+
+	lz77, err := enc.CompressLZ77(data)
+	if err != nil {
+		t.Fatalf("CompressLZ77 failed: %s", err)
+	}
+	out, err := DecompressLZ77(lz77)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("LZ77 round-trip failed: %v", err)
+	}
+	huffman, err := enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatalf("CompressLZ77Huffman failed: %s", err)
+	}
+	out, err = DecompressLZ77Huffman(huffman, nil)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("LZ77+Huffman round-trip failed: %v", err)
+	}
+	if len(huffman) >= len(data) {
+		t.Errorf("time-limited output %d bytes, input %d", len(huffman),
+			len(data))
+	}
+
+	// The limit applies to the custom match finder.
+	custom := &countingFinder{
+		f: NewHashChainMatchFinder(MatchWindowSize),
+	}
+	enc.MatchFinder = custom
+	f = enc.matchFinder(MatchWindowSize)
+	time.Sleep(time.Millisecond)
+	f.Find(data, 0)
+	if !f.(*deadlineMatcher).expired {
+		t.Errorf("custom finder: deadline did not expire")
+	}
+	custom.calls = 0
+	huffman, err = enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatalf("custom finder: CompressLZ77Huffman failed: %s", err)
+	}
+	out, err = DecompressLZ77Huffman(huffman, nil)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("custom finder: round-trip failed: %v", err)
+	}
+	if custom.calls > deadlineCheckInterval {
+		t.Errorf("custom finder called %d times after the deadline",
+			custom.calls)
+	}
+}
+
+// countingFinder counts the calls of its match finder.
+type countingFinder struct {
+	f     MatchFinder
+	calls int
+}
+
+func (f *countingFinder) Find(window []byte, pos int) (int, int) {
+	f.calls++
+	return f.f.Find(window, pos)
+}
@@ -0,0 +1,87 @@
+//
+// estimate.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+const lznt1ChunkSize = 4096
+
+// EstimateCost estimates the decompression cost of data without
+// decompressing it. The function returns an upper bound for the
+// output size and for the number of decode operations. The
+// operations count one operation for each token and for each output
+// byte.
+//
+// For LZNT1, the estimate is computed from the chunk headers: the
+// uncompressed chunks are exact and the compressed chunks are
+// bounded by the chunk size. For LZ77 and LZ77+Huffman, the function
+// decodes the tokens but does not produce output, so the cost is
+// proportional to the input size and not to the output size.
+func EstimateCost(data []byte, algo Algorithm) (outBytes int, ops int,
+	err error) {
+
+	if algo == AlgorithmLZNT1 {
+		return estimateLZNT1(data)
+	}
+	d := &decoder{
+		discard: true,
+	}
+	err = d.decode(algo, data)
+	if err != nil {
+		return 0, 0, err
+	}
+	return d.discarded, d.tokens + d.discarded, nil
+}
+
+func estimateLZNT1(data []byte) (outBytes int, ops int, err error) {
+	chunks, err := InspectLZNT1(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, chunk := range chunks {
+		length := chunk.Length
+		if chunk.Compressed {
+			length = lznt1ChunkSize
+		}
+		outBytes += length
+
+		// Each token produces at least one byte and the uncompressed
+		// chunks are sequences of literals.
+		ops += 2 * length
+	}
+	return outBytes, ops, nil
+}
+
+// DecompressedSizeLZNT1 returns the decompressed size of the LZNT1
+// data. The sizes of the uncompressed chunks are read from the chunk
+// headers and the tokens of the compressed chunks are decoded without
+// producing output. The function fails with the same errors as
+// DecompressLZNT1.
+func DecompressedSizeLZNT1(data []byte) (int, error) {
+	return decompressedSize(AlgorithmLZNT1, data)
+}
+
+// DecompressedSizeLZ77Huffman returns the decompressed size of the
+// LZ77+Huffman data. The format does not record the output size so
+// the function decodes all tokens of the stream but does not produce
+// the output. The cost is proportional to the input size and most of
+// the decoding work remains. The function fails with the same errors
+// as DecompressLZ77Huffman.
+func DecompressedSizeLZ77Huffman(data []byte) (int, error) {
+	return decompressedSize(AlgorithmLZ77Huffman, data)
+}
+
+func decompressedSize(algo Algorithm, data []byte) (int, error) {
+	d := &decoder{
+		discard: true,
+	}
+	err := d.decode(algo, data)
+	if err != nil {
+		return 0, err
+	}
+	return d.discarded, nil
+}
@@ -0,0 +1,126 @@
+//
+// estimate_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	plain := writerInput()
+	lz77, err := CompressLZ77(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(plain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := []struct {
+		algo Algorithm
+		data []byte
+	}{
+		{AlgorithmLZ77, lz77},
+		{AlgorithmLZ77, lz77Inputs[1]},
+		{AlgorithmLZ77Huffman, huffman},
+		{AlgorithmLZ77Huffman, lz77HuffmanInputs[0]},
+		{AlgorithmLZNT1, lznt1Uncompressed("abcdef", "ghijkl")},
+	}
+	for _, sample := range samples {
+		outBytes, ops, err := EstimateCost(sample.data, sample.algo)
+		if err != nil {
+			t.Fatalf("%s: EstimateCost failed: %s", sample.algo, err)
+		}
+		var trace bytes.Buffer
+		out, err := DecodeTrace(sample.data, sample.algo, &trace)
+		if err != nil {
+			t.Fatalf("%s: DecodeTrace failed: %s", sample.algo, err)
+		}
+		var tokens int
+		for _, line := range strings.Split(trace.String(), "\n") {
+			if strings.Contains(line, "literal") ||
+				strings.Contains(line, "match") {
+				tokens++
+			}
+		}
+		if outBytes < len(out) {
+			t.Errorf("%s: output estimate %d < %d",
+				sample.algo, outBytes, len(out))
+		}
+		if ops < tokens+len(out) {
+			t.Errorf("%s: ops estimate %d < %d",
+				sample.algo, ops, tokens+len(out))
+		}
+	}
+
+	// The LZNT1 estimate bounds compressed chunks by the chunk size.
+	outBytes, _, err := EstimateCost([]byte{0x01, 0xb0, 0x00, 0x61}, AlgorithmLZNT1)
+	if err != nil {
+		t.Fatalf("EstimateCost failed: %s", err)
+	}
+	if outBytes != lznt1ChunkSize {
+		t.Errorf("LZNT1 estimate %d, expected %d", outBytes, lznt1ChunkSize)
+	}
+	if _, _, err := EstimateCost([]byte{0x01}, AlgorithmLZNT1); err == nil {
+		t.Errorf("EstimateCost accepted truncated input")
+	}
+}
+
+func TestDecompressedSize(t *testing.T) {
+	huffman := append([][]byte{}, lz77HuffmanInputs...)
+	data := append(randomBytes(47, 30000), repeatedMatch(100000)...)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman = append(huffman, compressed)
+	for i, data := range huffman {
+		out, err := DecompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := DecompressedSizeLZ77Huffman(data)
+		if err != nil || n != len(out) {
+			t.Errorf("LZ77+Huffman input %d: got %d, %v, expected %d",
+				i, n, err, len(out))
+		}
+	}
+
+	lznt1 := append([][]byte{}, lznt1Inputs...)
+	lznt1 = append(lznt1, lznt1Uncompressed("abc", strings.Repeat("d", 4096)),
+		append(lznt1Uncompressed("abc"), lznt1Inputs[0]...))
+	for i, data := range lznt1 {
+		out, err := DecompressLZNT1(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := DecompressedSizeLZNT1(data)
+		if err != nil || n != len(out) {
+			t.Errorf("LZNT1 input %d: got %d, %v, expected %d",
+				i, n, err, len(out))
+		}
+	}
+
+	// The truncated and corrupt data fail as in the decoders.
+	_, err = DecompressedSizeLZ77Huffman(compressed[:len(compressed)/2])
+	if err != TruncatedInput {
+		t.Errorf("truncated LZ77+Huffman: got %v", err)
+	}
+	_, err = DecompressedSizeLZ77Huffman(make([]byte, 300))
+	if !errors.Is(err, ErrInvalidHuffmanTable) {
+		t.Errorf("invalid Huffman table: got %v", err)
+	}
+	truncated := lznt1Uncompressed("abcdef")
+	if _, err := DecompressedSizeLZNT1(truncated[:5]); err != TruncatedInput {
+		t.Errorf("truncated LZNT1: got %v", err)
+	}
+}
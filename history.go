@@ -0,0 +1,53 @@
+//
+// history.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+// HistoryDecoder decompresses a sequence of packets that share the
+// match window. The matches of a packet can reference the output of
+// the earlier packets, up to MatchWindowSize bytes back.
+type HistoryDecoder struct {
+	algo    Algorithm
+	history []byte
+}
+
+// NewHistoryDecoder creates a new HistoryDecoder for the algorithm
+// algo.
+func NewHistoryDecoder(algo Algorithm) *HistoryDecoder {
+	return &HistoryDecoder{
+		algo: algo,
+	}
+}
+
+// Reset clears the history. The packets after Reset can not
+// reference the output of the packets before it.
+func (h *HistoryDecoder) Reset() {
+	h.history = h.history[:0]
+}
+
+// Decompress decompresses the packet data and returns its output.
+// The returned slice is valid until the next call of Decompress or
+// Reset. If the decompression fails, the history is not modified.
+func (h *HistoryDecoder) Decompress(data []byte) ([]byte, error) {
+	// Drop the history that is no longer reachable by the matches.
+	if len(h.history) > 2*MatchWindowSize {
+		n := copy(h.history, h.history[len(h.history)-MatchWindowSize:])
+		h.history = h.history[:n]
+	}
+	start := len(h.history)
+	d := &decoder{
+		out:   h.history,
+		start: start,
+	}
+	err := d.decode(h.algo, data)
+	if err != nil {
+		return nil, err
+	}
+	h.history = d.out
+	return h.history[start:], nil
+}
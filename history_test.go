@@ -0,0 +1,74 @@
+//
+// history_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHistoryDecoder(t *testing.T) {
+	first, err := CompressLZ77([]byte("hello, world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second packet references the first packet's "world" and
+	// "hello".
+	e := newLZ77Encoder(nil)
+	e.match(5, 5)
+	e.literal(' ')
+	e.match(18, 5)
+	second := e.finish()
+
+	h := NewHistoryDecoder(AlgorithmLZ77)
+	out, err := h.Decompress(first)
+	if err != nil || string(out) != "hello, world" {
+		t.Fatalf("first packet: got %q, %v", out, err)
+	}
+	out, err = h.Decompress(second)
+	if err != nil {
+		t.Fatalf("second packet: %s", err)
+	}
+	if string(out) != "world hello" {
+		t.Errorf("second packet: got %q, expected %q", out, "world hello")
+	}
+}
+
+func TestHistoryDecoderWindow(t *testing.T) {
+	// The match references the first bytes of the packet that
+	// precedes it.
+	ref := huffmanOffsetStream(0x0000)
+	data := randomBytes(3, 3*MatchWindowSize)
+
+	h := NewHistoryDecoder(AlgorithmLZ77Huffman)
+	for i := 0; i < len(data); i += MatchWindowSize {
+		packet, err := CompressLZ77Huffman(data[i:i+MatchWindowSize], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := h.Decompress(packet); err != nil {
+			t.Fatalf("packet %d: %s", i/MatchWindowSize, err)
+		}
+		out, err := h.Decompress(ref)
+		if err != nil {
+			t.Fatalf("reference %d: %s", i/MatchWindowSize, err)
+		}
+		expected := data[i : i+3]
+		if !bytes.Equal(out, expected) {
+			t.Errorf("reference %d: got %x, expected %x",
+				i/MatchWindowSize, out, expected)
+		}
+	}
+
+	h.Reset()
+	if _, err := h.Decompress(ref); err != ErrInvalidMatchOffset {
+		t.Errorf("after Reset: got %v, expected %v", err,
+			ErrInvalidMatchOffset)
+	}
+}
@@ -0,0 +1,741 @@
+//
+// huffman.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	huffmanSymbols   = 512
+	huffmanMaxLength = 15
+	huffmanEOF       = 256
+)
+
+// packSymbolLength packs the symbol lengths into the 256-byte
+// SymbolLength table.
+func packSymbolLength(lengths *[huffmanSymbols]uint8) SymbolLength {
+	sl := make(SymbolLength, huffmanSymbols/2)
+	for sym, l := range lengths {
+		if sym%2 == 0 {
+			sl[sym/2] |= l & 0x0f
+		} else {
+			sl[sym/2] |= l << 4
+		}
+	}
+	return sl
+}
+
+// NewSymbolLength packs the code lengths of the 512 Huffman symbols
+// into a SymbolLength table. The lengths must not exceed 15 bits.
+func NewSymbolLength(lengths []uint8) (SymbolLength, error) {
+	if len(lengths) != huffmanSymbols {
+		return nil, fmt.Errorf("%w: %d symbol lengths",
+			ErrInvalidHuffmanTable, len(lengths))
+	}
+	var packed [huffmanSymbols]uint8
+	for sym, l := range lengths {
+		if l > huffmanMaxLength {
+			return nil, fmt.Errorf("%w: length %d for symbol %d",
+				ErrInvalidHuffmanTable, l, sym)
+		}
+		packed[sym] = l
+	}
+	return packSymbolLength(&packed), nil
+}
+
+// BuildSymbolLength builds the SymbolLength table of the optimal
+// length-limited canonical Huffman code for the symbol frequencies.
+// The codes are at most 15 bits long. The table is complete: if fewer
+// than two symbols are used, a spare symbol gets the other 1-bit
+// code.
+func BuildSymbolLength(freq [huffmanSymbols]uint64) (SymbolLength, error) {
+	sl, err := NewSymbolLength(huffmanLengthsOptimal(freq[:],
+		huffmanMaxLength))
+	if err != nil {
+		return nil, err
+	}
+	if err := sl.Validate(); err != nil {
+		return nil, err
+	}
+	return sl, nil
+}
+
+// Lengths returns the code lengths of the 512 Huffman symbols.
+func (sl SymbolLength) Lengths() []uint8 {
+	lengths := make([]uint8, huffmanSymbols)
+	for sym := range lengths {
+		lengths[sym] = uint8(sl.Length(sym))
+	}
+	return lengths
+}
+
+// KraftSum returns the sum of 2^-length over the symbols with a
+// nonzero code length. The sum of a complete Huffman table is 1.0.
+// The over-subscribed tables sum above 1.0 and the incomplete tables
+// below it.
+func (sl SymbolLength) KraftSum() float64 {
+	var sum float64
+	for sym := 0; sym < 2*len(sl); sym++ {
+		if l := sl.Length(sym); l > 0 {
+			sum += math.Ldexp(1, -l)
+		}
+	}
+	return sum
+}
+
+// Validate checks that the symbol lengths describe a complete prefix
+// code. The table must have the lengths of the 512 symbols and the
+// sum of 2^(15-length) over the used symbols must be 2^15. The
+// canonical code of a complete table is unambiguous. The
+// over-subscribed tables fail with ErrInvalidHuffmanTable and the
+// incomplete tables with ErrHuffmanTableUnderflow.
+func (sl SymbolLength) Validate() error {
+	if len(sl) != huffmanSymbols/2 {
+		return fmt.Errorf("%w: length %d", ErrInvalidHuffmanTable, len(sl))
+	}
+	var sum int
+	for sym := 0; sym < huffmanSymbols; sym++ {
+		if l := sl.Length(sym); l > 0 {
+			sum += 1 << uint(huffmanMaxLength-l)
+		}
+	}
+	if sum > huffmanTableLength {
+		return ErrInvalidHuffmanTable
+	}
+	if sum < huffmanTableLength {
+		return ErrHuffmanTableUnderflow
+	}
+	return nil
+}
+
+// huffmanCodes computes the canonical Huffman codes for the symbol
+// lengths. The codes are assigned in the same order as the
+// decompressor fills its decoding table: by increasing length and by
+// increasing symbol value within a length. The function unpacks the
+// lengths once and assigns the codes in one pass from the first code
+// of each length.
+func huffmanCodes(symLen SymbolLength) (codes [huffmanSymbols]uint16) {
+	var lengths [huffmanSymbols]uint8
+	var counts [huffmanMaxLength + 1]int
+	for sym := range lengths {
+		l := symLen.Length(sym)
+		lengths[sym] = uint8(l)
+		counts[l]++
+	}
+	// The first 15-bit left-aligned code of each length.
+	var next [huffmanMaxLength + 1]int
+	var code int
+	for l := 1; l <= huffmanMaxLength; l++ {
+		next[l] = code
+		code += counts[l] << uint(huffmanMaxLength-l)
+	}
+	for sym, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[sym] = uint16(next[l] >> uint(huffmanMaxLength-l))
+		next[l] += 1 << uint(huffmanMaxLength-l)
+	}
+	return
+}
+
+// bitWriter writes the LZ77+Huffman bitstream. The bits are packed
+// into 16-bit little-endian words, most significant bit first. The
+// decompressor reads two words ahead of the bits it consumes so the
+// raw match length bytes are written after the next two words.
+type bitWriter struct {
+	out   []byte
+	bits  uint32
+	count uint
+	next1 int
+	next2 int
+
+	// sink receives the completed output in blocks of at least
+	// bitWriterBlockSize bytes. The written is the number of bytes
+	// written to sink.
+	sink    io.Writer
+	written int
+	err     error
+}
+
+// bitWriterBlockSize is the minimum size of the blocks that the
+// bitWriter writes to its sink.
+const bitWriterBlockSize = 64 * 1024
+
+func newBitWriter(out []byte) *bitWriter {
+	w := &bitWriter{
+		out:   out,
+		next1: len(out),
+		next2: len(out) + 2,
+	}
+	w.out = append(w.out, 0, 0, 0, 0)
+	return w
+}
+
+// writeBits writes the n low bits of bits. The n must not exceed 16.
+func (w *bitWriter) writeBits(bits uint32, n uint) {
+	w.bits = (w.bits << n) | bits
+	w.count += n
+	if w.count > 16 {
+		w.count -= 16
+		binary.LittleEndian.PutUint16(w.out[w.next1:], uint16(w.bits>>w.count))
+		w.next1 = w.next2
+		w.next2 = len(w.out)
+		w.out = append(w.out, 0, 0)
+		if w.sink != nil && w.next1 >= bitWriterBlockSize {
+			w.drain(w.next1)
+		}
+	}
+}
+
+// drain writes the first n bytes of the output to the sink and
+// removes them from the output. The bytes before next1 are complete.
+// The written counts the bytes that the sink accepted.
+func (w *bitWriter) drain(n int) {
+	if w.err == nil {
+		var m int
+		m, w.err = w.sink.Write(w.out[:n])
+		w.written += m
+	}
+	w.out = w.out[:copy(w.out, w.out[n:])]
+	w.next1 -= n
+	w.next2 -= n
+}
+
+func (w *bitWriter) writeByte(b byte) {
+	w.out = append(w.out, b)
+}
+
+func (w *bitWriter) writeUint16(v uint16) {
+	w.out = append(w.out, byte(v), byte(v>>8))
+}
+
+// block completes the current block and starts a new block with the
+// Huffman table symLen. The decompressor has read the two words ahead
+// of the consumed bits when it reaches the end of the block so the new
+// table follows them.
+func (w *bitWriter) block(symLen SymbolLength) {
+	binary.LittleEndian.PutUint16(w.out[w.next1:],
+		uint16(w.bits<<(16-w.count)))
+	w.out = append(w.out, symLen...)
+	w.bits = 0
+	w.count = 0
+	w.next1 = len(w.out)
+	w.next2 = len(w.out) + 2
+	w.out = append(w.out, 0, 0, 0, 0)
+}
+
+// flush writes the pending bits and returns the encoded
+// stream. After flush, the decompressor has consumed all of its
+// input. If the writer has a sink, flush writes the rest of the
+// stream to the sink.
+func (w *bitWriter) flush() []byte {
+	binary.LittleEndian.PutUint16(w.out[w.next1:],
+		uint16(w.bits<<(16-w.count)))
+	if w.sink != nil {
+		w.drain(len(w.out))
+	}
+	return w.out
+}
+
+// huffmanLengths computes the Huffman code lengths for the symbol
+// frequencies. The lengths are limited to maxLength bits by halving
+// the frequencies until the longest code fits. If fewer than two
+// symbols are used, the function assigns 1-bit codes to the used
+// symbol and to a spare symbol so that the code is complete.
+func huffmanLengths(freq []uint64, maxLength int) []uint8 {
+	lengths := make([]uint8, len(freq))
+
+	var used []int
+	for sym, f := range freq {
+		if f > 0 {
+			used = append(used, sym)
+		}
+	}
+	switch len(used) {
+	case 0:
+		used = append(used, 0)
+		fallthrough
+	case 1:
+		spare := 0
+		if used[0] == 0 {
+			spare = 1
+		}
+		lengths[used[0]] = 1
+		lengths[spare] = 1
+		return lengths
+	}
+
+	scaled := make([]uint64, len(freq))
+	copy(scaled, freq)
+	for {
+		depths, max := huffmanDepths(scaled, used)
+		if max <= maxLength {
+			for i, sym := range used {
+				lengths[sym] = uint8(depths[i])
+			}
+			return lengths
+		}
+		for _, sym := range used {
+			scaled[sym] = (scaled[sym] >> 1) | 1
+		}
+	}
+}
+
+// huffmanDepths builds the Huffman tree for the used symbols and
+// returns their depths in the tree, and the maximum depth.
+func huffmanDepths(freq []uint64, used []int) ([]int, int) {
+	n := len(used)
+	leaves := make([]int, n)
+	for i := range leaves {
+		leaves[i] = i
+	}
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return freq[used[leaves[i]]] < freq[used[leaves[j]]]
+	})
+
+	// Nodes 0...n-1 are the sorted leaves and nodes n...2n-2 are the
+	// internal nodes in the order they are created. Since the internal
+	// node frequencies are non-decreasing, the two smallest nodes are
+	// always at the heads of the leaf and internal node queues.
+	weights := make([]uint64, 2*n-1)
+	parents := make([]int, 2*n-1)
+	for i, leaf := range leaves {
+		weights[i] = freq[used[leaf]]
+	}
+	li, ni := 0, n
+	smallest := func(next int) int {
+		if li < n && (ni >= next || weights[li] <= weights[ni]) {
+			li++
+			return li - 1
+		}
+		ni++
+		return ni - 1
+	}
+	for next := n; next < 2*n-1; next++ {
+		a := smallest(next)
+		b := smallest(next)
+		weights[next] = weights[a] + weights[b]
+		parents[a] = next
+		parents[b] = next
+	}
+
+	nodeDepths := make([]int, 2*n-1)
+	for i := 2*n - 3; i >= 0; i-- {
+		nodeDepths[i] = nodeDepths[parents[i]] + 1
+	}
+	depths := make([]int, n)
+	var max int
+	for i, leaf := range leaves {
+		depths[leaf] = nodeDepths[i]
+		if nodeDepths[i] > max {
+			max = nodeDepths[i]
+		}
+	}
+	return depths, max
+}
+
+// pmItem is an item in the package-merge lists. The leaf is the
+// symbol index of a leaf item and -1 for a package that combines the
+// items left and right of the previous list.
+type pmItem struct {
+	weight uint64
+	leaf   int
+	left   int
+	right  int
+}
+
+// huffmanLengthsOptimal computes the optimal length-limited Huffman
+// code lengths with the package-merge algorithm. The resulting codes
+// minimize the encoded size under the maxLength limit.
+func huffmanLengthsOptimal(freq []uint64, maxLength int) []uint8 {
+	var used []int
+	for sym, f := range freq {
+		if f > 0 {
+			used = append(used, sym)
+		}
+	}
+	if len(used) < 2 {
+		return huffmanLengths(freq, maxLength)
+	}
+	sort.SliceStable(used, func(i, j int) bool {
+		return freq[used[i]] < freq[used[j]]
+	})
+	n := len(used)
+
+	leaves := make([]pmItem, n)
+	for i, sym := range used {
+		leaves[i] = pmItem{
+			weight: freq[sym],
+			leaf:   i,
+		}
+	}
+
+	// Each list merges the leaves with the packages of the pairs of
+	// the previous list.
+	lists := [][]pmItem{leaves}
+	for l := 1; l < maxLength; l++ {
+		prev := lists[len(lists)-1]
+		list := make([]pmItem, 0, n+len(prev)/2)
+		li, pi := 0, 0
+		for li < n || pi+1 < len(prev) {
+			var pkg uint64
+			havePkg := pi+1 < len(prev)
+			if havePkg {
+				pkg = prev[pi].weight + prev[pi+1].weight
+			}
+			if li < n && (!havePkg || leaves[li].weight <= pkg) {
+				list = append(list, leaves[li])
+				li++
+			} else {
+				list = append(list, pmItem{
+					weight: pkg,
+					leaf:   -1,
+					left:   pi,
+					right:  pi + 1,
+				})
+				pi += 2
+			}
+		}
+		lists = append(lists, list)
+	}
+
+	// The code length of a symbol is the number of times its leaf
+	// appears in the first 2n-2 items of the last list.
+	depths := make([]int, n)
+	type ref struct {
+		list int
+		item int
+	}
+	var stack []ref
+	for i := 0; i < 2*n-2; i++ {
+		stack = append(stack, ref{len(lists) - 1, i})
+	}
+	for len(stack) > 0 {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		item := lists[r.list][r.item]
+		if item.leaf >= 0 {
+			depths[item.leaf]++
+			continue
+		}
+		stack = append(stack, ref{r.list - 1, item.left},
+			ref{r.list - 1, item.right})
+	}
+
+	lengths := make([]uint8, len(freq))
+	for i, sym := range used {
+		lengths[sym] = uint8(depths[i])
+	}
+	return lengths
+}
+
+// RepairHuffmanTable completes an under-subscribed Huffman table.
+// The function assigns the shortest lengths that complete the
+// canonical code to the unused symbols, starting from the lowest
+// symbol value. The repair is a heuristic for recovering damaged
+// data: the repaired table decodes the stream but the decoded data
+// is not guaranteed to be correct. A complete table is returned as
+// is, and over-subscribed and empty tables are errors.
+func RepairHuffmanTable(symLen SymbolLength) (SymbolLength, error) {
+	if len(symLen) != huffmanSymbols/2 {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidHuffmanTable,
+			len(symLen))
+	}
+	var lengths [huffmanSymbols]uint8
+	var sum int
+	for sym := range lengths {
+		l := symLen.Length(sym)
+		lengths[sym] = uint8(l)
+		if l > 0 {
+			sum += 1 << uint(huffmanMaxLength-l)
+		}
+	}
+	full := 1 << huffmanMaxLength
+	if sum > full {
+		return nil, fmt.Errorf("%w: over-subscribed", ErrInvalidHuffmanTable)
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidHuffmanTable)
+	}
+
+	// Each set bit of the missing code space is a code of the
+	// corresponding length.
+	missing := full - sum
+	sym := 0
+	for l := 1; l <= huffmanMaxLength; l++ {
+		if missing&(1<<uint(huffmanMaxLength-l)) == 0 {
+			continue
+		}
+		for sym < huffmanSymbols && lengths[sym] != 0 {
+			sym++
+		}
+		if sym >= huffmanSymbols {
+			return nil, fmt.Errorf("%w: no unused symbols",
+				ErrInvalidHuffmanTable)
+		}
+		lengths[sym] = uint8(l)
+	}
+	return packSymbolLength(&lengths), nil
+}
+
+// huffmanPrimaryBits is the number of code bits that the primary
+// level of the decoding table resolves.
+const huffmanPrimaryBits = 10
+
+// Decoding table entries. A symbol entry holds the symbol in its low
+// 16 bits and the code length above them. A link entry holds the
+// index of the secondary table in its low 16 bits and the number of
+// the secondary table's index bits above them.
+const (
+	huffmanEntryLink  = 1 << 31
+	huffmanEntryShift = 16
+	huffmanEntryMask  = 0xffff
+)
+
+// decodingTable is a two-level Huffman decoding table. The primary
+// table is indexed with the next huffmanPrimaryBits bits of the
+// input. The codes that are not longer than that are resolved with
+// the primary table. For the longer codes, the primary entry links
+// to a secondary table that is indexed with the following bits. The
+// secondary table of a prefix is sized for the longest code with the
+// prefix.
+type decodingTable struct {
+	primary   [1 << huffmanPrimaryBits]uint32
+	secondary []uint32
+}
+
+// huffmanDecoders pools the Huffman decoders of the LZ77+Huffman
+// decompressors. The init reuses the table's secondary storage.
+var huffmanDecoders = sync.Pool{
+	New: func() interface{} {
+		return new(HuffmanDecoder)
+	},
+}
+
+// putHuffmanDecoder returns the decoder to the huffmanDecoders pool.
+// The decoder's symbol lengths point into the caller's compressed
+// data and they are cleared so that the pool does not retain the
+// data.
+func putHuffmanDecoder(hd *HuffmanDecoder) {
+	hd.symLen = nil
+	huffmanDecoders.Put(hd)
+}
+
+// HuffmanDecoder decodes the bitstream of an LZ77+Huffman block with
+// the block's Huffman table. The decoder separates the table from
+// the bitstream for the streams that keep them apart.
+type HuffmanDecoder struct {
+	symLen SymbolLength
+	table  decodingTable
+}
+
+// NewHuffmanDecoder creates a new HuffmanDecoder for the 256-byte
+// SymbolLength table tableBytes. The function returns an error if the
+// table is not a complete Huffman code.
+func NewHuffmanDecoder(tableBytes []byte) (*HuffmanDecoder, error) {
+	if len(tableBytes) != huffmanSymbols/2 {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidHuffmanTable,
+			len(tableBytes))
+	}
+	hd := new(HuffmanDecoder)
+	if err := hd.init(append(SymbolLength{}, tableBytes...)); err != nil {
+		return nil, err
+	}
+	return hd, nil
+}
+
+// init initializes the decoder for the symbol lengths symLen.
+func (hd *HuffmanDecoder) init(symLen SymbolLength) error {
+	hd.symLen = symLen
+	return hd.table.init(symLen)
+}
+
+// SymbolLength returns the decoder's symbol lengths.
+func (hd *HuffmanDecoder) SymbolLength() SymbolLength {
+	return hd.symLen
+}
+
+// Decode decodes the bitstream of one LZ77+Huffman block and appends
+// the decompressed data to out. The data starts with the first bits
+// of the block, after the block's Huffman table. The block ends at the
+// end-of-stream marker or when its output reaches the block size of
+// 64KB. The data after a full block belongs to the next block and it
+// is not decoded. The function returns the decompressed data and the
+// number of bytes of data that the block consumed. After a full
+// block, the next block's Huffman table starts at that position.
+func (hd *HuffmanDecoder) Decode(data, out []byte) ([]byte, int, error) {
+	d := &decoder{
+		out:   out,
+		start: len(out),
+		fast:  true,
+	}
+	in := &input{
+		input: data,
+	}
+	_, err := d.huffmanBits(in, hd)
+	if err != nil {
+		return nil, 0, err
+	}
+	return d.out, in.pos, nil
+}
+
+// init initializes the decoding table from the symbol lengths. The
+// symbols are assigned canonical codes by increasing length and by
+// increasing symbol value within a length. The lengths must form a
+// complete code.
+func (t *decodingTable) init(symLen SymbolLength) error {
+	const secondaryBits = huffmanMaxLength - huffmanPrimaryBits
+
+	if err := symLen.Validate(); err != nil {
+		return err
+	}
+	var lengths [huffmanSymbols]uint8
+	var counts [huffmanMaxLength + 1]int
+	for sym := range lengths {
+		l := symLen.Length(sym)
+		lengths[sym] = uint8(l)
+		counts[l]++
+	}
+
+	// The first 15-bit left-aligned code of each length.
+	var first [huffmanMaxLength + 1]int
+	var next int
+	for l := 1; l <= huffmanMaxLength; l++ {
+		first[l] = next
+		next += counts[l] << uint(huffmanMaxLength-l)
+	}
+
+	// Size the secondary tables by the longest code of each prefix.
+	var maxLength [1 << huffmanPrimaryBits]uint8
+	codes := first
+	for _, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		prefix := codes[l] >> secondaryBits
+		if l > huffmanPrimaryBits && l > maxLength[prefix] {
+			maxLength[prefix] = l
+		}
+		codes[l] += 1 << uint(huffmanMaxLength-l)
+	}
+	t.secondary = t.secondary[:0]
+	for i, l := range maxLength {
+		if l == 0 {
+			continue
+		}
+		bits := uint32(l) - huffmanPrimaryBits
+		t.primary[i] = huffmanEntryLink | bits<<huffmanEntryShift |
+			uint32(len(t.secondary))
+		t.secondary = append(t.secondary, make([]uint32, 1<<bits)...)
+	}
+
+	codes = first
+	for sym, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		code := codes[l]
+		count := 1 << uint(huffmanMaxLength-l)
+		codes[l] += count
+
+		entry := uint32(l)<<huffmanEntryShift | uint32(sym)
+		if l <= huffmanPrimaryBits {
+			start := code >> secondaryBits
+			for i := 0; i < count>>secondaryBits; i++ {
+				t.primary[start+i] = entry
+			}
+		} else {
+			link := t.primary[code>>secondaryBits]
+			shift := secondaryBits - (link>>huffmanEntryShift)&0xf
+			start := int(link&huffmanEntryMask) +
+				(code&(1<<secondaryBits-1))>>shift
+			for i := 0; i < count>>shift; i++ {
+				t.secondary[start+i] = entry
+			}
+		}
+	}
+	return nil
+}
+
+// lookup decodes the code at the most significant bits of bits. The
+// function returns the symbol and its code length.
+func (t *decodingTable) lookup(bits uint32) (uint16, int) {
+	e := t.primary[bits>>(32-huffmanPrimaryBits)]
+	if e&huffmanEntryLink != 0 {
+		n := (e >> huffmanEntryShift) & 0xf
+		e = t.secondary[int(e&huffmanEntryMask)+
+			int(bits<<huffmanPrimaryBits>>(32-n))]
+	}
+	return uint16(e & huffmanEntryMask), int(e >> huffmanEntryShift)
+}
+
+// HuffmanTable is the LZ77+Huffman code table.
+type HuffmanTable struct {
+	symLen   SymbolLength
+	decoding decodingTable
+}
+
+// NewHuffmanTable creates the Huffman table for the 256-byte symbol
+// length table symLen. The symbol lengths must form a complete code.
+func NewHuffmanTable(symLen SymbolLength) (*HuffmanTable, error) {
+	if len(symLen) != huffmanSymbols/2 {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidHuffmanTable,
+			len(symLen))
+	}
+	t := &HuffmanTable{
+		symLen: append(SymbolLength{}, symLen...),
+	}
+	if err := t.decoding.init(t.symLen); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SymbolCode describes the code of a Huffman symbol.
+type SymbolCode struct {
+	// Symbol is the Huffman symbol.
+	Symbol int
+	// Length is the code length in bits.
+	Length int
+	// Code holds the code bits in its Length low bits. The bits are
+	// stored in the stream from the most significant bit to the
+	// least significant bit.
+	Code uint16
+}
+
+func (c SymbolCode) String() string {
+	return fmt.Sprintf("%d: %0*b", c.Symbol, c.Length, c.Code)
+}
+
+// Codes returns the codes of the used symbols. The codes are in the
+// canonical order: by increasing length and by increasing symbol
+// value within a length.
+func (t *HuffmanTable) Codes() []SymbolCode {
+	codes := huffmanCodes(t.symLen)
+	var result []SymbolCode
+	for l := 1; l <= huffmanMaxLength; l++ {
+		for sym := 0; sym < huffmanSymbols; sym++ {
+			if t.symLen.Length(sym) == l {
+				result = append(result, SymbolCode{
+					Symbol: sym,
+					Length: l,
+					Code:   codes[sym],
+				})
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,626 @@
+//
+// huffman_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func huffmanCost(freq []uint64, lengths []uint8) uint64 {
+	var cost uint64
+	for sym, f := range freq {
+		cost += f * uint64(lengths[sym])
+	}
+	return cost
+}
+
+func checkLengths(t *testing.T, name string, freq []uint64, lengths []uint8) {
+	var kraft uint64
+	for sym, l := range lengths {
+		if freq[sym] > 0 && l == 0 {
+			t.Errorf("%s: symbol %d has no code", name, sym)
+		}
+		if l > huffmanMaxLength {
+			t.Errorf("%s: symbol %d length %d", name, sym, l)
+		}
+		if l > 0 {
+			kraft += 1 << uint(huffmanMaxLength-l)
+		}
+	}
+	if kraft != 1<<huffmanMaxLength {
+		t.Errorf("%s: incomplete code: Kraft sum %d/%d", name, kraft,
+			1<<huffmanMaxLength)
+	}
+}
+
+func TestHuffmanLengthsOptimal(t *testing.T) {
+	// Fibonacci frequencies produce codes longer than 15 bits
+	// without the length limit.
+	fib := make([]uint64, huffmanSymbols)
+	a, b := uint64(1), uint64(1)
+	for i := 0; i < 30; i++ {
+		fib[i] = a
+		a, b = b, a+b
+	}
+	skewed := make([]uint64, huffmanSymbols)
+	for i := range skewed {
+		skewed[i] = uint64(1 + (i*i*i)%1000)
+	}
+	skewed[0] = 1 << 30
+
+	for idx, freq := range [][]uint64{fib, skewed} {
+		heuristic := huffmanLengths(freq, huffmanMaxLength)
+		optimal := huffmanLengthsOptimal(freq, huffmanMaxLength)
+		checkLengths(t, "heuristic", freq, heuristic)
+		checkLengths(t, "optimal", freq, optimal)
+
+		h := huffmanCost(freq, heuristic)
+		o := huffmanCost(freq, optimal)
+		if o > h {
+			t.Errorf("test %d: optimal cost %d > heuristic cost %d",
+				idx, o, h)
+		}
+	}
+
+	data := append(bytes.Repeat([]byte("aaaaaaab"), 1000),
+		randomBytes(9, 5000)...)
+	def, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := &Encoder{
+		Effort: EffortBest,
+	}
+	best, err := enc.CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(best) > len(def) {
+		t.Errorf("EffortBest output %d > default output %d",
+			len(best), len(def))
+	}
+	out, err := DecompressLZ77Huffman(best, nil)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("round trip failed: %v", err)
+	}
+}
+
+func TestRepairHuffmanTable(t *testing.T) {
+	// The codes 'a' and 'b' leave a quarter of the code space unused.
+	damaged := huffmanTable(map[int]int{
+		'a': 1,
+		'b': 2,
+	})
+	_, err := DecompressLZ77Huffman(append(damaged, 0, 0, 0, 0), nil)
+	if err == nil {
+		t.Fatalf("damaged table accepted")
+	}
+	repaired, err := RepairHuffmanTable(damaged)
+	if err != nil {
+		t.Fatalf("repair failed: %s", err)
+	}
+	freq := make([]uint64, huffmanSymbols)
+	lengths := make([]uint8, huffmanSymbols)
+	for sym := range lengths {
+		lengths[sym] = uint8(repaired.Length(sym))
+	}
+	freq['a'] = 1
+	freq['b'] = 1
+	checkLengths(t, "repaired", freq, lengths)
+	if lengths['a'] != 1 || lengths['b'] != 2 || lengths[0] != 2 {
+		t.Errorf("unexpected lengths a=%d b=%d 0=%d",
+			lengths['a'], lengths['b'], lengths[0])
+	}
+
+	// The repaired table decodes the stream: the all-zero bits are
+	// the literal 'a'.
+	d := &decoder{
+		maxOut: 10,
+	}
+	err = d.lz77Huffman(append(repaired, 0, 0, 0, 0))
+	if err != ErrOutputTooLarge {
+		t.Errorf("decode with repaired table: %v", err)
+	}
+
+	complete := huffmanTable(map[int]int{'a': 1, 256: 1})
+	if r, err := RepairHuffmanTable(complete); err != nil ||
+		!bytes.Equal(r, complete) {
+		t.Errorf("complete table modified: %v", err)
+	}
+	over := huffmanTable(map[int]int{'a': 1, 'b': 1, 'c': 1})
+	if _, err := RepairHuffmanTable(over); err == nil {
+		t.Errorf("over-subscribed table repaired")
+	}
+	if _, err := RepairHuffmanTable(make(SymbolLength, 256)); err == nil {
+		t.Errorf("empty table repaired")
+	}
+}
+
+// flatDecodingTable builds a flat decoding table that is indexed with
+// the next 15 bits of input. The table is the reference for the
+// two-level decodingTable.
+func flatDecodingTable(symLen SymbolLength) ([]uint16, error) {
+	table := make([]uint16, 0, huffmanTableLength)
+	for l := 1; l <= huffmanMaxLength; l++ {
+		for sym := 0; sym < huffmanSymbols; sym++ {
+			if symLen.Length(sym) != l {
+				continue
+			}
+			for e := 0; e < 1<<uint(huffmanMaxLength-l); e++ {
+				if len(table) >= huffmanTableLength {
+					return nil, errors.New("Invalid Huffman table")
+				}
+				table = append(table, uint16(sym))
+			}
+		}
+	}
+	if len(table) != huffmanTableLength {
+		return nil, errors.New("Huffman table underflow")
+	}
+	return table, nil
+}
+
+// skewedSymbolLength returns symbol lengths with codes of all lengths
+// up to 15 bits.
+func skewedSymbolLength() SymbolLength {
+	freq := make([]uint64, huffmanSymbols)
+	a, b := uint64(1), uint64(1)
+	for sym := 0; sym < 20; sym++ {
+		freq[sym*23] = a
+		a, b = b, a+b
+	}
+	for sym := 300; sym < 400; sym++ {
+		freq[sym] = 1
+	}
+	var lengths [huffmanSymbols]uint8
+	copy(lengths[:], huffmanLengths(freq, huffmanMaxLength))
+	return packSymbolLength(&lengths)
+}
+
+func decodingTableInputs(t testing.TB) []SymbolLength {
+	tables := []SymbolLength{
+		skewedSymbolLength(),
+		SymbolLength(huffmanTable(map[int]int{'a': 1, 256: 1})),
+	}
+	enc := &Encoder{
+		Effort: EffortBest,
+	}
+	inputs := [][]byte{
+		[]byte("abcabdabeabf"),
+		randomBytes(18, 60000),
+		repeatedMatch(5000),
+		append(bytes.Repeat([]byte("xyz"), 1000), randomBytes(19, 3000)...),
+	}
+	for _, data := range inputs {
+		compressed, err := enc.CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, SymbolLength(compressed[:256]))
+	}
+	return tables
+}
+
+func TestDecodingTable(t *testing.T) {
+	for i, symLen := range decodingTableInputs(t) {
+		flat, err := flatDecodingTable(symLen)
+		if err != nil {
+			t.Fatalf("table %d: %s", i, err)
+		}
+		var table decodingTable
+		if err := table.init(symLen); err != nil {
+			t.Fatalf("table %d: %s", i, err)
+		}
+		for bits := 0; bits < huffmanTableLength; bits++ {
+			// The bits below the 15-bit index must not matter.
+			next := uint32(bits)<<17 | uint32(bits)&0x1ffff
+			sym, length := table.lookup(next)
+			if sym != flat[bits] || length != symLen.Length(int(sym)) {
+				t.Fatalf("table %d: bits %015b: got %d/%d, expected %d/%d",
+					i, bits, sym, length, flat[bits],
+					symLen.Length(int(flat[bits])))
+			}
+		}
+	}
+
+	var table decodingTable
+	over := huffmanTable(map[int]int{'a': 1, 'b': 1, 'c': 1})
+	if err := table.init(over); err == nil {
+		t.Errorf("over-subscribed table accepted")
+	}
+	under := huffmanTable(map[int]int{'a': 1, 'b': 2})
+	if err := table.init(under); err == nil {
+		t.Errorf("under-subscribed table accepted")
+	}
+}
+
+// decodingTableBits returns the input for the decoding table
+// benchmarks.
+func decodingTableBits() []uint32 {
+	rnd := rand.New(rand.NewSource(20))
+	bits := make([]uint32, 4096)
+	for i := range bits {
+		bits[i] = rnd.Uint32()
+	}
+	return bits
+}
+
+func BenchmarkDecodingTableFlat(b *testing.B) {
+	tables := decodingTableInputs(b)
+	bits := decodingTableBits()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		symLen := tables[i%len(tables)]
+		flat, err := flatDecodingTable(symLen)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var sum int
+		for _, next := range bits {
+			sym := flat[next>>(32-huffmanMaxLength)]
+			sum += int(sym) + symLen.Length(int(sym))
+		}
+	}
+	b.ReportMetric(float64(huffmanTableLength*2), "table-bytes")
+}
+
+func BenchmarkDecodingTableTwoLevel(b *testing.B) {
+	tables := decodingTableInputs(b)
+	bits := decodingTableBits()
+	var size int
+	for _, symLen := range tables {
+		var table decodingTable
+		if err := table.init(symLen); err != nil {
+			b.Fatal(err)
+		}
+		size += len(table.primary)*4 + len(table.secondary)*4
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var table decodingTable
+		if err := table.init(tables[i%len(tables)]); err != nil {
+			b.Fatal(err)
+		}
+		var sum int
+		for _, next := range bits {
+			sym, length := table.lookup(next)
+			sum += int(sym) + length
+		}
+	}
+	b.ReportMetric(float64(size/len(tables)), "table-bytes")
+}
+
+func TestHuffmanTableCodes(t *testing.T) {
+	for i, symLen := range decodingTableInputs(t) {
+		table, err := NewHuffmanTable(symLen)
+		if err != nil {
+			t.Fatalf("table %d: %s", i, err)
+		}
+		codes := table.Codes()
+		var next uint32
+		var kraft int
+		for j, c := range codes {
+			if c.Length != symLen.Length(c.Symbol) {
+				t.Fatalf("table %d: %v: length %d", i, c,
+					symLen.Length(c.Symbol))
+			}
+			// The canonical code is the previous code plus one,
+			// extended to the code length.
+			if j > 0 {
+				next = (next + 1) << uint(c.Length-codes[j-1].Length)
+			}
+			if uint32(c.Code) != next {
+				t.Fatalf("table %d: %v: expected code %0*b",
+					i, c, c.Length, next)
+			}
+			kraft += 1 << uint(huffmanMaxLength-c.Length)
+
+			sym, length := table.decoding.lookup(uint32(c.Code) <<
+				uint(32-c.Length))
+			if int(sym) != c.Symbol || length != c.Length {
+				t.Fatalf("table %d: %v decodes to %d/%d",
+					i, c, sym, length)
+			}
+		}
+		if kraft != huffmanTableLength {
+			t.Errorf("table %d: codes are not complete", i)
+		}
+	}
+
+	if _, err := NewHuffmanTable(huffmanTable(map[int]int{'a': 1})); err == nil {
+		t.Errorf("incomplete table accepted")
+	}
+	if _, err := NewHuffmanTable(make(SymbolLength, 100)); err == nil {
+		t.Errorf("short table accepted")
+	}
+}
+
+func TestSymbolLengths(t *testing.T) {
+	for i, symLen := range decodingTableInputs(t) {
+		lengths := symLen.Lengths()
+		if len(lengths) != huffmanSymbols {
+			t.Fatalf("table %d: got %d lengths", i, len(lengths))
+		}
+		for sym, l := range lengths {
+			if int(l) != symLen.Length(sym) {
+				t.Fatalf("table %d: symbol %d: got length %d, expected %d",
+					i, sym, l, symLen.Length(sym))
+			}
+		}
+		packed, err := NewSymbolLength(lengths)
+		if err != nil {
+			t.Fatalf("table %d: %s", i, err)
+		}
+		if !bytes.Equal(packed, symLen) {
+			t.Errorf("table %d: round trip mismatch", i)
+		}
+	}
+
+	if _, err := NewSymbolLength(make([]uint8, 100)); err == nil {
+		t.Errorf("short lengths accepted")
+	}
+	lengths := make([]uint8, huffmanSymbols)
+	lengths[10] = 16
+	if _, err := NewSymbolLength(lengths); err == nil {
+		t.Errorf("16-bit length accepted")
+	}
+}
+
+func TestBuildSymbolLength(t *testing.T) {
+	var skewed, random [huffmanSymbols]uint64
+	a, b := uint64(1), uint64(1)
+	for sym := 0; sym < 40; sym++ {
+		skewed[sym] = a
+		a, b = b, a+b
+	}
+	skewed[huffmanEOF] = 1
+	rnd := rand.New(rand.NewSource(1))
+	for sym := 0; sym < 256; sym++ {
+		random[sym] = uint64(rnd.Intn(1000))
+	}
+	random[huffmanEOF] = 1
+
+	for i, freq := range [][huffmanSymbols]uint64{skewed, random} {
+		sl, err := BuildSymbolLength(freq)
+		if err != nil {
+			t.Fatalf("frequencies %d: BuildSymbolLength failed: %s", i, err)
+		}
+		if err := sl.Validate(); err != nil {
+			t.Fatalf("frequencies %d: %s", i, err)
+		}
+		var lengths [huffmanSymbols]uint8
+		var symbols []int
+		var expected []byte
+		for sym, l := range sl.Lengths() {
+			if l > huffmanMaxLength {
+				t.Fatalf("frequencies %d: symbol %d has length %d", i, sym, l)
+			}
+			if (freq[sym] > 0) != (l > 0) {
+				t.Fatalf("frequencies %d: symbol %d: frequency %d, length %d",
+					i, sym, freq[sym], l)
+			}
+			lengths[sym] = l
+			if l > 0 && sym < 256 {
+				symbols = append(symbols, sym)
+				expected = append(expected, byte(sym))
+			}
+		}
+		data := huffmanSymbolStream(&lengths, append(symbols, huffmanEOF))
+		out, err := DecompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatalf("frequencies %d: decompress failed: %s", i, err)
+		}
+		if !bytes.Equal(out, expected) {
+			t.Errorf("frequencies %d: output mismatch", i)
+		}
+	}
+
+	// A single used symbol gets a 1-bit code and a spare symbol gets
+	// the other one.
+	var single [huffmanSymbols]uint64
+	single['x'] = 100
+	sl, err := BuildSymbolLength(single)
+	if err != nil {
+		t.Fatalf("single symbol: %s", err)
+	}
+	if sl.Length('x') != 1 || sl.Validate() != nil {
+		t.Errorf("single symbol: length %d, %v", sl.Length('x'), sl.Validate())
+	}
+}
+
+func TestKraftSum(t *testing.T) {
+	for i, symLen := range decodingTableInputs(t) {
+		if sum := symLen.KraftSum(); sum != 1.0 {
+			t.Errorf("table %d: got %v, expected 1.0", i, sum)
+		}
+	}
+
+	lengths := make([]uint8, huffmanSymbols)
+	lengths[0] = 1
+	lengths[1] = 2
+	incomplete, err := NewSymbolLength(lengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := incomplete.KraftSum(); sum != 0.75 {
+		t.Errorf("incomplete table: got %v, expected 0.75", sum)
+	}
+	lengths[2] = 1
+	oversubscribed, err := NewSymbolLength(lengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := oversubscribed.KraftSum(); sum != 1.25 {
+		t.Errorf("over-subscribed table: got %v, expected 1.25", sum)
+	}
+	if sum := make(SymbolLength, huffmanSymbols/2).KraftSum(); sum != 0 {
+		t.Errorf("empty table: got %v, expected 0", sum)
+	}
+}
+
+func TestSymbolLengthValidate(t *testing.T) {
+	for i, symLen := range decodingTableInputs(t) {
+		if err := symLen.Validate(); err != nil {
+			t.Errorf("table %d: %s", i, err)
+		}
+	}
+	lengths := make([]uint8, huffmanSymbols)
+	lengths[0] = 1
+	lengths[1] = 2
+	incomplete, err := NewSymbolLength(lengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lengths[2] = 1
+	oversubscribed, err := NewSymbolLength(lengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		symLen   SymbolLength
+		expected error
+	}{
+		{incomplete, ErrHuffmanTableUnderflow},
+		{oversubscribed, ErrInvalidHuffmanTable},
+		{make(SymbolLength, huffmanSymbols/2), ErrHuffmanTableUnderflow},
+		{make(SymbolLength, 100), ErrInvalidHuffmanTable},
+	}
+	for i, test := range tests {
+		err := test.symLen.Validate()
+		if !errors.Is(err, test.expected) {
+			t.Errorf("test %d: got %v, expected %v", i, err, test.expected)
+		}
+		var table decodingTable
+		if err := table.init(test.symLen); err == nil {
+			t.Errorf("test %d: invalid table accepted", i)
+		}
+	}
+}
+
+func TestHuffmanDecoder(t *testing.T) {
+	for i, data := range lz77HuffmanInputs {
+		expected, err := DecompressLZ77Huffman(data, []byte("xyz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hd, err := NewHuffmanDecoder(data[:256])
+		if err != nil {
+			t.Fatalf("input %d: %s", i, err)
+		}
+		if !bytes.Equal(hd.SymbolLength(), data[:256]) {
+			t.Errorf("input %d: symbol length mismatch", i)
+		}
+		out, n, err := hd.Decode(data[256:], []byte("xyz"))
+		if err != nil {
+			t.Fatalf("input %d: Decode failed: %s", i, err)
+		}
+		if !bytes.Equal(out, expected) {
+			t.Errorf("input %d: output mismatch", i)
+		}
+		if n != len(data)-256 {
+			t.Errorf("input %d: consumed %d bytes, expected %d",
+				i, n, len(data)-256)
+		}
+	}
+
+	// The decoder decodes the first block of a multi-block stream.
+	data := append(randomBytes(43, 50000), repeatedMatch(100000)...)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd, err := NewHuffmanDecoder(compressed[:256])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, n, err := hd.Decode(compressed[256:], nil)
+	if err != nil {
+		t.Fatalf("Decode failed: %s", err)
+	}
+	if len(out) < huffmanBlockSize || len(out) >= len(data) ||
+		!bytes.Equal(out, data[:len(out)]) {
+		t.Errorf("first block: got %d bytes", len(out))
+	}
+
+	// The next block's table starts at the consumed position.
+	next := compressed[256+n:]
+	hd, err = NewHuffmanDecoder(next[:256])
+	if err != nil {
+		t.Fatalf("second block: %s", err)
+	}
+	out, _, err = hd.Decode(next[256:], out)
+	if err != nil {
+		t.Fatalf("second block: Decode failed: %s", err)
+	}
+	if len(out) < 2*huffmanBlockSize || !bytes.Equal(out, data[:len(out)]) {
+		t.Errorf("second block: got %d bytes", len(out))
+	}
+
+	// The pooled decoders do not retain the compressed data.
+	if _, err := DecompressLZ77Huffman(compressed, nil); err != nil {
+		t.Fatal(err)
+	}
+	pooled := huffmanDecoders.Get().(*HuffmanDecoder)
+	if pooled.symLen != nil {
+		t.Errorf("pooled decoder retains %d bytes of input",
+			len(pooled.symLen))
+	}
+	huffmanDecoders.Put(pooled)
+
+	for _, table := range [][]byte{nil, make([]byte, 255), make([]byte, 256)} {
+		_, err := NewHuffmanDecoder(table)
+		if !errors.Is(err, ErrInvalidHuffmanTable) {
+			t.Errorf("%d byte table: got %v", len(table), err)
+		}
+	}
+}
+
+// huffmanCodesReference computes the canonical codes by scanning the
+// symbols for each length.
+func huffmanCodesReference(symLen SymbolLength) (
+	codes [huffmanSymbols]uint16) {
+
+	var next int
+	for l := 1; l <= huffmanMaxLength; l++ {
+		for sym := 0; sym < huffmanSymbols; sym++ {
+			if symLen.Length(sym) == l {
+				codes[sym] = uint16(next >> uint(huffmanMaxLength-l))
+				next += 1 << uint(huffmanMaxLength-l)
+			}
+		}
+	}
+	return
+}
+
+func TestHuffmanCodes(t *testing.T) {
+	for i, symLen := range decodingTableInputs(t) {
+		if huffmanCodes(symLen) != huffmanCodesReference(symLen) {
+			t.Errorf("table %d: codes differ", i)
+		}
+	}
+}
+
+func BenchmarkHuffmanCodes(b *testing.B) {
+	tables := decodingTableInputs(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		huffmanCodes(tables[i%len(tables)])
+	}
+}
+
+func BenchmarkHuffmanCodesReference(b *testing.B) {
+	tables := decodingTableInputs(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		huffmanCodesReference(tables[i%len(tables)])
+	}
+}
@@ -0,0 +1,111 @@
+//
+// index.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+)
+
+// indexGram is the length of the byte n-grams that Index records.
+const indexGram = 3
+
+// Index is a byte trigram index of decompressed data. The index
+// records the output positions of each 3-byte sequence so the data
+// can be searched without scanning all of it. The index uses 4 bytes
+// of memory for each byte of data, in addition to the data.
+type Index struct {
+	data  []byte
+	grams map[uint32][]int32
+}
+
+// DecompressIndexed decompresses data with the algorithm algo and
+// options opts, and builds an Index of the decompressed data while
+// decoding. The function returns the decompressed data and its index.
+func DecompressIndexed(algo Algorithm, data []byte, opts *Options) (
+	[]byte, *Index, error) {
+
+	idx := &Index{
+		grams: make(map[uint32][]int32),
+	}
+	_, err := DecompressToWriter(algo, data, indexWriter{idx}, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return idx.data, idx, nil
+}
+
+// indexWriter appends the decoder's output segments to the index.
+type indexWriter struct {
+	idx *Index
+}
+
+func (w indexWriter) Write(p []byte) (int, error) {
+	idx := w.idx
+	// The trigrams of the previous segment's last bytes complete
+	// with the bytes of p.
+	pos := max(0, len(idx.data)-indexGram+1)
+	idx.data = append(idx.data, p...)
+	for ; pos+indexGram <= len(idx.data); pos++ {
+		gram := trigram(idx.data[pos:])
+		idx.grams[gram] = append(idx.grams[gram], int32(pos))
+	}
+	return len(p), nil
+}
+
+// trigram returns the key of the first 3 bytes of data.
+func trigram(data []byte) uint32 {
+	return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+}
+
+// Data returns the indexed data.
+func (idx *Index) Data() []byte {
+	return idx.data
+}
+
+// Find returns the positions of all occurrences of pattern in the
+// indexed data in increasing order. The occurrences can overlap. The
+// patterns shorter than 3 bytes are searched by scanning the data.
+func (idx *Index) Find(pattern []byte) []int {
+	var result []int
+	if len(pattern) < indexGram {
+		if len(pattern) == 0 {
+			return nil
+		}
+		for pos := 0; ; {
+			i := bytes.Index(idx.data[pos:], pattern)
+			if i < 0 {
+				return result
+			}
+			result = append(result, pos+i)
+			pos += i + 1
+		}
+	}
+
+	// Verify the positions of the pattern's least frequent trigram.
+	var candidates []int32
+	var delta int
+	for i := 0; i+indexGram <= len(pattern); i++ {
+		positions := idx.grams[trigram(pattern[i:])]
+		if len(positions) == 0 {
+			return nil
+		}
+		if i == 0 || len(positions) < len(candidates) {
+			candidates = positions
+			delta = i
+		}
+	}
+	for _, p := range candidates {
+		pos := int(p) - delta
+		if pos >= 0 && pos+len(pattern) <= len(idx.data) &&
+			bytes.Equal(idx.data[pos:pos+len(pattern)], pattern) {
+			result = append(result, pos)
+		}
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+//
+// index_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// findAll returns the positions of all occurrences of pattern in
+// data.
+func findAll(data, pattern []byte) []int {
+	var result []int
+	for pos := 0; pos+len(pattern) <= len(data); pos++ {
+		if bytes.Equal(data[pos:pos+len(pattern)], pattern) {
+			result = append(result, pos)
+		}
+	}
+	return result
+}
+
+func TestDecompressIndexed(t *testing.T) {
+	var data []byte
+	for i := 0; i < 5; i++ {
+		data = append(data, randomBytes(int64(i), 40000)...)
+		data = append(data, repeatedMatch(50000+i)...)
+	}
+	copy(data[123456:], "needle in a haystack")
+
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, idx, err := DecompressIndexed(AlgorithmLZ77Huffman, compressed, nil)
+	if err != nil {
+		t.Fatalf("DecompressIndexed failed: %s", err)
+	}
+	if !bytes.Equal(out, data) || !bytes.Equal(idx.Data(), data) {
+		t.Fatalf("output mismatch")
+	}
+
+	positions := idx.Find([]byte("needle in a haystack"))
+	if !reflect.DeepEqual(positions, []int{123456}) {
+		t.Errorf("got %v, expected [123456]", positions)
+	}
+	for _, pattern := range [][]byte{
+		data[200000:200010],
+		data[40000:40003],
+		data[99999:100001],
+		[]byte("xyzzy"),
+	} {
+		expected := findAll(data, pattern)
+		if got := idx.Find(pattern); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Find(%x): got %d positions, expected %d",
+				pattern, len(got), len(expected))
+		}
+	}
+
+	if _, _, err := DecompressIndexed(AlgorithmLZ77Huffman,
+		compressed[:1000], nil); err == nil {
+		t.Errorf("truncated input decoded")
+	}
+}
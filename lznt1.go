@@ -0,0 +1,336 @@
+//
+// lznt1.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// LZNT1Chunk describes an LZNT1 chunk.
+type LZNT1Chunk struct {
+	// Offset is the input offset of the chunk header.
+	Offset int
+	// Compressed tells if the chunk data is compressed.
+	Compressed bool
+	// Format is the compression format from the chunk header.
+	Format int
+	// Length is the length of the chunk data, declared by the chunk
+	// header.
+	Length int
+}
+
+// ChunkHeaderError describes an LZNT1 chunk header that could not be
+// read.
+type ChunkHeaderError struct {
+	// Chunk is the index of the chunk.
+	Chunk int
+	// Offset is the input offset of the chunk header.
+	Offset int
+	// Err is the read error, TruncatedInput if the input ends inside
+	// the header.
+	Err error
+}
+
+func (e *ChunkHeaderError) Error() string {
+	return fmt.Sprintf("LZNT1 chunk %d header at offset %d: %s",
+		e.Chunk, e.Offset, e.Err)
+}
+
+// Unwrap returns the read error.
+func (e *ChunkHeaderError) Unwrap() error {
+	return e.Err
+}
+
+// lznt1Chunk reads the header of the LZNT1 chunk index.
+func lznt1Chunk(in *input, index int) (LZNT1Chunk, error) {
+	chunk := LZNT1Chunk{
+		Offset: in.pos,
+	}
+	hdr, err := in.ReadUint16()
+	if err != nil {
+		return chunk, &ChunkHeaderError{
+			Chunk:  index,
+			Offset: chunk.Offset,
+			Err:    err,
+		}
+	}
+	chunk.Format = int((hdr >> 12) & 0x7)
+
+	// The 12-bit size field is the chunk size with its 2-byte
+	// header minus 3 (MS-XCA 2.5.1.1). The data length is the field
+	// plus 1 for both chunk types and the largest field 0xfff is a
+	// chunk of exactly lznt1ChunkSize bytes.
+	chunk.Length = int(hdr&0xfff) + 1
+
+	// The signature bits are 3 for both chunk types (MS-XCA
+	// 2.5.1.1).
+	chunk.Compressed = (hdr & 0x8000) != 0
+	if chunk.Format != 3 {
+		return chunk, fmt.Errorf("%w: compression format %d",
+			ErrInvalidData, chunk.Format)
+	}
+	return chunk, nil
+}
+
+// lznt1End tests if the next chunk header is the zero header that
+// ends the LZNT1 data. The encoders emit the zero header as an end
+// marker and pad the buffers with zeros after it, so the data after
+// the zero header is not decoded.
+func lznt1End(in *input) bool {
+	return in.Avail() >= 2 && in.input[in.pos] == 0 && in.input[in.pos+1] == 0
+}
+
+// InspectLZNT1 returns the chunk structure of the LZNT1 data without
+// decompressing it.
+func InspectLZNT1(data []byte) ([]LZNT1Chunk, error) {
+	in := &input{
+		input: data,
+	}
+	var chunks []LZNT1Chunk
+	for in.Avail() > 0 && !lznt1End(in) {
+		chunk, err := lznt1Chunk(in, len(chunks))
+		if err != nil {
+			return nil, err
+		}
+		if in.Avail() < chunk.Length {
+			return nil, TruncatedInput
+		}
+		in.pos += chunk.Length
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// lznt1BatchSize is the largest output space that
+// DecompressLZNT1Parallel reserves for the chunks that it decodes
+// concurrently.
+const lznt1BatchSize = 4 << 20
+
+// lznt1Inflight is called with the output bytes that each batch of
+// DecompressLZNT1ParallelLimit reserves. The tests use it to check
+// the in-flight byte accounting.
+var lznt1Inflight func(bytes int)
+
+// DecompressLZNT1Parallel decompresses the LZNT1 data with workers
+// goroutines. The chunks do not reference each other's output so the
+// function scans the chunk headers and decodes the chunks
+// concurrently into their own output slots. The chunks are decoded in
+// batches that reserve at most lznt1BatchSize bytes of output and
+// each batch is appended to the output in order, so the memory use
+// follows the decompressed size. The output and the error are the
+// same as with DecompressLZNT1: the function returns the error of the
+// first failing chunk. If workers is 0 or negative, the function uses
+// GOMAXPROCS workers.
+func DecompressLZNT1Parallel(data []byte, workers int) ([]byte, error) {
+	return DecompressLZNT1ParallelLimit(data, workers, lznt1BatchSize)
+}
+
+// DecompressLZNT1ParallelLimit decompresses the LZNT1 data like
+// DecompressLZNT1Parallel and bounds the decoded-buffer memory of the
+// chunks in flight to maxInflightBytes. A compressed chunk reserves
+// lznt1ChunkSize bytes and an uncompressed chunk its length. The
+// workers are not dispatched to the next batch of chunks before the
+// current batch is appended to the output. The limits below the chunk
+// size of 4096 bytes are raised to the chunk size so that each batch
+// has at least one chunk.
+func DecompressLZNT1ParallelLimit(data []byte, workers,
+	maxInflightBytes int) ([]byte, error) {
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	maxInflightBytes = max(maxInflightBytes, lznt1ChunkSize)
+	in := &input{
+		input: data,
+	}
+
+	// The workers decode the chunks of the current batch into the
+	// slots of buf.
+	var chunks []LZNT1Chunk
+	var slots []int
+	var buf []byte
+	var outs [][]byte
+	var errs []error
+
+	indices := make(chan int)
+	defer close(indices)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range indices {
+				outs[i], errs[i] = lznt1DecodeChunk(data, chunks[i],
+					buf[slots[i]:slots[i]:slots[i+1]])
+				wg.Done()
+			}
+		}()
+	}
+
+	result := []byte{}
+	var index int
+	for {
+		// Scan the chunks of the next batch. The compressed chunks
+		// decode to at most lznt1ChunkSize bytes and the uncompressed
+		// chunks to their length.
+		var scanErr error
+		chunks = chunks[:0]
+		slots = append(slots[:0], 0)
+		for in.Avail() > 0 && !lznt1End(in) {
+			chunk, err := lznt1Chunk(in, index)
+			if err == nil && in.Avail() < chunk.Length {
+				err = TruncatedInput
+			}
+			if err != nil {
+				scanErr = err
+				break
+			}
+			size := chunk.Length
+			if chunk.Compressed {
+				size = lznt1ChunkSize
+			}
+			end := slots[len(chunks)] + size
+			if len(chunks) > 0 && end > maxInflightBytes {
+				in.pos = chunk.Offset
+				break
+			}
+			in.pos += chunk.Length
+			chunks = append(chunks, chunk)
+			slots = append(slots, end)
+			index++
+		}
+		if len(chunks) == 0 {
+			if scanErr != nil {
+				return nil, scanErr
+			}
+			return result, nil
+		}
+
+		size := slots[len(chunks)]
+		if lznt1Inflight != nil {
+			lznt1Inflight(size)
+		}
+		if cap(buf) < size {
+			buf = make([]byte, size)
+		}
+		if cap(outs) < len(chunks) {
+			outs = make([][]byte, len(chunks))
+			errs = make([]error, len(chunks))
+		}
+		outs = outs[:len(chunks)]
+		errs = errs[:len(chunks)]
+		clear(errs)
+		wg.Add(len(chunks))
+		for i := range chunks {
+			indices <- i
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, outs[i]...)
+		}
+		if scanErr != nil {
+			return nil, scanErr
+		}
+	}
+}
+
+// lznt1DecodeChunk decodes the LZNT1 chunk of data and appends its
+// output to out.
+func lznt1DecodeChunk(data []byte, chunk LZNT1Chunk, out []byte) (
+	[]byte, error) {
+
+	d := &decoder{
+		out: out,
+	}
+	start := chunk.Offset + 2
+	body := data[start : start+chunk.Length]
+	var err error
+	if chunk.Compressed {
+		err = d.lznt1Tokens(start, body)
+	} else {
+		err = d.literals(start, body)
+	}
+	return d.out, err
+}
+
+// lznt1LengthBits returns the number of match length bits in the
+// LZNT1 match token at the chunk position pos. The offset field
+// grows with the position: it has 4 bits for the first 16 bytes of
+// the chunk and one more bit each time the position doubles.
+func lznt1LengthBits(pos int) uint {
+	bits := uint(12)
+	for p := pos - 1; p >= 0x10; p >>= 1 {
+		bits--
+	}
+	return bits
+}
+
+// CompressLZNT1 compresses data with the LZNT1 algorithm. The data is
+// split into chunks of lznt1ChunkSize bytes, the last chunk possibly
+// shorter, and the matches are found within each chunk. The chunks
+// that do not compress are stored uncompressed.
+func CompressLZNT1(data []byte) ([]byte, error) {
+	chunks := (len(data) + lznt1ChunkSize - 1) / lznt1ChunkSize
+	out := make([]byte, 0, len(data)+2*chunks)
+	m := newMatcher(lznt1ChunkSize, lznt1ChunkSize, maxChainLen)
+
+	for start := 0; start < len(data); start += lznt1ChunkSize {
+		chunk := data[start:min(len(data), start+lznt1ChunkSize)]
+		hdrPos := len(out)
+		out = append(out, 0, 0)
+		var err error
+		out, err = lznt1CompressChunk(m, chunk, out)
+		if err != nil {
+			return nil, err
+		}
+		length := len(out) - hdrPos - 2
+		hdr := 0xb000 | (length - 1)
+		if length >= len(chunk) {
+			out = append(out[:hdrPos+2], chunk...)
+			hdr = 0x3000 | (len(chunk) - 1)
+		}
+		out[hdrPos] = byte(hdr)
+		out[hdrPos+1] = byte(hdr >> 8)
+	}
+	return out, nil
+}
+
+// lznt1CompressChunk encodes the tokens of the chunk and appends them
+// to out. The tokens are grouped by flag bytes that precede their
+// tokens, the first token in the least significant bit. The matches
+// are limited to the offset and length bits of their positions. The
+// match finder f starts a new input at the beginning of each chunk.
+func lznt1CompressChunk(f MatchFinder, chunk, out []byte) ([]byte, error) {
+	var flagPos, count int
+	for pos := 0; pos < len(chunk); count++ {
+		if count%8 == 0 {
+			flagPos = len(out)
+			out = append(out, 0)
+		}
+		bits := lznt1LengthBits(pos)
+		t, err := nextToken(f, chunk, pos, pos, 1<<bits-1+lz77MinMatch)
+		if err != nil {
+			return nil, err
+		}
+		if t.length == 0 {
+			out = append(out, t.literal)
+			pos++
+			continue
+		}
+		out[flagPos] |= 1 << (count % 8)
+		token := (t.offset-1)<<bits | (t.length - lz77MinMatch)
+		out = append(out, byte(token), byte(token>>8))
+		pos += t.length
+	}
+	return out, nil
+}
@@ -0,0 +1,397 @@
+//
+// lznt1_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestInspectLZNT1(t *testing.T) {
+	data := lznt1Uncompressed("abcdef", "ghi")
+	data = append(data, lznt1Inputs[0]...)
+
+	chunks, err := InspectLZNT1(data)
+	if err != nil {
+		t.Fatalf("InspectLZNT1 failed: %s", err)
+	}
+	expected := []LZNT1Chunk{
+		{Offset: 0, Compressed: false, Format: 3, Length: 6},
+		{Offset: 8, Compressed: false, Format: 3, Length: 3},
+		{Offset: 13, Compressed: true, Format: 3, Length: 57},
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("got %d chunks, expected %d", len(chunks), len(expected))
+	}
+	for i, chunk := range chunks {
+		if chunk != expected[i] {
+			t.Errorf("chunk %d: got %+v, expected %+v", i, chunk, expected[i])
+		}
+	}
+
+	if _, err := InspectLZNT1(data[:len(data)-1]); err != TruncatedInput {
+		t.Errorf("expected TruncatedInput, got %v", err)
+	}
+	if _, err := InspectLZNT1([]byte{0x00, 0xa0}); err == nil {
+		t.Errorf("invalid compression format accepted")
+	}
+}
+
+func TestLZNT1TruncatedHeader(t *testing.T) {
+	_, err := DecompressLZNT1([]byte{0x05})
+	herr, ok := err.(*ChunkHeaderError)
+	if !ok {
+		t.Fatalf("got %v, expected ChunkHeaderError", err)
+	}
+	if herr.Chunk != 0 || herr.Offset != 0 || herr.Err != TruncatedInput {
+		t.Errorf("unexpected error %+v", herr)
+	}
+	if !errors.Is(err, TruncatedInput) {
+		t.Errorf("error %v is not TruncatedInput", err)
+	}
+
+	// The header of the second chunk is truncated.
+	data := append(lznt1Uncompressed("abcdef"), 0x05)
+	_, err = DecompressLZNT1(data)
+	herr, ok = err.(*ChunkHeaderError)
+	if !ok || herr.Chunk != 1 || herr.Offset != 8 {
+		t.Errorf("got %v, expected chunk 1 header error at offset 8", err)
+	}
+	if _, err := InspectLZNT1(data); !errors.Is(err, TruncatedInput) {
+		t.Errorf("InspectLZNT1: got %v, expected TruncatedInput", err)
+	}
+}
+
+func TestDecompressLZNT1Parallel(t *testing.T) {
+	data, _ := lznt1ManyChunks(50)
+	for i := 0; i < 20; i++ {
+		data = append(data, lznt1Inputs[0]...)
+	}
+	data = append(data, lznt1Uncompressed(string(randomBytes(3, lznt1ChunkSize)))...)
+	expected, err := DecompressLZNT1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 3, 100} {
+		out, err := DecompressLZNT1Parallel(data, workers)
+		if err != nil {
+			t.Fatalf("workers=%d: decompress failed: %s", workers, err)
+		}
+		if !bytes.Equal(out, expected) {
+			t.Errorf("workers=%d: output mismatch", workers)
+		}
+	}
+
+	// The invalid match offset of the corrupt chunk is reported
+	// before the truncated chunk at the end.
+	corrupt := append([]byte{}, data...)
+	corrupt = append(corrupt, 0x02, 0xb0, 0x02, 'a', 0x00, 0x10)
+	corrupt = append(corrupt, lznt1Uncompressed("abcdef")...)
+	for _, input := range [][]byte{
+		data[:len(data)-1],
+		corrupt,
+		corrupt[:len(corrupt)-1],
+		{0x05},
+	} {
+		_, expected := DecompressLZNT1(input)
+		_, err := DecompressLZNT1Parallel(input, 4)
+		if expected == nil || err == nil || err.Error() != expected.Error() {
+			t.Errorf("got %v, expected %v", err, expected)
+		}
+	}
+}
+
+func TestDecompressLZNT1ParallelAllocs(t *testing.T) {
+	// The empty compressed chunks decode to nothing but each one can
+	// produce up to lznt1ChunkSize bytes.
+	data := bytes.Repeat([]byte{0x00, 0xb0, 0x00}, 100000)
+	out, err := DecompressLZNT1(data)
+	if err != nil || len(out) != 0 {
+		t.Fatalf("DecompressLZNT1: %d bytes, %v", len(out), err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	out, err = DecompressLZNT1Parallel(data, 4)
+	runtime.ReadMemStats(&after)
+	if err != nil || len(out) != 0 {
+		t.Fatalf("DecompressLZNT1Parallel: %d bytes, %v", len(out), err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 2*lznt1BatchSize {
+		t.Errorf("DecompressLZNT1Parallel allocated %d bytes", alloc)
+	}
+
+	// The chunks of several batches are appended in order.
+	data = bytes.Repeat(lznt1Inputs[0], 3*lznt1BatchSize/lznt1ChunkSize)
+	data = append(data, lznt1Uncompressed("abc")...)
+	expected, err := DecompressLZNT1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = DecompressLZNT1Parallel(data, 3)
+	if err != nil || !bytes.Equal(out, expected) {
+		t.Errorf("batches: output mismatch, %v", err)
+	}
+}
+
+func TestDecompressLZNT1ParallelLimit(t *testing.T) {
+	// Each compressed chunk reserves lznt1ChunkSize bytes.
+	data := bytes.Repeat(lznt1Inputs[0], 100)
+	data = append(data, lznt1Uncompressed(string(randomBytes(12, 1000)),
+		"abc")...)
+	expected, err := DecompressLZNT1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		lznt1Inflight = nil
+	}()
+	for _, limit := range []int{0, lznt1ChunkSize, 10000, 100000} {
+		var batches, peak int
+		lznt1Inflight = func(bytes int) {
+			batches++
+			peak = max(peak, bytes)
+		}
+		out, err := DecompressLZNT1ParallelLimit(data, 4, limit)
+		if err != nil || !bytes.Equal(out, expected) {
+			t.Fatalf("limit %d: output mismatch, %v", limit, err)
+		}
+		if peak > max(limit, lznt1ChunkSize) {
+			t.Errorf("limit %d: %d bytes in flight", limit, peak)
+		}
+		if min := 100 * lznt1ChunkSize / max(limit, lznt1ChunkSize); batches < min {
+			t.Errorf("limit %d: %d batches, expected at least %d", limit,
+				batches, min)
+		}
+	}
+}
+
+func TestLZNT1MaxUncompressedChunk(t *testing.T) {
+	// The header 0x3fff is an uncompressed chunk of 4096 bytes: the
+	// size field 0xfff plus 1. The header 0x3000 is a 1-byte chunk.
+	plain := randomBytes(4, lznt1ChunkSize)
+	data := append([]byte{0xff, 0x3f}, plain...)
+	data = append(data, 0x00, 0x30, 'x')
+
+	chunks, err := InspectLZNT1(data)
+	if err != nil {
+		t.Fatalf("InspectLZNT1 failed: %s", err)
+	}
+	expected := []LZNT1Chunk{
+		{Offset: 0, Compressed: false, Format: 3, Length: lznt1ChunkSize},
+		{Offset: 2 + lznt1ChunkSize, Compressed: false, Format: 3, Length: 1},
+	}
+	if !reflect.DeepEqual(chunks, expected) {
+		t.Errorf("got %+v, expected %+v", chunks, expected)
+	}
+
+	out, err := DecompressLZNT1(data)
+	if err != nil {
+		t.Fatalf("DecompressLZNT1 failed: %s", err)
+	}
+	if !bytes.Equal(out, append(plain, 'x')) {
+		t.Errorf("output mismatch")
+	}
+	if algo, err := DetectAlgorithm(data); err != nil || algo != AlgorithmLZNT1 {
+		t.Errorf("detected %s, %v", algo, err)
+	}
+	if _, err := DecompressLZNT1(data[:lznt1ChunkSize+1]); err != TruncatedInput {
+		t.Errorf("truncated chunk: got %v, expected %v", err, TruncatedInput)
+	}
+}
+
+func TestLZNT1ZeroHeader(t *testing.T) {
+	// A compressed chunk and an uncompressed 3-byte chunk, followed by
+	// the zero end marker and padding.
+	data := append([]byte{}, lznt1Inputs[0]...)
+	data = append(data, lznt1Uncompressed("abc")...)
+	expected, err := DecompressLZNT1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(expected, []byte("abc")) {
+		t.Fatalf("unexpected output %q", expected)
+	}
+
+	for _, padding := range []int{2, 3, 512} {
+		padded := append(append([]byte{}, data...), make([]byte, padding)...)
+		out, err := DecompressLZNT1(padded)
+		if err != nil {
+			t.Fatalf("padding %d: DecompressLZNT1 failed: %s", padding, err)
+		}
+		if !bytes.Equal(out, expected) {
+			t.Errorf("padding %d: output mismatch", padding)
+		}
+		out, err = DecompressLZNT1Parallel(padded, 2)
+		if err != nil || !bytes.Equal(out, expected) {
+			t.Errorf("padding %d: DecompressLZNT1Parallel: %v", padding, err)
+		}
+		chunks, err := InspectLZNT1(padded)
+		if err != nil || len(chunks) != 2 {
+			t.Errorf("padding %d: InspectLZNT1: %d chunks, %v", padding,
+				len(chunks), err)
+		}
+		if algo, err := DetectAlgorithm(padded); err != nil ||
+			algo != AlgorithmLZNT1 {
+			t.Errorf("padding %d: detected %s, %v", padding, algo, err)
+		}
+	}
+
+	// The data after the zero header is not decoded.
+	out, err := DecompressLZNT1(append(append([]byte{}, data...),
+		0x00, 0x00, 0x00, 0x30, 'x'))
+	if err != nil || !bytes.Equal(out, expected) {
+		t.Errorf("data after the end marker: %q, %v", out, err)
+	}
+
+	out, err = DecompressLZNT1([]byte{0x00, 0x00})
+	if err != nil || len(out) != 0 {
+		t.Errorf("zero header: %q, %v", out, err)
+	}
+}
+
+func TestCompressLZNT1(t *testing.T) {
+	text := bytes.Repeat([]byte("LZNT1 compresses each chunk separately. "), 300)
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("abcabcabcabc"),
+		text,
+		randomBytes(5, 3*lznt1ChunkSize+100),
+		make([]byte, lznt1ChunkSize),
+		make([]byte, 2*lznt1ChunkSize+1),
+	}
+	for i, data := range inputs {
+		compressed, err := CompressLZNT1(data)
+		if err != nil {
+			t.Fatalf("input %d: CompressLZNT1 failed: %s", i, err)
+		}
+		chunks, err := InspectLZNT1(compressed)
+		if err != nil {
+			t.Fatalf("input %d: InspectLZNT1 failed: %s", i, err)
+		}
+		expected := (len(data) + lznt1ChunkSize - 1) / lznt1ChunkSize
+		if len(chunks) != expected {
+			t.Errorf("input %d: %d chunks, expected %d", i, len(chunks),
+				expected)
+		}
+		if len(compressed) > len(data)+2*len(chunks) {
+			t.Errorf("input %d: %d bytes compressed to %d bytes", i,
+				len(data), len(compressed))
+		}
+		out, err := DecompressLZNT1(compressed)
+		if err != nil {
+			t.Fatalf("input %d: DecompressLZNT1 failed: %s", i, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("input %d: round trip failed", i)
+		}
+	}
+
+	// The random chunks expand and they are stored uncompressed.
+	compressed, err := CompressLZNT1(randomBytes(6, lznt1ChunkSize+10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := InspectLZNT1(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, chunk := range chunks {
+		if chunk.Compressed {
+			t.Errorf("random chunk %d is compressed", i)
+		}
+	}
+	if chunks[1].Length != 10 {
+		t.Errorf("final chunk has %d bytes, expected 10", chunks[1].Length)
+	}
+
+	// The repeated text compresses in every chunk.
+	compressed, err = CompressLZNT1(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) > len(text)/10 {
+		t.Errorf("text compressed to %d bytes", len(compressed))
+	}
+	chunks, err = InspectLZNT1(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, chunk := range chunks {
+		if !chunk.Compressed {
+			t.Errorf("text chunk %d is not compressed", i)
+		}
+	}
+}
+
+func TestLZNT1ChunkFormat(t *testing.T) {
+	// The signature bits must be 3 for the uncompressed chunks too.
+	for format := 0; format < 8; format++ {
+		hdr := format<<12 | 2
+		data := []byte{byte(hdr), byte(hdr >> 8), 'a', 'b', 'c'}
+		out, err := DecompressLZNT1(data)
+		if format == 3 {
+			if err != nil || string(out) != "abc" {
+				t.Errorf("format 3: %q, %v", out, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidData) {
+			t.Errorf("format %d: got %v, expected %v", format, err,
+				ErrInvalidData)
+		}
+		if _, err := InspectLZNT1(data); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("format %d: InspectLZNT1: got %v", format, err)
+		}
+	}
+
+	// The size field 0xfff is 4096 bytes of compressed data: 455
+	// groups of a flag byte and 8 literals and a final flag byte.
+	plain := randomBytes(10, 455*8)
+	data := []byte{0xff, 0xbf}
+	for i := 0; i < len(plain); i += 8 {
+		data = append(data, 0)
+		data = append(data, plain[i:i+8]...)
+	}
+	data = append(data, 0)
+	chunks, err := InspectLZNT1(data)
+	if err != nil {
+		t.Fatalf("InspectLZNT1 failed: %s", err)
+	}
+	if len(chunks) != 1 || !chunks[0].Compressed ||
+		chunks[0].Length != lznt1ChunkSize {
+		t.Errorf("unexpected chunks %+v", chunks)
+	}
+	out, err := DecompressLZNT1(data)
+	if err != nil {
+		t.Fatalf("DecompressLZNT1 failed: %s", err)
+	}
+	if !bytes.Equal(out, plain) {
+		t.Errorf("output mismatch")
+	}
+
+	// A full uncompressed chunk followed by a compressed chunk.
+	plain = append(randomBytes(11, lznt1ChunkSize),
+		bytes.Repeat([]byte("abc"), 100)...)
+	data, err = CompressLZNT1(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != 0xff || data[1] != 0x3f {
+		t.Errorf("first chunk header %02x%02x", data[1], data[0])
+	}
+	out, err = DecompressLZNT1(data)
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Errorf("full uncompressed chunk: %v", err)
+	}
+}
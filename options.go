@@ -0,0 +1,510 @@
+//
+// options.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// Profile specifies the compressed stream variant the decoder
+// accepts.
+type Profile int
+
+// Decoder profiles.
+const (
+	// ProfileStandard decodes streams as specified in MS-XCA.
+	ProfileStandard Profile = iota
+
+	// Profile7Zip decodes the streams produced by 7-Zip and other
+	// third-party tools that store Xpress data in fixed-size chunks,
+	// such as WIM resources. The profile handles the following
+	// deviations from MS-XCA:
+	//
+	//   - The stream ends when the output reaches the chunk size
+	//     given in Options.Size. The encoders do not terminate the
+	//     stream with the LZ77+Huffman end-of-stream marker or with
+	//     the unused LZ77 match flags.
+	//   - The LZ77+Huffman symbol 256 is always a match with offset
+	//     1 and length 3, also when it is the last symbol of the
+	//     input.
+	//   - The input after the last token is padding and it is
+	//     ignored.
+	Profile7Zip
+)
+
+var profileNames = map[Profile]string{
+	ProfileStandard: "Standard",
+	Profile7Zip:     "7-Zip",
+}
+
+func (p Profile) String() string {
+	name, ok := profileNames[p]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Profile %d}", p)
+}
+
+// ErrSizeRequired is returned if the decoder profile requires the
+// output size but Options.Size is not set.
+var ErrSizeRequired = errors.New("Output size required")
+
+// ErrIncomplete is returned if the data of a partial stream ends
+// before the end of the stream. The caller can retry the
+// decompression when more data has arrived.
+// The error matches ErrTruncatedInput with errors.Is.
+var ErrIncomplete error = &categoryError{
+	msg:      "Incomplete input",
+	category: ErrTruncatedInput,
+}
+
+// Options define optional decompression parameters.
+type Options struct {
+	// Resolver resolves match references that point before the
+	// beginning of the output. The offset argument is the distance
+	// from the beginning of the output: 1 is the last byte before
+	// the output. If Resolver is nil, such matches are errors.
+	Resolver func(offset int) byte
+
+	// AllowOffset tells if the match offset is legal for the
+	// caller's stream profile. The decompression fails with
+	// ErrDisallowedOffset if AllowOffset returns false for the offset
+	// of a match. The value nil allows all offsets.
+	AllowOffset func(offset int) bool
+
+	// ResetPoints specify the output offsets where the match window
+	// is reset. Matches must not reference data before a reset point
+	// when producing output at or after it. The offsets are positions
+	// in the output, including the initial contents of the output
+	// buffer.
+	ResetPoints []int
+
+	// MaxOutput limits the number of decompressed bytes. The
+	// decompression fails with ErrOutputTooLarge if the output would
+	// exceed the limit. The initial contents of the output buffer do
+	// not count toward the limit. The value 0 means no limit.
+	MaxOutput int
+
+	// MaxExpansion limits the number of decompressed bytes to
+	// MaxExpansion times the input length. The limit catches the
+	// corrupt streams that expand without reaching their end. The
+	// decompression fails with ErrExpansionLimit if the output would
+	// exceed the limit. The initial contents of the output buffer do
+	// not count toward the limit. The value 0 means no limit.
+	MaxExpansion int
+
+	// MaxMatches limits the number of match tokens. The
+	// decompression fails with ErrTooManyMatches if the data has more
+	// matches. The value 0 means no limit.
+	MaxMatches int
+
+	// Size specifies the expected number of decompressed bytes. If
+	// Size is set, the decoding stops when the output reaches Size
+	// bytes and the data after the stream, such as the padding of
+	// plain LZ77 streams to a block boundary, is ignored. The
+	// decompression fails with ErrChunkOverrun if a token
+	// would exceed the size and with TruncatedInput if the data ends
+	// before the size is reached. The initial contents of the output
+	// buffer do not count toward the size. The value 0 means that the
+	// size is not known.
+	Size int
+
+	// Profile specifies the compressed stream variant. The default
+	// is ProfileStandard.
+	Profile Profile
+
+	// Lenient decodes a truncated final match on a best-effort
+	// basis. If the final match exceeds Size, the decoder copies the
+	// bytes that fit into the size. If the input ends inside the
+	// match length bytes, the decoder copies the match with the
+	// shortest length that the available bytes allow and ends the
+	// decoding. If Lenient is false, these cases fail with
+	// ErrChunkOverrun and TruncatedInput respectively.
+	Lenient bool
+
+	// Strict requires the bits after the LZ77+Huffman end-of-stream
+	// marker to be zero padding. The streams with data after the
+	// marker fail with ErrTrailingBits.
+	Strict bool
+
+	// FlushSize specifies the size of the output segments that
+	// DecompressToWriter writes. The writer receives segments of
+	// FlushSize bytes, except possibly the last segment. The value 0
+	// writes the output as the decoder's circular window fills.
+	FlushSize int
+
+	// Partial specifies that the data can be a prefix of a larger
+	// stream. If the data ends before the end of the stream, the
+	// decompression fails with ErrIncomplete instead of
+	// TruncatedInput. The corrupt data fails with the decoding
+	// errors as without Partial.
+	Partial bool
+
+	// VerifyChecksum states that the data ends with the checksum
+	// trailer of Encoder.AppendChecksum. The trailer is removed and
+	// the decompression fails with ErrChecksum if the decompressed
+	// data does not match the checksum. The data without the trailer
+	// fails with ErrNoChecksum. The streams without the trailer are
+	// decompressed without VerifyChecksum.
+	VerifyChecksum bool
+
+	// Context cancels the decompression. The decoder checks the
+	// context periodically and fails with the context's error if
+	// the context is done. The value nil means no cancellation.
+	Context context.Context
+
+	// Policy aborts the decompression of the streams that violate
+	// the caller's policy. The decoder calls Policy periodically with
+	// the running decode metrics and fails with the error that Policy
+	// returns. The value nil means no policy.
+	Policy Policy
+
+	// MatchSymbols maps the LZ77+Huffman match symbols to their
+	// match lengths and offset bits for the streams that assign the
+	// match symbols differently from MS-XCA. The literals and the
+	// end-of-stream marker are not mapped. The value nil means
+	// StandardMatchSymbols.
+	MatchSymbols MatchSymbolMap
+
+	// Chunker finds the content-defined chunk boundaries of the
+	// decompressed data while the data is decoded. The decoder feeds
+	// the output to the Chunker and closes it at the end of the
+	// stream. The initial contents of the output buffer are not
+	// chunked. The value nil disables the chunking.
+	Chunker *Chunker
+}
+
+// MatchSymbolMap maps the LZ77+Huffman match symbol to the match
+// length header and the number of offset bits. The symbol is in the
+// range 256...511. The length header is in the range 0...15 and the
+// header 15 is followed by the extended match length bytes. The
+// number of offset bits is in the range 0...15. The decoding fails
+// with ErrInvalidData if the mapping returns a value out of range.
+type MatchSymbolMap func(symbol int) (length, offsetBits int)
+
+// StandardMatchSymbols is the MS-XCA mapping of the match symbols.
+// The low nibble of symbol-256 is the length header and the high
+// nibble is the number of offset bits.
+func StandardMatchSymbols(symbol int) (length, offsetBits int) {
+	symbol -= 256
+	return symbol % 16, symbol / 16
+}
+
+// DecompressWithOptions decompresses data with the algorithm algo
+// and options opts, appending the decompressed data to out. The opts
+// can be nil for the default options.
+func DecompressWithOptions(algo Algorithm, data, out []byte,
+	opts *Options) ([]byte, error) {
+
+	d, err := newDecoder(out, opts)
+	if err != nil {
+		return nil, err
+	}
+	// The decoder without options has no limits or hooks.
+	d.fast = opts == nil
+	var sum uint32
+	checked := opts != nil && opts.VerifyChecksum
+	if checked {
+		data, sum, err = stripChecksum(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = d.decode(algo, data)
+	if err == nil && d.sized && !d.done() {
+		err = TruncatedInput
+	}
+	if err != nil {
+		return nil, incomplete(err, opts)
+	}
+	if checked && crc32.ChecksumIEEE(d.out[d.start:]) != sum {
+		return nil, ErrChecksum
+	}
+	if d.chunker != nil {
+		if err := d.feedChunker(); err != nil {
+			return nil, err
+		}
+		if err := d.chunker.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return d.out, nil
+}
+
+// fullChunkSizes are the chunk sizes that DecompressFullChunk tries
+// for the Profile7Zip streams: the 32 KB chunks of WIM resources and
+// the 64 KB chunks of 7-Zip.
+var fullChunkSizes = []int{32 * 1024, 64 * 1024}
+
+// DecompressFullChunk decompresses data with the algorithm algo and
+// retries the LZ77+Huffman data as a full-size Profile7Zip chunk if
+// the standard decoding fails. The function returns the decompressed
+// data and the profile that decoded it. The Profile7Zip chunks do not
+// encode their size so the data is tried with the full chunk sizes of
+// WIM and 7-Zip, and the shorter chunks, such as the last chunk of a
+// resource, are not recovered: decode them with Profile7Zip and their
+// size in Options.Size. A stream that decodes without error under the
+// standard profile is returned as is, even if it was produced for
+// another profile. The Profile7Zip decoding must consume all data
+// except its zero padding: the decoding stops at the chunk size so it
+// would otherwise accept a prefix of a truncated or garbage-tailed
+// stream. If the retries fail, the function returns the error of the
+// standard decoding.
+func DecompressFullChunk(data []byte, algo Algorithm) ([]byte, Profile,
+	error) {
+
+	out, err := DecompressWithOptions(algo, data, nil, nil)
+	if err == nil {
+		return out, ProfileStandard, nil
+	}
+	if algo != AlgorithmLZ77Huffman {
+		return nil, ProfileStandard, err
+	}
+	for _, size := range fullChunkSizes {
+		out, ok := decompress7Zip(data, size)
+		if ok {
+			return out, Profile7Zip, nil
+		}
+	}
+	return nil, ProfileStandard, err
+}
+
+// decompress7Zip decompresses the LZ77+Huffman data of size bytes with
+// Profile7Zip. The function returns false if the decoding fails or if
+// the data has non-zero bytes after the decoded stream.
+func decompress7Zip(data []byte, size int) ([]byte, bool) {
+	d, err := newDecoder(nil, &Options{
+		Size:    size,
+		Profile: Profile7Zip,
+	})
+	if err != nil {
+		return nil, false
+	}
+	if err := d.decode(AlgorithmLZ77Huffman, data); err != nil || !d.done() {
+		return nil, false
+	}
+	for _, b := range data[d.consumed:] {
+		if b != 0 {
+			return nil, false
+		}
+	}
+	return d.out, true
+}
+
+// DecompressToWriter decompresses data with the algorithm algo and
+// options opts, and writes the decompressed data to w. The decoder
+// keeps the match window in a fixed-size circular buffer so its
+// memory use does not depend on the output size. The function
+// returns the number of bytes written to w.
+func DecompressToWriter(algo Algorithm, data []byte, w io.Writer,
+	opts *Options) (int64, error) {
+
+	d, err := newDecoder(nil, opts)
+	if err != nil {
+		return 0, err
+	}
+	var sum uint32
+	checked := opts != nil && opts.VerifyChecksum
+	if checked {
+		data, sum, err = stripChecksum(data)
+		if err != nil {
+			return 0, err
+		}
+	}
+	var n int64
+	var crc uint32
+	var flushSize int
+	if opts != nil {
+		flushSize = opts.FlushSize
+	}
+	sink := writerSink(w, &n)
+	d.window = newWindow(func(p []byte) error {
+		if err := sink(p); err != nil {
+			return err
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, p)
+		if d.chunker != nil {
+			_, err = d.chunker.Write(p)
+		}
+		return err
+	}, flushSize)
+	err = d.decode(algo, data)
+	if err == nil && d.sized && !d.done() {
+		err = TruncatedInput
+	}
+	if err == nil {
+		err = d.flush()
+	}
+	if err == nil && checked && crc != sum {
+		err = ErrChecksum
+	}
+	if err == nil && d.chunker != nil {
+		err = d.chunker.Close()
+	}
+	return n, incomplete(err, opts)
+}
+
+// DecodesTo tests if compressed decompresses with the algorithm algo
+// to plaintext. The function compares the output with plaintext as
+// it is decoded and it stops at the first mismatch, so it does not
+// hold the decompressed data in memory. The function returns false
+// and the decoding error if compressed does not decode.
+func DecodesTo(compressed []byte, algo Algorithm, plaintext []byte) (
+	bool, error) {
+
+	equal, _, err := decodesTo(compressed, algo, plaintext)
+	return equal, err
+}
+
+// errMismatch stops the decoding of DecodesTo at the first mismatch.
+var errMismatch = errors.New("Output mismatch")
+
+// decodesTo implements DecodesTo. The function returns also the
+// number of bytes that the decoder decoded before it stopped.
+func decodesTo(compressed []byte, algo Algorithm, plaintext []byte) (
+	bool, int, error) {
+
+	d, err := newDecoder(nil, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	rest := plaintext
+	d.window = newWindow(func(p []byte) error {
+		if len(p) > len(rest) || !bytes.Equal(p, rest[:len(p)]) {
+			return errMismatch
+		}
+		rest = rest[len(p):]
+		return nil
+	}, 0)
+	err = d.decode(algo, compressed)
+	if err == nil {
+		err = d.flush()
+	}
+	if err == errMismatch {
+		return false, d.decoded(), nil
+	}
+	if err != nil {
+		return false, d.decoded(), err
+	}
+	return len(rest) == 0, d.decoded(), nil
+}
+
+// incomplete returns ErrIncomplete for the truncated input errors err
+// if the options opts specify a partial stream.
+func incomplete(err error, opts *Options) error {
+	if opts != nil && opts.Partial &&
+		(truncation(err) || errors.Is(err, TruncatedInput)) {
+		return ErrIncomplete
+	}
+	return err
+}
+
+// DecompressPrefix decompresses the first n bytes of data with the
+// algorithm algo. The decoding stops when the output reaches n bytes
+// and the rest of data is not decoded. If data decompresses to fewer
+// than n bytes, the function returns all of the decompressed data.
+func DecompressPrefix(data []byte, algo Algorithm, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("Invalid prefix length %d", n)
+	}
+	if n == 0 {
+		return []byte{}, nil
+	}
+	d := &decoder{
+		sized:  true,
+		size:   n,
+		prefix: true,
+	}
+	err := d.decode(algo, data)
+	if err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// DecompressLZ77HuffmanLimit decompresses the LZ77+Huffman data like
+// DecompressLZ77Huffman and fails with ErrOutputTooLarge if the output
+// would exceed maxOut bytes. The limit is checked before each token
+// is added to the output. The initial contents of out do not count
+// toward the limit. The value 0 means no limit.
+func DecompressLZ77HuffmanLimit(data, out []byte, maxOut int) (
+	[]byte, error) {
+
+	return decompressLimit(AlgorithmLZ77Huffman, data, out, maxOut)
+}
+
+// DecompressLZ77Limit decompresses the LZ77 data like DecompressLZ77
+// and fails with ErrOutputTooLarge if the output would exceed maxOut
+// bytes. See DecompressLZ77HuffmanLimit for the limit.
+func DecompressLZ77Limit(data, out []byte, maxOut int) ([]byte, error) {
+	return decompressLimit(AlgorithmLZ77, data, out, maxOut)
+}
+
+// DecompressLZNT1Limit decompresses the LZNT1 data like
+// DecompressLZNT1 and fails with ErrOutputTooLarge if the output
+// would exceed maxOut bytes. See DecompressLZ77HuffmanLimit for the
+// limit.
+func DecompressLZNT1Limit(data, out []byte, maxOut int) ([]byte, error) {
+	return decompressLimit(AlgorithmLZNT1, data, out, maxOut)
+}
+
+func decompressLimit(algo Algorithm, data, out []byte, maxOut int) (
+	[]byte, error) {
+
+	if maxOut < 0 {
+		return nil, fmt.Errorf("Invalid output limit %d", maxOut)
+	}
+	return DecompressWithOptions(algo, data, out, &Options{
+		MaxOutput: maxOut,
+	})
+}
+
+// newDecoder creates a decoder for the options opts. The decoder
+// appends its output to out.
+func newDecoder(out []byte, opts *Options) (*decoder, error) {
+	d := &decoder{
+		out:   out,
+		start: len(out),
+	}
+	if opts != nil {
+		d.maxOut = opts.MaxOutput
+		d.maxMatches = opts.MaxMatches
+		d.maxExpansion = opts.MaxExpansion
+		d.sized = opts.Size > 0
+		d.size = opts.Size
+		d.profile = opts.Profile
+		d.lenient = opts.Lenient
+		d.strict = opts.Strict
+		d.partial = opts.Partial
+		d.ctx = opts.Context
+		d.policy = opts.Policy
+		d.matchSymbols = opts.MatchSymbols
+		d.chunker = opts.Chunker
+		d.chunked = len(out)
+		if _, ok := profileNames[d.profile]; !ok {
+			return nil, fmt.Errorf("Unknown profile %s", d.profile)
+		}
+		if d.profile == Profile7Zip && !d.sized {
+			return nil, ErrSizeRequired
+		}
+		d.resolve = opts.Resolver
+		d.allowOffset = opts.AllowOffset
+		if len(opts.ResetPoints) > 0 {
+			d.resets = append([]int{}, opts.ResetPoints...)
+			sort.Ints(d.resets)
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,758 @@
+//
+// options_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	// The history is kept in non-contiguous segments, oldest first.
+	segments := [][]byte{
+		[]byte("hello, "),
+		[]byte("world"),
+	}
+	var history []byte
+	for _, seg := range segments {
+		history = append(history, seg...)
+	}
+	resolver := func(offset int) byte {
+		for i := len(segments) - 1; i >= 0; i-- {
+			if offset <= len(segments[i]) {
+				return segments[i][len(segments[i])-offset]
+			}
+			offset -= len(segments[i])
+		}
+		t.Fatalf("offset out of range")
+		return 0
+	}
+
+	// Encode the tokens manually so that the first match refers to
+	// the history.
+	e := newLZ77Encoder(nil)
+	e.match(len(history), 5)
+	e.literal('!')
+	e.match(6, 12)
+	data := e.finish()
+
+	out, err := DecompressWithOptions(AlgorithmLZ77, data, nil, &Options{
+		Resolver: resolver,
+	})
+	if err != nil {
+		t.Fatalf("DecompressWithOptions failed: %s", err)
+	}
+	expected := []byte("hello!hello!hello!")
+	if !bytes.Equal(out, expected) {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman,
+		huffmanOffsetStream(0x0000), nil, &Options{
+			Resolver: func(offset int) byte {
+				return byte(offset)
+			},
+		})
+	if err != nil {
+		t.Errorf("DecompressWithOptions failed: %s", err)
+	}
+}
+
+func TestAllowOffset(t *testing.T) {
+	// The offsets 4 and 3 with the power-of-two profile.
+	e := newLZ77Encoder(nil)
+	for _, b := range []byte("abcd") {
+		e.literal(b)
+	}
+	e.match(4, 4)
+	e.match(3, 3)
+	data := e.finish()
+
+	powerOfTwo := func(offset int) bool {
+		return offset&(offset-1) == 0
+	}
+	_, err := DecompressWithOptions(AlgorithmLZ77, data, nil, &Options{
+		AllowOffset: powerOfTwo,
+	})
+	if !errors.Is(err, ErrDisallowedOffset) {
+		t.Errorf("got %v, expected %v", err, ErrDisallowedOffset)
+	}
+
+	var offsets []int
+	out, err := DecompressWithOptions(AlgorithmLZ77, data, nil, &Options{
+		AllowOffset: func(offset int) bool {
+			offsets = append(offsets, offset)
+			return offset < 8
+		},
+	})
+	if err != nil {
+		t.Fatalf("DecompressWithOptions failed: %s", err)
+	}
+	if string(out) != "abcdabcdbcd" {
+		t.Errorf("got %q, expected %q", out, "abcdabcdbcd")
+	}
+	if len(offsets) != 2 || offsets[0] != 4 || offsets[1] != 3 {
+		t.Errorf("checked offsets %v, expected [4 3]", offsets)
+	}
+}
+
+func TestResetPoints(t *testing.T) {
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	e.literal('b')
+	e.literal('c')
+	e.match(3, 3)
+	e.literal('d')
+	e.match(1, 3)
+	data := e.finish()
+
+	tests := []struct {
+		resets []int
+		err    error
+	}{
+		{nil, nil},
+		{[]int{6}, nil},
+		{[]int{6, 0}, nil},
+		{[]int{7}, ErrCrossResetReference},
+		{[]int{2}, ErrCrossResetReference},
+		{[]int{5}, ErrCrossResetReference},
+		{[]int{10, 3}, ErrCrossResetReference},
+		{[]int{9}, ErrCrossResetReference},
+	}
+	for _, test := range tests {
+		out, err := DecompressWithOptions(AlgorithmLZ77, data, nil, &Options{
+			ResetPoints: test.resets,
+		})
+		if err != test.err {
+			t.Errorf("resets %v: got error %v, expected %v",
+				test.resets, err, test.err)
+			continue
+		}
+		if err == nil && string(out) != "abcabcdddd" {
+			t.Errorf("resets %v: unexpected output %q", test.resets, out)
+		}
+	}
+}
+
+func TestMaxOutput(t *testing.T) {
+	lz77 := []byte{
+		0xff, 0xff, 0xff, 0x1f, 'a', 'b', 'c', 0x10, 0x00,
+	}
+	huffman, err := CompressLZ77Huffman([]byte("abcabc"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, algo := range []Algorithm{AlgorithmLZ77, AlgorithmLZ77Huffman} {
+		data := lz77
+		if algo == AlgorithmLZ77Huffman {
+			data = huffman
+		}
+		// The final match fills the output exactly to the limit.
+		for _, prefix := range [][]byte{nil, []byte("xyz")} {
+			out, err := DecompressWithOptions(algo, data,
+				append([]byte{}, prefix...), &Options{
+					MaxOutput: 6,
+				})
+			if err != nil {
+				t.Errorf("%s: limit 6: %s", algo, err)
+			} else if string(out) != string(prefix)+"abcabc" {
+				t.Errorf("%s: limit 6: unexpected output %q", algo, out)
+			}
+		}
+		_, err := DecompressWithOptions(algo, data, nil, &Options{
+			MaxOutput: 5,
+		})
+		if err != ErrOutputTooLarge {
+			t.Errorf("%s: limit 5: expected ErrOutputTooLarge, got %v",
+				algo, err)
+		}
+	}
+
+	// An LZNT1 chunk that fills the size exactly is accepted.
+	out, err := DecompressLZNT1Sized(lznt1Uncompressed("abcdef"), 6)
+	if err != nil || string(out) != "abcdef" {
+		t.Errorf("DecompressLZNT1Sized: %q, %v", out, err)
+	}
+}
+
+func TestMaxExpansion(t *testing.T) {
+	zeros := make([]byte, 1<<20)
+	for _, algo := range []Algorithm{
+		AlgorithmLZ77, AlgorithmLZ77Huffman, AlgorithmLZNT1,
+	} {
+		var data []byte
+		var err error
+		switch algo {
+		case AlgorithmLZ77:
+			data, err = CompressLZ77(zeros)
+		case AlgorithmLZ77Huffman:
+			data, err = CompressLZ77Huffman(zeros, nil)
+		case AlgorithmLZNT1:
+			data = lznt1Inputs[0]
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := DecompressWithOptions(algo, data, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := len(plain)
+		ratio := (expected + len(data) - 1) / len(data)
+
+		out, err := DecompressWithOptions(algo, data, []byte("xyz"),
+			&Options{MaxExpansion: ratio})
+		if err != nil || len(out) != 3+expected {
+			t.Errorf("%s: ratio %d: %d bytes, %v", algo, ratio, len(out), err)
+		}
+		_, err = DecompressWithOptions(algo, data, nil,
+			&Options{MaxExpansion: expected / (len(data) + 1)})
+		if err != ErrExpansionLimit {
+			t.Errorf("%s: expected %v, got %v", algo, ErrExpansionLimit, err)
+		}
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	zeros := make([]byte, 1<<20)
+	lz77, err := CompressLZ77(zeros)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(zeros, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.Repeat("abcdefgh", 512)
+	lznt1 := lznt1Uncompressed(text, text, text)
+
+	tests := []struct {
+		algo       Algorithm
+		decompress func(data, out []byte, maxOut int) ([]byte, error)
+		data       []byte
+		size       int
+	}{
+		{AlgorithmLZ77, DecompressLZ77Limit, lz77, len(zeros)},
+		{AlgorithmLZ77Huffman, DecompressLZ77HuffmanLimit, huffman,
+			len(zeros)},
+		{AlgorithmLZNT1, DecompressLZNT1Limit, lznt1, 3 * len(text)},
+	}
+	for _, test := range tests {
+		_, err := test.decompress(test.data, nil, 1000)
+		if err != ErrOutputTooLarge {
+			t.Errorf("%s: got %v, expected ErrOutputTooLarge", test.algo, err)
+		}
+		// The initial contents of out do not count toward the limit
+		// and the output does not grow beyond the limit.
+		prefix := []byte("prefix")
+		out, err := test.decompress(test.data, prefix, test.size)
+		if err != nil {
+			t.Errorf("%s: limit %d: %s", test.algo, test.size, err)
+			continue
+		}
+		if len(out) != len(prefix)+test.size {
+			t.Errorf("%s: got %d bytes", test.algo, len(out))
+		}
+		if test.algo != AlgorithmLZNT1 && cap(out) > len(out) {
+			t.Errorf("%s: output capacity %d exceeds the limit",
+				test.algo, cap(out))
+		}
+		_, err = test.decompress(test.data, nil, test.size-1)
+		if err != ErrOutputTooLarge {
+			t.Errorf("%s: limit %d: got %v", test.algo, test.size-1, err)
+		}
+	}
+	if _, err := DecompressLZ77Limit(lz77, nil, -1); err == nil {
+		t.Errorf("negative limit accepted")
+	}
+}
+
+func TestPartial(t *testing.T) {
+	data := append(randomBytes(11, 3000), repeatedMatch(100000)...)
+	data = append(data, randomBytes(12, 3000)...)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{
+		Partial: true,
+	}
+	for _, l := range []int{0, 100, 256, 300, len(compressed) / 2,
+		len(compressed) - 1} {
+		_, err := DecompressWithOptions(AlgorithmLZ77Huffman, compressed[:l],
+			nil, opts)
+		if err != ErrIncomplete {
+			t.Errorf("prefix %d: got %v, expected %v", l, err, ErrIncomplete)
+		}
+	}
+	out, err := DecompressWithOptions(AlgorithmLZ77Huffman, compressed, nil,
+		opts)
+	if err != nil || !bytes.Equal(out, data) {
+		t.Errorf("full stream: %v", err)
+	}
+
+	// The corrupt data is not incomplete.
+	corrupt := append([]byte(nil), compressed...)
+	for i := 0; i < 256; i++ {
+		corrupt[i] = 0xff
+	}
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman, corrupt, nil, opts)
+	if err == nil || err == ErrIncomplete {
+		t.Errorf("corrupt table: got %v", err)
+	}
+
+	text := strings.Repeat("abcdefgh", 512)
+	lznt1 := lznt1Uncompressed(text, text)
+	_, err = DecompressWithOptions(AlgorithmLZNT1, lznt1[:5000], nil, opts)
+	if err != ErrIncomplete {
+		t.Errorf("LZNT1: got %v, expected %v", err, ErrIncomplete)
+	}
+	_, err = DecompressToWriter(AlgorithmLZ77Huffman, compressed[:1000],
+		io.Discard, opts)
+	if err != ErrIncomplete {
+		t.Errorf("DecompressToWriter: got %v, expected %v", err, ErrIncomplete)
+	}
+}
+
+func TestSizeTrailingPadding(t *testing.T) {
+	data := append(randomBytes(13, 3000), repeatedMatch(3000)...)
+	compressed, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, padding := range []int{1, 2, 7, 512} {
+		padded := append(append([]byte(nil), compressed...),
+			make([]byte, padding)...)
+
+		// Without the size, the padding decodes as tokens.
+		out, err := DecompressLZ77(padded)
+		if err == nil && bytes.Equal(out, data) {
+			t.Errorf("padding %d: decoded without the size", padding)
+		}
+		out, err = DecompressWithOptions(AlgorithmLZ77, padded, nil, &Options{
+			Size: len(data),
+		})
+		if err != nil {
+			t.Errorf("padding %d: %s", padding, err)
+		} else if !bytes.Equal(out, data) {
+			t.Errorf("padding %d: output mismatch", padding)
+		}
+	}
+}
+
+func TestDecompressFullChunk(t *testing.T) {
+	// A 32 KB chunk in the 7-Zip layout. The standard profile decodes
+	// the last symbol 256 as a match and fails in the padding.
+	symbols := make([]int, 0, 32*1024)
+	for i := 0; i < cap(symbols)-3; i++ {
+		symbols = append(symbols, 'a'+i%26)
+	}
+	symbols[len(symbols)-1] = 'z'
+	symbols = append(symbols, huffmanEOF)
+	data := huffmanSymbolStream(literalLengths(), symbols)
+	data = append(data, 0, 0, 0, 0)
+
+	out, profile, err := DecompressFullChunk(data, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatalf("DecompressFullChunk failed: %s", err)
+	}
+	if profile != Profile7Zip {
+		t.Errorf("got profile %s, expected %s", profile, Profile7Zip)
+	}
+	if len(out) != 32*1024 || !bytes.HasSuffix(out, []byte("zzzz")) {
+		t.Errorf("unexpected output of %d bytes", len(out))
+	}
+
+	compressed, err := CompressLZ77Huffman(out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out2, profile, err := DecompressFullChunk(compressed, AlgorithmLZ77Huffman)
+	if err != nil || profile != ProfileStandard || !bytes.Equal(out2, out) {
+		t.Errorf("standard stream: profile %s, %v", profile, err)
+	}
+
+	_, _, err = DecompressFullChunk(data[:1000], AlgorithmLZ77Huffman)
+	if !truncation(err) {
+		t.Errorf("got %v, expected %v", err, ErrTruncatedInput)
+	}
+
+	// A short chunk does not have a full chunk size. It decodes to
+	// the last 1002 bytes of the full chunk with its size.
+	short := huffmanSymbolStream(literalLengths(),
+		append(symbols[len(symbols)-1000:len(symbols)-1], huffmanEOF))
+	short = append(short, 0, 0, 0, 0)
+	_, _, err = DecompressFullChunk(short, AlgorithmLZ77Huffman)
+	if err == nil {
+		t.Errorf("short chunk decoded")
+	}
+	out2, err = DecompressWithOptions(AlgorithmLZ77Huffman, short, nil,
+		&Options{
+			Size:    1002,
+			Profile: Profile7Zip,
+		})
+	if err != nil {
+		t.Fatalf("short chunk: %s", err)
+	}
+	if !bytes.Equal(out2, out[len(out)-1002:]) {
+		t.Errorf("short chunk: output mismatch")
+	}
+
+	// The truncated and the garbage-tailed streams would decode to
+	// their first 32 KB with Profile7Zip. The other algorithms are not
+	// retried and the LZ77+Huffman streams must be consumed.
+	plain := randomBytes(16, 50*1024)
+	lz77, err := CompressLZ77(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lznt1, err := CompressLZNT1(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(plain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		algo Algorithm
+		data []byte
+	}{
+		{AlgorithmLZ77, lz77[:len(lz77)-100]},
+		{AlgorithmLZ77, append(append([]byte{}, lz77...), 0xff, 0xff, 0xff)},
+		{AlgorithmLZNT1, lznt1[:len(lznt1)-100]},
+		{AlgorithmLZ77Huffman, huffman[:len(huffman)-100]},
+		{AlgorithmLZ77Huffman, append(append([]byte{}, huffman...), 0xff, 0xff)},
+	} {
+		out, profile, err := DecompressFullChunk(test.data, test.algo)
+		if err == nil {
+			t.Errorf("%s: decoded %d bytes with profile %s", test.algo,
+				len(out), profile)
+		}
+	}
+}
+
+func TestProfile7Zip(t *testing.T) {
+	// A crafted chunk in the 7-Zip layout: the last symbol 256 is a
+	// match and the stream is not terminated by the end-of-stream
+	// marker. The chunk is followed by padding.
+	data := huffmanSymbolStream(literalLengths(),
+		[]int{'a', 'b', 'c', huffmanEOF})
+	data = append(data, 0, 0, 0, 0)
+	expected := []byte("abcccc")
+
+	opts := &Options{
+		Size:    len(expected),
+		Profile: Profile7Zip,
+	}
+	out, err := DecompressWithOptions(AlgorithmLZ77Huffman, data, nil, opts)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if !bytes.Equal(out, expected) {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+
+	opts.Size = len(expected) - 1
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman, data, nil, opts)
+	if err != ErrChunkOverrun {
+		t.Errorf("size %d: got %v, expected %v", opts.Size, err,
+			ErrChunkOverrun)
+	}
+
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman, data, nil,
+		&Options{Profile: Profile7Zip})
+	if err != ErrSizeRequired {
+		t.Errorf("got %v, expected %v", err, ErrSizeRequired)
+	}
+
+	// Plain LZ77 chunk that ends at the chunk size.
+	e := newLZ77Encoder(nil)
+	for _, b := range expected {
+		e.literal(b)
+	}
+	data = append(e.finish(), 0, 0)
+	opts.Size = len(expected)
+	out, err = DecompressWithOptions(AlgorithmLZ77, data, nil, opts)
+	if err != nil {
+		t.Fatalf("LZ77 decompress failed: %s", err)
+	}
+	if !bytes.Equal(out, expected) {
+		t.Errorf("LZ77: got %q, expected %q", out, expected)
+	}
+}
+
+func TestLenient(t *testing.T) {
+	huffmanOverrun := huffmanSymbolStream(literalLengths(),
+		[]int{'a', 'b', 'c', huffmanEOF, 'd', huffmanEOF})
+
+	// The symbol 271 is a match with offset 1 and an extended
+	// length, but the stream ends before the length byte.
+	var lengths [huffmanSymbols]uint8
+	lengths['a'] = 1
+	lengths[huffmanEOF] = 2
+	lengths[271] = 2
+	huffmanTruncated := huffmanSymbolStream(&lengths, []int{'a', 271})
+
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	e.match(1, 10)
+	lz77Overrun := e.finish()
+
+	// The match token declares an extended length, but the stream
+	// ends before the length nibble.
+	e = newLZ77Encoder(nil)
+	e.literal('a')
+	e.flag(1)
+	e.putUint16(7)
+	lz77Truncated := e.finish()
+
+	tests := []struct {
+		algo     Algorithm
+		data     []byte
+		size     int
+		strict   error
+		expected string
+	}{
+		{AlgorithmLZ77Huffman, huffmanOverrun, 5, ErrChunkOverrun, "abccc"},
+		{AlgorithmLZ77Huffman, huffmanTruncated, 0, TruncatedInput,
+			strings.Repeat("a", 19)},
+		{AlgorithmLZ77, lz77Overrun, 5, ErrChunkOverrun, "aaaaa"},
+		{AlgorithmLZ77, lz77Truncated, 0, ErrTruncatedLengthNibble,
+			strings.Repeat("a", 11)},
+	}
+	for idx, test := range tests {
+		opts := &Options{
+			Size: test.size,
+		}
+		_, err := DecompressWithOptions(test.algo, test.data, nil, opts)
+		if err != test.strict {
+			t.Errorf("test %d: strict: got %v, expected %v",
+				idx, err, test.strict)
+		}
+		opts.Lenient = true
+		out, err := DecompressWithOptions(test.algo, test.data, nil, opts)
+		if err != nil {
+			t.Errorf("test %d: lenient: %s", idx, err)
+			continue
+		}
+		if string(out) != test.expected {
+			t.Errorf("test %d: lenient: got %q, expected %q",
+				idx, out, test.expected)
+		}
+	}
+}
+
+func TestMaxMatches(t *testing.T) {
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	for i := 0; i < 10; i++ {
+		e.match(1, 3)
+	}
+	data := e.finish()
+
+	for _, limit := range []int{0, 10, 11} {
+		out, err := DecompressWithOptions(AlgorithmLZ77, data, nil,
+			&Options{MaxMatches: limit})
+		if err != nil {
+			t.Errorf("limit %d: %s", limit, err)
+		} else if len(out) != 31 {
+			t.Errorf("limit %d: got %d bytes, expected 31", limit, len(out))
+		}
+	}
+	for _, limit := range []int{1, 9} {
+		_, err := DecompressWithOptions(AlgorithmLZ77, data, nil,
+			&Options{MaxMatches: limit})
+		if err != ErrTooManyMatches {
+			t.Errorf("limit %d: got %v, expected %v", limit, err,
+				ErrTooManyMatches)
+		}
+	}
+}
+
+type segmentWriter struct {
+	segments [][]byte
+}
+
+func (w *segmentWriter) Write(p []byte) (int, error) {
+	w.segments = append(w.segments, append([]byte{}, p...))
+	return len(p), nil
+}
+
+func TestFlushSize(t *testing.T) {
+	var data []byte
+	for i := 0; i < 5; i++ {
+		data = append(data, randomBytes(int64(i), 30000)...)
+		data = append(data, repeatedMatch(70000+i)...)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, flushSize := range []int{0, 1000, 4096, 200000} {
+		w := new(segmentWriter)
+		n, err := DecompressToWriter(AlgorithmLZ77Huffman, compressed, w,
+			&Options{FlushSize: flushSize})
+		if err != nil {
+			t.Fatalf("flush size %d: %s", flushSize, err)
+		}
+		if n != int64(len(data)) {
+			t.Errorf("flush size %d: wrote %d bytes, expected %d",
+				flushSize, n, len(data))
+		}
+		var out []byte
+		for i, seg := range w.segments {
+			if flushSize > 0 && i < len(w.segments)-1 && len(seg) != flushSize {
+				t.Errorf("flush size %d: segment %d has %d bytes",
+					flushSize, i, len(seg))
+			}
+			out = append(out, seg...)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("flush size %d: output mismatch", flushSize)
+		}
+	}
+}
+
+func TestDecompressPrefix(t *testing.T) {
+	data := append(randomBytes(21, 1000), repeatedMatch(5000)...)
+	data = append(data, bytes.Repeat([]byte{0}, 10000)...)
+
+	streams := map[Algorithm][]byte{
+		AlgorithmLZNT1: lznt1Uncompressed(string(data[:3000]),
+			string(data[3000:4096])),
+	}
+	compressed, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams[AlgorithmLZ77] = compressed
+	compressed, err = CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams[AlgorithmLZ77Huffman] = compressed
+
+	for algo, compressed := range streams {
+		full, err := DecompressWithOptions(algo, compressed, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: decompress failed: %s", algo, err)
+		}
+		for _, n := range []int{0, 1, 256, 1001, 2500, 4096, len(full),
+			len(full) + 100} {
+
+			// The stream is truncated after the prefix.
+			out, err := DecompressPrefix(compressed[:len(compressed)-1],
+				algo, n)
+			expected := n
+			if expected > len(full) {
+				expected = len(full)
+			}
+			if expected == len(full) {
+				// The prefix covers the full stream.
+				out, err = DecompressPrefix(compressed, algo, n)
+			}
+			if err != nil {
+				t.Fatalf("%s: DecompressPrefix(%d) failed: %s", algo, n, err)
+			}
+			if !bytes.Equal(out, full[:expected]) {
+				t.Errorf("%s: DecompressPrefix(%d): got %d bytes, expected %d",
+					algo, n, len(out), expected)
+			}
+		}
+	}
+}
+
+func TestMatchSymbols(t *testing.T) {
+	// The swapped mapping has the length header in the high nibble
+	// and the offset bits in the low nibble. The symbol 257 is a
+	// match of length 3 with 1 offset bit that is the symbol 272 in
+	// the standard mapping. The bits are a, b, c, 257 with the offset
+	// bit 1, and the terminator, see TestLZ77HuffmanOffsetBase.
+	swapped := func(symbol int) (int, int) {
+		symbol -= 256
+		return symbol / 16, symbol % 16
+	}
+	data := huffmanTable(map[int]int{
+		'a': 2,
+		'b': 2,
+		'c': 2,
+		256: 3,
+		257: 3,
+	})
+	data = append(data, 0xf0, 0x1b, 0x00, 0x00)
+
+	out, err := DecompressWithOptions(AlgorithmLZ77Huffman, data, nil,
+		&Options{MatchSymbols: swapped})
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if string(out) != "abcabc" {
+		t.Errorf("got %q, expected %q", out, "abcabc")
+	}
+
+	out, err = DecompressWithOptions(AlgorithmLZ77Huffman, data, nil,
+		&Options{MatchSymbols: StandardMatchSymbols})
+	if err == nil && string(out) == "abcabc" {
+		t.Errorf("standard mapping decoded the swapped stream")
+	}
+
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman, data, nil,
+		&Options{
+			MatchSymbols: func(symbol int) (int, int) {
+				return 3, 16
+			},
+		})
+	if !errors.Is(err, ErrInvalidData) {
+		t.Errorf("invalid mapping: got %v, expected %v", err, ErrInvalidData)
+	}
+}
+
+func TestDecodesTo(t *testing.T) {
+	var data []byte
+	for i := 0; i < 4; i++ {
+		data = append(data, randomBytes(int64(i), 100000)...)
+		data = append(data, repeatedMatch(200000+i)...)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal, err := DecodesTo(compressed, AlgorithmLZ77Huffman, data)
+	if err != nil || !equal {
+		t.Errorf("matching plaintext: %v, %v", equal, err)
+	}
+
+	// The decoding stops when the window emits the mismatching data.
+	// The window emits its output before it has buffered windowSize
+	// bytes so the decoder stops long before the end of the data.
+	other := append([]byte{}, data...)
+	other[10] ^= 1
+	equal, n, err := decodesTo(compressed, AlgorithmLZ77Huffman, other)
+	if err != nil || equal {
+		t.Errorf("mismatching plaintext: %v, %v", equal, err)
+	}
+	if n <= 10 || n > windowSize {
+		t.Errorf("decoded %d bytes of %d before stopping", n, len(data))
+	}
+
+	for _, plaintext := range [][]byte{data[:len(data)-1], append(data, 0)} {
+		equal, err = DecodesTo(compressed, AlgorithmLZ77Huffman, plaintext)
+		if err != nil || equal {
+			t.Errorf("%d byte plaintext: %v, %v", len(plaintext), equal, err)
+		}
+	}
+	_, err = DecodesTo(compressed[:1000], AlgorithmLZ77Huffman, data)
+	if err != TruncatedInput {
+		t.Errorf("truncated input: got %v, expected %v", err, TruncatedInput)
+	}
+}
@@ -0,0 +1,64 @@
+//
+// policy.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+// DecodeMetrics describe the progress of a decompression.
+type DecodeMetrics struct {
+	// Input is the number of input bytes consumed.
+	Input int
+	// Output is the number of decompressed bytes, excluding the
+	// initial contents of the output buffer.
+	Output int
+	// Matches is the number of match tokens.
+	Matches int
+	// MaxOffset is the longest match offset.
+	MaxOffset int
+}
+
+// Ratio returns the expansion ratio of the output to the consumed
+// input. The ratio is 0 if no input has been consumed.
+func (m DecodeMetrics) Ratio() float64 {
+	if m.Input == 0 {
+		return 0
+	}
+	return float64(m.Output) / float64(m.Input)
+}
+
+// Policy decides if a decompression can continue. The function
+// returns an error to abort the decompression.
+type Policy func(m DecodeMetrics) error
+
+// checkSize specifies how many output bytes the decoder produces
+// between the checks of its context and policy.
+const checkSize = 256 * 1024
+
+// check checks the decoder's context and policy. The decoders call
+// check at the block, flag word, and chunk boundaries and the
+// context and policy are checked after each checkSize bytes of
+// output.
+func (d *decoder) check(in *input) error {
+	if d.ctx == nil && d.policy == nil || d.produced() < d.nextCheck {
+		return nil
+	}
+	d.nextCheck = d.produced() + checkSize
+	if d.ctx != nil {
+		if err := d.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if d.policy != nil {
+		return d.policy(DecodeMetrics{
+			Input:     in.pos,
+			Output:    d.decoded(),
+			Matches:   d.matches,
+			MaxOffset: d.maxOffset,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+//
+// policy_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	errRatio := errors.New("Expansion ratio exceeded")
+	var calls int
+	var last DecodeMetrics
+	policy := func(m DecodeMetrics) error {
+		calls++
+		last = m
+		if m.Ratio() > 100 {
+			return errRatio
+		}
+		return nil
+	}
+
+	bomb, err := CompressLZ77Huffman(make([]byte, 16*1024*1024), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman, bomb, nil, &Options{
+		Policy: policy,
+	})
+	if err != errRatio {
+		t.Fatalf("got %v, expected %v", err, errRatio)
+	}
+	if last.Output >= 16*1024*1024 || last.Matches == 0 ||
+		last.MaxOffset != 1 {
+		t.Errorf("unexpected metrics %+v", last)
+	}
+	_, err = DecompressToWriter(AlgorithmLZ77Huffman, bomb, io.Discard,
+		&Options{
+			Policy: policy,
+		})
+	if err != errRatio {
+		t.Errorf("DecompressToWriter: got %v, expected %v", err, errRatio)
+	}
+
+	// The random data does not expand and the policy accepts it.
+	data := randomBytes(44, 2*checkSize)
+	lz77, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huffman, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls = 0
+	for algo, compressed := range map[Algorithm][]byte{
+		AlgorithmLZ77:        lz77,
+		AlgorithmLZ77Huffman: huffman,
+	} {
+		out, err := DecompressWithOptions(algo, compressed, nil, &Options{
+			Policy: policy,
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", algo, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("%s: output mismatch", algo)
+		}
+	}
+	if calls < 2 {
+		t.Errorf("policy called %d times", calls)
+	}
+}
@@ -0,0 +1,427 @@
+//
+// reader.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// HuffmanReader implements a streaming LZ77+Huffman decompressor. The
+// reader reads the compressed stream from the underlying reader as
+// the decompressed data is read. The reader keeps the match window in
+// a fixed-size circular buffer. The reader decodes the tokens with
+// the decoder's token path so the decoding options apply as with
+// DecompressWithOptions.
+type HuffmanReader struct {
+	r   io.Reader
+	d   *decoder
+	eof bool
+	err error
+
+	// buf holds the decoded data that the window has emitted and
+	// that is not yet read from off.
+	buf []byte
+	off int
+
+	// in holds the compressed data that is read from r and that is
+	// not yet decoded. The closed flag tells if the compressed data
+	// has ended.
+	in     input
+	closed bool
+
+	br       BitReader
+	table    decodingTable
+	blockEnd int
+	started  bool
+}
+
+// huffmanReaderBufferSize is the size of the HuffmanReader's input
+// buffer.
+const huffmanReaderBufferSize = 4096
+
+// NewHuffmanReader creates a new HuffmanReader that reads the
+// compressed stream from r.
+func NewHuffmanReader(r io.Reader) *HuffmanReader {
+	hr, _ := NewHuffmanReaderWithOptions(r, nil)
+	return hr
+}
+
+// NewHuffmanReaderWithOptions creates a new HuffmanReader that reads
+// the compressed stream from r and decodes it with the options opts.
+// The options that control the decoding of the tokens apply as with
+// DecompressWithOptions. The options Partial, VerifyChecksum,
+// Chunker, and FlushSize do not apply to the reader.
+func NewHuffmanReaderWithOptions(r io.Reader, opts *Options) (
+	*HuffmanReader, error) {
+
+	d, err := newDecoder(nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	d.chunker = nil
+	hr := &HuffmanReader{
+		r: r,
+		d: d,
+	}
+	hr.br.in = &hr.in
+	d.window = newWindow(func(p []byte) error {
+		hr.buf = append(hr.buf, p...)
+		return nil
+	}, 0)
+	return hr, nil
+}
+
+// Read reads the decompressed data into p. Read returns io.EOF at the
+// end of the compressed stream and TruncatedInput if the underlying
+// reader ends inside the stream.
+func (r *HuffmanReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.off == len(r.buf) {
+		r.buf = r.buf[:0]
+		r.off = 0
+	}
+	// The reader decodes at most MatchWindowSize bytes ahead so its
+	// buffer does not grow with p.
+	for r.buffered() < len(p) && r.buffered() < MatchWindowSize &&
+		!r.eof && r.err == nil {
+		r.err = r.token()
+	}
+	if r.off > 0 && r.d.window.pending() > 0 {
+		n := copy(r.buf, r.buf[r.off:])
+		r.buf = r.buf[:n]
+		r.off = 0
+	}
+	if err := r.d.window.flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	n := copy(p, r.buf[r.off:])
+	r.off += n
+	if n > 0 {
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// buffered returns the number of decoded bytes that are not yet read.
+func (r *HuffmanReader) buffered() int {
+	return len(r.buf) - r.off + r.d.window.pending()
+}
+
+// token decodes the next token of the stream. At the end of a block,
+// the function reads the Huffman table of the next block.
+func (r *HuffmanReader) token() error {
+	d := r.d
+	if !r.started || d.decoded() >= r.blockEnd {
+		err := r.block()
+		if err != nil || r.eof {
+			return err
+		}
+	}
+	if d.done() {
+		r.eof = true
+		return nil
+	}
+	if err := r.fill(pushTokenInput); err != nil {
+		return err
+	}
+	end, err := d.huffmanToken(&r.br, &r.table)
+	r.eof = end
+	return err
+}
+
+// block reads the Huffman table and the first two words of the next
+// block. The stream ends at a block boundary if the compressed data
+// ends there.
+func (r *HuffmanReader) block() error {
+	if err := r.fill(pushBlockInput); err != nil {
+		return err
+	}
+	in := &r.in
+	if in.Avail() == 0 && r.started {
+		r.eof = true
+		return nil
+	}
+	if in.Avail() < 256 {
+		return TruncatedInput
+	}
+	if err := r.d.check(in); err != nil {
+		return err
+	}
+	r.started = true
+	if err := r.table.init(in.input[in.pos : in.pos+256]); err != nil {
+		return err
+	}
+	in.pos += 256
+	end, err := r.d.huffmanStart(&r.br, &r.table)
+	if err != nil {
+		return err
+	}
+	r.eof = end
+	r.blockEnd = r.d.decoded() + huffmanBlockSize
+	return nil
+}
+
+// fill reads the compressed data from the underlying reader until
+// the input buffer has need bytes after the loaded words or the
+// compressed data ends. With need bytes, the decoder does not see
+// the end of input inside a token or a block start, see
+// pushTokenInput.
+func (r *HuffmanReader) fill(need int) error {
+	return fillInput(r.r, &r.in, &r.closed, need, huffmanReaderBufferSize)
+}
+
+// compact drops the decoded bytes from the input buffer.
+func (r *HuffmanReader) compact() {
+	compactInput(&r.in)
+}
+
+// fillInput reads the compressed data from r to the input buffer in
+// until in has need bytes or r ends. The buffer is allocated with
+// size bytes. The closed flag is set when r ends.
+func fillInput(r io.Reader, in *input, closed *bool, need, size int) error {
+	if in.Avail() >= need || *closed {
+		return nil
+	}
+	compactInput(in)
+	if in.input == nil {
+		in.input = make([]byte, 0, size)
+	}
+	for in.Avail() < need && !*closed {
+		n, err := r.Read(in.input[len(in.input):cap(in.input)])
+		in.input = in.input[:len(in.input)+n]
+		if err == io.EOF {
+			*closed = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactInput drops the decoded bytes from the input buffer.
+func compactInput(in *input) {
+	n := copy(in.input, in.input[in.pos:])
+	in.input = in.input[:n]
+	in.pos = 0
+}
+
+// The LZ77HuffmanDecoder decodes a token when the pending input has
+// the token's bytes and the byte after them. A token reads at most
+// two refill words and three match length bytes. A block start
+// reads the Huffman table and the first two words before the token.
+const (
+	pushTokenInput = 2 + 3 + 2 + 1
+	pushBlockInput = 256 + 4 + pushTokenInput
+)
+
+// LZ77HuffmanDecoder implements an incremental LZ77+Huffman
+// decompressor. The compressed stream is pushed to the decoder in
+// chunks of any size and the decoder returns the decompressed data
+// as it becomes available. The decoder keeps the pending input and
+// the HuffmanReader state across the chunks so the symbols and the
+// tokens can straddle the chunk boundaries.
+type LZ77HuffmanDecoder struct {
+	r *HuffmanReader
+}
+
+// NewLZ77HuffmanDecoder creates a new incremental LZ77+Huffman
+// decoder.
+func NewLZ77HuffmanDecoder() *LZ77HuffmanDecoder {
+	return &LZ77HuffmanDecoder{
+		r: NewHuffmanReader(nil),
+	}
+}
+
+// Push adds the chunk to the pending input and decodes the tokens
+// that the pending input completes. The function returns the
+// decompressed data of the tokens. The returned data is valid until
+// the next call of Push or Close.
+func (d *LZ77HuffmanDecoder) Push(chunk []byte) ([]byte, error) {
+	d.r.compact()
+	d.r.in.input = append(d.r.in.input, chunk...)
+	return d.decode()
+}
+
+// Close ends the input and decodes the rest of the pending input.
+// The function returns the rest of the decompressed data.
+// Close returns TruncatedInput if the input ended inside the stream.
+func (d *LZ77HuffmanDecoder) Close() ([]byte, error) {
+	d.r.closed = true
+	out, err := d.decode()
+	if err == nil && !d.r.eof {
+		err = TruncatedInput
+	}
+	return out, err
+}
+
+func (d *LZ77HuffmanDecoder) decode() ([]byte, error) {
+	r := d.r
+	r.buf = r.buf[:0]
+	r.off = 0
+	for !r.eof && r.err == nil {
+		need := pushTokenInput
+		if !r.started || r.d.decoded() >= r.blockEnd {
+			need = pushBlockInput
+		}
+		if !r.closed && r.in.Avail() < need {
+			break
+		}
+		r.err = r.token()
+	}
+	if err := r.d.window.flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.buf, r.err
+}
+
+// streamReader implements a streaming LZ77 and LZNT1 decompressor.
+// Like HuffmanReader, the reader reads the compressed stream from the
+// underlying reader as the decompressed data is read and keeps the
+// match window in the decoder's window.
+type streamReader struct {
+	r    io.Reader
+	algo Algorithm
+	d    *decoder
+	eof  bool
+	err  error
+
+	buf []byte
+	off int
+
+	in      input
+	closed  bool
+	started bool
+
+	lz77 lz77State
+}
+
+// The streamReader decodes an LZ77 token when the input has the
+// token's bytes: the flags word, the match token, the length nibble
+// byte, the length byte, and the 16-bit length. The input buffer
+// holds a complete LZNT1 chunk with its header.
+const (
+	streamTokenInput       = 4 + 2 + 1 + 1 + 2
+	streamReaderBufferSize = 2 * lznt1ChunkSize
+)
+
+// newStreamReader creates a new streamReader that reads the algo
+// compressed stream from r.
+func newStreamReader(r io.Reader, algo Algorithm) *streamReader {
+	sr := &streamReader{
+		r:    r,
+		algo: algo,
+		d:    &decoder{},
+	}
+	sr.d.window = newWindow(func(p []byte) error {
+		sr.buf = append(sr.buf, p...)
+		return nil
+	}, 0)
+	return sr
+}
+
+// Read reads the decompressed data into p. Read returns io.EOF at the
+// end of the compressed stream and TruncatedInput if the underlying
+// reader ends inside the stream.
+func (r *streamReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.off == len(r.buf) {
+		r.buf = r.buf[:0]
+		r.off = 0
+	}
+	for r.buffered() < len(p) && r.buffered() < MatchWindowSize &&
+		!r.eof && r.err == nil {
+		if r.algo == AlgorithmLZNT1 {
+			r.err = r.chunk()
+		} else {
+			r.err = r.tokens()
+		}
+	}
+	if r.off > 0 && r.d.window.pending() > 0 {
+		n := copy(r.buf, r.buf[r.off:])
+		r.buf = r.buf[:n]
+		r.off = 0
+	}
+	if err := r.d.window.flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	n := copy(p, r.buf[r.off:])
+	r.off += n
+	if n > 0 {
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// buffered returns the number of decoded bytes that are not yet read.
+func (r *streamReader) buffered() int {
+	return len(r.buf) - r.off + r.d.window.pending()
+}
+
+// fill reads the compressed data from the underlying reader until
+// the input buffer has need bytes or the compressed data ends.
+func (r *streamReader) fill(need int) error {
+	return fillInput(r.r, &r.in, &r.closed, need, streamReaderBufferSize)
+}
+
+// tokens decodes the LZ77 tokens of the buffered input. The tokens
+// are decoded until the decoder has MatchWindowSize bytes buffered.
+func (r *streamReader) tokens() error {
+	if err := r.fill(streamTokenInput); err != nil {
+		return err
+	}
+	if !r.started && r.closed && r.in.Avail() == 0 {
+		// The empty stream is the compression of empty data.
+		r.eof = true
+		return nil
+	}
+	r.started = true
+	reserve := streamTokenInput
+	if r.closed {
+		reserve = 0
+	}
+	limit := r.d.produced() + MatchWindowSize - r.buffered()
+	end, err := r.d.lz77Tokens(&r.in, &r.lz77, reserve, limit)
+	r.eof = end
+	return err
+}
+
+// chunk decodes the next LZNT1 chunk. The stream ends at the end of
+// the compressed data or at the zero chunk header.
+func (r *streamReader) chunk() error {
+	if err := r.fill(2); err != nil {
+		return err
+	}
+	in := &r.in
+	if in.Avail() == 0 || lznt1End(in) {
+		r.eof = true
+		return nil
+	}
+	n := 2
+	if in.Avail() >= 2 {
+		n += int(binary.LittleEndian.Uint16(in.input[in.pos:])&0xfff) + 1
+	}
+	if err := r.fill(n); err != nil {
+		return err
+	}
+	n = min(n, in.Avail())
+	err := r.d.lznt1(in.input[in.pos : in.pos+n])
+	in.pos += n
+	return err
+}
@@ -0,0 +1,196 @@
+//
+// reader_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestHuffmanReader(t *testing.T) {
+	for i, data := range lz77HuffmanInputs {
+		expected, err := DecompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(NewHuffmanReader(iotest.OneByteReader(
+			bytes.NewReader(data))))
+		if err != nil {
+			t.Fatalf("input %d: %s", i, err)
+		}
+		if !bytes.Equal(out, expected) {
+			t.Errorf("input %d: output mismatch", i)
+		}
+	}
+
+	var data []byte
+	for i := 0; i < 40; i++ {
+		data = append(data, randomBytes(int64(60+i), 20000)...)
+		data = append(data, data[len(data)-15000:]...)
+		data = append(data, repeatedMatch(30000+i)...)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewHuffmanReader(bytes.NewReader(compressed))
+	var out bytes.Buffer
+	n, err := io.Copy(&out, r)
+	if err != nil {
+		t.Fatalf("io.Copy failed: %s", err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("got %d bytes, expected %d", n, len(data))
+	}
+	if cap(r.buf) > 8*MatchWindowSize || len(r.d.out) != 0 {
+		t.Errorf("reader retains %d bytes", cap(r.buf)+cap(r.d.out))
+	}
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("read after EOF: got %d, %v", n, err)
+	}
+
+	// The truncated stream returns the decoded data before the error.
+	r = NewHuffmanReader(bytes.NewReader(compressed[:len(compressed)/3]))
+	out.Reset()
+	_, err = io.Copy(&out, r)
+	if err != TruncatedInput {
+		t.Errorf("got %v, expected %v", err, TruncatedInput)
+	}
+	if out.Len() == 0 || !bytes.Equal(out.Bytes(), data[:out.Len()]) {
+		t.Errorf("got %d bytes before the error", out.Len())
+	}
+	for _, l := range []int{0, 100} {
+		_, err = io.ReadAll(NewHuffmanReader(bytes.NewReader(compressed[:l])))
+		if err != TruncatedInput {
+			t.Errorf("%d bytes: got %v, expected %v", l, err, TruncatedInput)
+		}
+	}
+}
+
+func TestLZ77HuffmanDecoder(t *testing.T) {
+	var large []byte
+	for i := 0; i < 4; i++ {
+		large = append(large, randomBytes(int64(i), 30000)...)
+		large = append(large, repeatedMatch(40000+i)...)
+	}
+	compressed, err := CompressLZ77Huffman(large, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := append([][]byte{compressed}, lz77HuffmanInputs...)
+
+	for i, data := range inputs {
+		expected, err := DecompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, chunkSize := range []int{1, 3, 7, 256, 1000, len(data)} {
+			d := NewLZ77HuffmanDecoder()
+			var out []byte
+			for pos := 0; pos < len(data); pos += chunkSize {
+				produced, err := d.Push(data[pos:min(len(data),
+					pos+chunkSize)])
+				if err != nil {
+					t.Fatalf("input %d: chunk %d: Push failed: %s",
+						i, chunkSize, err)
+				}
+				out = append(out, produced...)
+			}
+			produced, err := d.Close()
+			if err != nil {
+				t.Fatalf("input %d: chunk %d: Close failed: %s",
+					i, chunkSize, err)
+			}
+			out = append(out, produced...)
+			if !bytes.Equal(out, expected) {
+				t.Errorf("input %d: chunk %d: output mismatch", i, chunkSize)
+			}
+		}
+	}
+
+	// The decoder emits the output before the end of input.
+	d := NewLZ77HuffmanDecoder()
+	produced, err := d.Push(compressed[:len(compressed)/3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(produced) == 0 || !bytes.Equal(produced, large[:len(produced)]) {
+		t.Errorf("first third produced %d bytes", len(produced))
+	}
+	if _, err := d.Close(); err != TruncatedInput {
+		t.Errorf("truncated stream: got %v, expected %v", err, TruncatedInput)
+	}
+}
+
+func TestHuffmanReaderOptions(t *testing.T) {
+	read := func(data []byte, opts *Options) ([]byte, error) {
+		r, err := NewHuffmanReaderWithOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	// The swapped match symbols, see TestMatchSymbols.
+	swapped := huffmanTable(map[int]int{
+		'a': 2,
+		'b': 2,
+		'c': 2,
+		256: 3,
+		257: 3,
+	})
+	swapped = append(swapped, 0xf0, 0x1b, 0x00, 0x00)
+	out, err := read(swapped, &Options{
+		MatchSymbols: func(symbol int) (int, int) {
+			symbol -= 256
+			return symbol / 16, symbol % 16
+		},
+	})
+	if err != nil || string(out) != "abcabc" {
+		t.Errorf("match symbols: got %q, %v", out, err)
+	}
+
+	// The padding bit after the marker, see
+	// TestDecompressLZ77HuffmanStrict.
+	compressed, expected, _ := DecodeKnownVector("lz77huffman-literals")
+	padded := append([]byte{}, compressed...)
+	padded[len(padded)-2] |= 1
+	out, err = read(padded, nil)
+	if err != nil || !bytes.Equal(out, expected) {
+		t.Errorf("padding bit: got %q, %v", out, err)
+	}
+	_, err = read(padded, &Options{Strict: true})
+	if err != ErrTrailingBits {
+		t.Errorf("strict: got %v, expected %v", err, ErrTrailingBits)
+	}
+	_, err = DecompressWithOptions(AlgorithmLZ77Huffman, padded, nil,
+		&Options{Strict: true})
+	if err != ErrTrailingBits {
+		t.Errorf("strict: DecompressWithOptions: got %v, expected %v",
+			err, ErrTrailingBits)
+	}
+
+	// The truncated extended match length, see TestLenient.
+	var lengths [huffmanSymbols]uint8
+	lengths['a'] = 1
+	lengths[huffmanEOF] = 2
+	lengths[271] = 2
+	truncated := huffmanSymbolStream(&lengths, []int{'a', 271})
+	_, err = read(truncated, nil)
+	if err != TruncatedInput {
+		t.Errorf("truncated: got %v, expected %v", err, TruncatedInput)
+	}
+	out, err = read(truncated, &Options{Lenient: true})
+	if err != nil || string(out) != strings.Repeat("a", 19) {
+		t.Errorf("lenient: got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,74 @@
+//
+// stats.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+// SparseMinLength specifies the minimum length of the zero-fill
+// matches that are reported as sparse regions.
+const SparseMinLength = 4096
+
+// SparseRegion describes a zero-filled region of the decompressed
+// output.
+type SparseRegion struct {
+	// Offset is the offset of the region in the decompressed output.
+	Offset int
+	// Length is the length of the region.
+	Length int
+}
+
+// Stats contain decoding statistics.
+type Stats struct {
+	// Literals and Matches are the numbers of literal and match
+	// tokens. The bytes of the uncompressed LZNT1 chunks are counted
+	// as literals.
+	Literals int
+	Matches  int
+
+	// LiteralBytes and MatchBytes are the numbers of output bytes
+	// that the literals and matches produced.
+	LiteralBytes int64
+	MatchBytes   int64
+
+	// MaxMatchLength and MaxMatchOffset are the longest match length
+	// and offset. They are 0 if the stream has no matches.
+	MaxMatchLength int
+	MaxMatchOffset int
+
+	// SparseRegions are the zero-filled output regions of at least
+	// SparseMinLength bytes that the stream encodes with consecutive
+	// offset 1 matches after a zero byte.
+	SparseRegions []SparseRegion
+}
+
+// DecompressLZ77HuffmanStats decompresses the LZ77+Huffman data like
+// DecompressLZ77Huffman and returns the decoding statistics.
+func DecompressLZ77HuffmanStats(data, out []byte) ([]byte, Stats, error) {
+	var stats Stats
+	d := &decoder{
+		out:    out,
+		start:  len(out),
+		stats:  &stats,
+		sparse: &stats.SparseRegions,
+	}
+	err := d.lz77Huffman(data)
+
+	// Drop the last region if it is too short.
+	last := len(stats.SparseRegions) - 1
+	if last >= 0 && stats.SparseRegions[last].Length < SparseMinLength {
+		stats.SparseRegions = stats.SparseRegions[:last]
+	}
+	return d.out, stats, err
+}
+
+// match adds the match with the offset and length to the statistics.
+func (stats *Stats) match(offset, length int) {
+	stats.Matches++
+	stats.MatchBytes += int64(length)
+	stats.MaxMatchLength = max(stats.MaxMatchLength, length)
+	stats.MaxMatchOffset = max(stats.MaxMatchOffset, offset)
+}
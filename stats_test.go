@@ -0,0 +1,92 @@
+//
+// stats_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestStatsSparseRegions(t *testing.T) {
+	var data []byte
+	data = append(data, []byte("header")...)
+	data = append(data, make([]byte, 200000)...)
+	data = append(data, []byte("middle")...)
+	data = append(data, make([]byte, 1000)...)
+	data = append(data, []byte("trailer")...)
+
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, stats, err := DecompressLZ77HuffmanStats(compressed, nil)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("output mismatch")
+	}
+
+	// The first zero byte is a literal and the rest of the run is
+	// encoded with offset 1 matches. The short run is not reported.
+	expected := []SparseRegion{
+		{Offset: 7, Length: 200000 - 1},
+	}
+	if !reflect.DeepEqual(stats.SparseRegions, expected) {
+		t.Errorf("got %v, expected %v", stats.SparseRegions, expected)
+	}
+}
+
+func TestStatsTokens(t *testing.T) {
+	// The stream of TestLZ77HuffmanOffsetBase: the literals a, b, c
+	// and a match with offset 3 and length 3.
+	data := huffmanTable(map[int]int{
+		'a': 2,
+		'b': 2,
+		'c': 2,
+		256: 3,
+		272: 3,
+	})
+	data = append(data, 0xf0, 0x1b, 0x00, 0x00)
+
+	_, stats, err := DecompressLZ77HuffmanStats(data, nil)
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	expected := Stats{
+		Literals:       3,
+		Matches:        1,
+		LiteralBytes:   3,
+		MatchBytes:     3,
+		MaxMatchLength: 3,
+		MaxMatchOffset: 3,
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("got %+v, expected %+v", stats, expected)
+	}
+
+	input := append(randomBytes(1, 5000), repeatedMatch(70000)...)
+	compressed, err := CompressLZ77Huffman(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stats, err = DecompressLZ77HuffmanStats(compressed, []byte("prefix"))
+	if err != nil {
+		t.Fatalf("decompress failed: %s", err)
+	}
+	if stats.LiteralBytes+stats.MatchBytes != int64(len(input)) {
+		t.Errorf("%d literal and %d match bytes, expected %d bytes",
+			stats.LiteralBytes, stats.MatchBytes, len(input))
+	}
+	if stats.Literals < 5000 || stats.Matches == 0 ||
+		stats.MaxMatchOffset < 300 || stats.MaxMatchLength < 65536 {
+		t.Errorf("unexpected statistics %+v", stats)
+	}
+}
@@ -0,0 +1,198 @@
+//
+// tokens.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+// Token is a decoded literal or match.
+type Token struct {
+	// Literal is the byte of a literal token.
+	Literal byte
+	// Offset is the match offset.
+	Offset int
+	// Length is the match length. The Length is 0 for literals.
+	Length int
+}
+
+// Tokenize decodes the compressed data and returns its literal and
+// match tokens. The uncompressed LZNT1 chunks are returned as
+// literals.
+func Tokenize(data []byte, algo Algorithm) ([]Token, error) {
+	var tokens []Token
+	d := &decoder{
+		discard: true,
+		record:  &tokens,
+	}
+	err := d.decode(algo, data)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DecompressLZ77HuffmanTokens decompresses the LZ77+Huffman data and
+// calls onToken for each literal and match as it is decoded, before
+// the token's output is produced. If onToken returns an error, the
+// decompression stops and the function returns the error.
+func DecompressLZ77HuffmanTokens(data []byte, onToken func(Token) error) (
+	[]byte, error) {
+
+	d := &decoder{
+		onToken: onToken,
+	}
+	err := d.lz77Huffman(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// EditOp specifies a token edit operation.
+type EditOp int
+
+// Token edit operations.
+const (
+	// EditKeep keeps tokens of the old stream.
+	EditKeep EditOp = iota
+	// EditDelete deletes tokens of the old stream.
+	EditDelete
+	// EditInsert inserts tokens of the new stream.
+	EditInsert
+)
+
+var editOpNames = map[EditOp]string{
+	EditKeep:   "keep",
+	EditDelete: "delete",
+	EditInsert: "insert",
+}
+
+func (op EditOp) String() string {
+	name, ok := editOpNames[op]
+	if ok {
+		return name
+	}
+	return "{EditOp}"
+}
+
+// TokenEdit is an operation of a token edit script. The script
+// transforms the tokens of the old stream to the tokens of the new
+// stream.
+type TokenEdit struct {
+	Op EditOp
+	// Count is the number of tokens the operation keeps, deletes, or
+	// inserts.
+	Count int
+	// Tokens are the inserted tokens.
+	Tokens []Token
+}
+
+// TokenDiff tokenizes the compressed streams a and b and returns the
+// shortest edit script that transforms the tokens of a to the tokens
+// of b. The function uses the Myers difference algorithm so it is
+// fast for similar streams. Its running time is O((N+M)D) and memory
+// usage O(D²) where D is the number of edits.
+func TokenDiff(a, b []byte, algo Algorithm) ([]TokenEdit, error) {
+	ta, err := Tokenize(a, algo)
+	if err != nil {
+		return nil, err
+	}
+	tb, err := Tokenize(b, algo)
+	if err != nil {
+		return nil, err
+	}
+	return diffTokens(ta, tb), nil
+}
+
+func diffTokens(a, b []Token) []TokenEdit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	// Find the furthest reaching paths for increasing number of
+	// edits. The trace has the path ends before each round.
+	var d int
+search:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int{}, v[max-d:max+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Backtrack the edits from the end.
+	var edits []TokenEdit
+	add := func(op EditOp, t Token) {
+		edits = append(edits, TokenEdit{
+			Op:     op,
+			Count:  1,
+			Tokens: []Token{t},
+		})
+	}
+	x, y := n, m
+	for ; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int {
+			return prev[k+d]
+		}
+		k := x - y
+		var pk int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := at(pk)
+		py := px - pk
+		for x > px && y > py {
+			x--
+			y--
+			add(EditKeep, a[x])
+		}
+		if x == px {
+			add(EditInsert, b[py])
+		} else {
+			add(EditDelete, a[px])
+		}
+		x, y = px, py
+	}
+	for x > 0 {
+		x--
+		add(EditKeep, a[x])
+	}
+
+	// Reverse the edits and merge the consecutive operations.
+	var result []TokenEdit
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		if e.Op != EditInsert {
+			e.Tokens = nil
+		}
+		last := len(result) - 1
+		if last >= 0 && result[last].Op == e.Op {
+			result[last].Count += e.Count
+			result[last].Tokens = append(result[last].Tokens, e.Tokens...)
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
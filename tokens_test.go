@@ -0,0 +1,143 @@
+//
+// tokens_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	e := newLZ77Encoder(nil)
+	e.literal('a')
+	e.literal('b')
+	e.match(2, 4)
+	tokens, err := Tokenize(e.finish(), AlgorithmLZ77)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Token{
+		{Literal: 'a'},
+		{Literal: 'b'},
+		{Offset: 2, Length: 4},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("got %v, expected %v", tokens, expected)
+	}
+}
+
+func TestDecompressLZ77HuffmanTokens(t *testing.T) {
+	data := append(randomBytes(3, 5000), randomBytes(3, 3000)...)
+	data = append(data, repeatedMatch(huffmanBlockSize)...)
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Tokenize(compressed, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []Token
+	out, err := DecompressLZ77HuffmanTokens(compressed, func(t Token) error {
+		tokens = append(tokens, t)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Errorf("output mismatch")
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("got %d tokens, expected %d", len(tokens), len(expected))
+	}
+
+	// The callback aborts the decoding at the first match.
+	errAbort := errors.New("abort")
+	var count int
+	_, err = DecompressLZ77HuffmanTokens(compressed, func(t Token) error {
+		count++
+		if t.Length > 0 {
+			return errAbort
+		}
+		return nil
+	})
+	if err != errAbort {
+		t.Errorf("got %v, expected %v", err, errAbort)
+	}
+	if count != 5001 {
+		t.Errorf("callback called %d times before the abort", count)
+	}
+}
+
+func applyTokenEdits(a []Token, edits []TokenEdit) []Token {
+	var result []Token
+	var pos int
+	for _, e := range edits {
+		switch e.Op {
+		case EditKeep:
+			result = append(result, a[pos:pos+e.Count]...)
+			pos += e.Count
+		case EditDelete:
+			pos += e.Count
+		case EditInsert:
+			result = append(result, e.Tokens...)
+		}
+	}
+	return result
+}
+
+func TestTokenDiff(t *testing.T) {
+	text := []byte("The quick brown fox jumps over the lazy dog. ")
+	var a, b []byte
+	for i := 0; i < 100; i++ {
+		line := append(bytes.Repeat([]byte{byte('a' + i%26)}, 5), text...)
+		a = append(a, line...)
+		if i == 50 {
+			line = []byte("A new line in the middle. ")
+		}
+		b = append(b, line...)
+	}
+	ca, err := CompressLZ77(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := CompressLZ77(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := TokenDiff(ca, cb, AlgorithmLZ77)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ta, _ := Tokenize(ca, AlgorithmLZ77)
+	tb, _ := Tokenize(cb, AlgorithmLZ77)
+	if !reflect.DeepEqual(applyTokenEdits(ta, edits), tb) {
+		t.Fatalf("edit script does not produce the new tokens")
+	}
+	var changed int
+	for _, e := range edits {
+		if e.Op != EditKeep {
+			changed += e.Count
+		}
+	}
+	if changed > len(tb)/4 {
+		t.Errorf("edit script changes %d tokens of %d", changed, len(tb))
+	}
+
+	edits = diffTokens(ta, ta)
+	if len(edits) != 1 || edits[0].Op != EditKeep {
+		t.Errorf("identical streams: got %v", edits)
+	}
+	if edits := diffTokens(nil, nil); len(edits) != 0 {
+		t.Errorf("empty streams: got %v", edits)
+	}
+}
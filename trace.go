@@ -0,0 +1,88 @@
+//
+// trace.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"io"
+)
+
+// DecodeTrace decompresses data with the algorithm algo and writes a
+// human-readable token trace to w. Each trace line contains the input
+// offset where the token was read, followed by the literal byte or
+// the match offset and length. The function returns the decompressed
+// data.
+func DecodeTrace(data []byte, algo Algorithm, w io.Writer) ([]byte, error) {
+	d := &decoder{
+		trace: w,
+	}
+	err := d.decode(algo, data)
+	return d.out, err
+}
+
+// TokenRecord is a machine-readable trace record of a decoded token.
+type TokenRecord struct {
+	// Type is "literal" or "match".
+	Type string `json:"type"`
+	// Value is the byte of a literal token and 0 for a match token.
+	Value byte `json:"value"`
+	// Offset and Length are the offset and length of a match token.
+	Offset int `json:"offset,omitempty"`
+	Length int `json:"length,omitempty"`
+	// Input is the input offset where the token was read. For
+	// LZ77+Huffman, it is the offset of the byte that holds the
+	// token's first bit.
+	Input int `json:"input"`
+	// Bit is the input bit position of the token. For LZ77+Huffman,
+	// it is the Input offset times 8 plus the position of the
+	// token's first bit in its byte from the most significant bit.
+	// The bitstream words are little-endian so the first bits of a
+	// word are in its second byte. For the byte-aligned algorithms,
+	// it is Input*8.
+	Bit int `json:"bit"`
+	// Output is the output offset of the token's first byte.
+	Output int `json:"output"`
+}
+
+// tokenRecord returns the trace record of the token t that was read
+// at the input offset pos and produces output from the offset output.
+func (d *decoder) tokenRecord(pos, output int, t Token) TokenRecord {
+	r := TokenRecord{
+		Type:   "literal",
+		Value:  t.Literal,
+		Input:  pos,
+		Bit:    pos * 8,
+		Output: output,
+	}
+	if t.Length > 0 {
+		r.Type = "match"
+		r.Value = 0
+		r.Offset = t.Offset
+		r.Length = t.Length
+	}
+	if d.bitPos >= 0 {
+		r.Bit = d.bitPos
+	}
+	return r
+}
+
+// DecodeTraceJSON decompresses data with the algorithm algo and
+// returns a trace record for each decoded token. The records encode
+// to JSON with encoding/json. The uncompressed LZNT1 chunks are
+// returned as literals.
+func DecodeTraceJSON(data []byte, algo Algorithm) ([]TokenRecord, error) {
+	records := []TokenRecord{}
+	d := &decoder{
+		records: &records,
+		bitPos:  -1,
+	}
+	if err := d.decode(algo, data); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
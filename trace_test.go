@@ -0,0 +1,192 @@
+//
+// trace_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeTrace(t *testing.T) {
+	data, err := CompressLZ77([]byte("abcabcabc"))
+	if err != nil {
+		t.Fatalf("CompressLZ77 failed: %s", err)
+	}
+	var trace bytes.Buffer
+	out, err := DecodeTrace(data, AlgorithmLZ77, &trace)
+	if err != nil {
+		t.Fatalf("DecodeTrace failed: %s", err)
+	}
+	if string(out) != "abcabcabc" {
+		t.Errorf("unexpected output %q", out)
+	}
+	expected := []string{
+		"00000004: literal 61",
+		"00000005: literal 62",
+		"00000006: literal 63",
+		"00000007: match offset=3 length=6",
+	}
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("got %d trace lines, expected %d:\n%s",
+			len(lines), len(expected), trace.String())
+	}
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("line %d: got %q, expected %q", i, line, expected[i])
+		}
+	}
+}
+
+func TestDecodeTraceLZNT1(t *testing.T) {
+	var trace bytes.Buffer
+	_, err := DecodeTrace([]byte{0x03, 0x30, 'a', 'b', 'c', 'd'},
+		AlgorithmLZNT1, &trace)
+	if err != nil {
+		t.Fatalf("DecodeTrace failed: %s", err)
+	}
+	if !strings.HasPrefix(trace.String(),
+		"00000000: chunk compressed=false length=4\n"+
+			"00000002: literal 61\n") {
+		t.Errorf("unexpected trace:\n%s", trace.String())
+	}
+}
+
+func TestDecodeTraceJSON(t *testing.T) {
+	data, err := CompressLZ77([]byte("abcabcabc"))
+	if err != nil {
+		t.Fatalf("CompressLZ77 failed: %s", err)
+	}
+	records, err := DecodeTraceJSON(data, AlgorithmLZ77)
+	if err != nil {
+		t.Fatalf("DecodeTraceJSON failed: %s", err)
+	}
+	expected := []TokenRecord{
+		{Type: "literal", Value: 'a', Input: 4, Bit: 32, Output: 0},
+		{Type: "literal", Value: 'b', Input: 5, Bit: 40, Output: 1},
+		{Type: "literal", Value: 'c', Input: 6, Bit: 48, Output: 2},
+		{Type: "match", Offset: 3, Length: 6, Input: 7, Bit: 56, Output: 3},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("got %+v, expected %+v", records, expected)
+	}
+
+	js, err := json.Marshal(records[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != `{"type":"match","value":0,"offset":3,"length":6,"input":7,"bit":56,"output":3}` {
+		t.Errorf("unexpected JSON %s", js)
+	}
+
+	// The zero literal has its value in the JSON.
+	data, err = CompressLZ77([]byte{0})
+	if err != nil {
+		t.Fatalf("CompressLZ77 failed: %s", err)
+	}
+	records, err = DecodeTraceJSON(data, AlgorithmLZ77)
+	if err != nil {
+		t.Fatalf("DecodeTraceJSON failed: %s", err)
+	}
+	js, err = json.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != `[{"type":"literal","value":0,"input":4,"bit":32,"output":0}]` {
+		t.Errorf("unexpected JSON %s", js)
+	}
+}
+
+func TestDecodeTraceJSONLZ77Huffman(t *testing.T) {
+	// The stream of TestLZ77HuffmanOffsetBase: the bits are 00=a,
+	// 01=b, 10=c, and 111=272 with the offset bit 1.
+	data := huffmanTable(map[int]int{
+		'a': 2,
+		'b': 2,
+		'c': 2,
+		256: 3,
+		272: 3,
+	})
+	data = append(data, 0xf0, 0x1b, 0x00, 0x00)
+
+	records, err := DecodeTraceJSON(data, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatalf("DecodeTraceJSON failed: %s", err)
+	}
+	// The first word is at the offset 256 and its first bits are in
+	// the byte 257.
+	expected := []TokenRecord{
+		{Type: "literal", Value: 'a', Input: 257, Bit: 2056, Output: 0},
+		{Type: "literal", Value: 'b', Input: 257, Bit: 2058, Output: 1},
+		{Type: "literal", Value: 'c', Input: 257, Bit: 2060, Output: 2},
+		{Type: "match", Offset: 3, Length: 3, Input: 257, Bit: 2062, Output: 3},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("got %+v, expected %+v", records, expected)
+	}
+
+	// The symbol 271 is a match with offset 1 and an extended length
+	// byte. The byte follows the first two words and the third word
+	// follows the byte.
+	var lengths [huffmanSymbols]uint8
+	lengths['a'] = 1
+	lengths[huffmanEOF] = 2
+	lengths[271] = 2
+	symLen := packSymbolLength(&lengths)
+	codes := huffmanCodes(symLen)
+	w := newBitWriter(append([]byte{}, symLen...))
+	w.writeBits(uint32(codes['a']), 1)
+	w.writeBits(uint32(codes[271]), 2)
+	w.writeByte(0)
+	for i := 0; i < 40; i++ {
+		w.writeBits(uint32(codes['a']), 1)
+	}
+	w.writeBits(uint32(codes[huffmanEOF]), 2)
+	data = w.flush()
+
+	records, err = DecodeTraceJSON(data, AlgorithmLZ77Huffman)
+	if err != nil {
+		t.Fatalf("DecodeTraceJSON failed: %s", err)
+	}
+	if len(records) != 42 {
+		t.Fatalf("got %d records, expected 42", len(records))
+	}
+	words := []int{256, 258, 261}
+	var bit int
+	for i, r := range records {
+		word, pos := words[bit/16], bit%16
+		input := word
+		if pos < 8 {
+			input++
+		}
+		if r.Input != input || r.Bit != input*8+pos%8 {
+			t.Errorf("record %d: got input %d, bit %d, expected %d, %d",
+				i, r.Input, r.Bit, input, input*8+pos%8)
+		}
+		if r.Type == "match" {
+			bit += 2
+		} else {
+			bit++
+		}
+	}
+
+	if _, err := DecodeTraceJSON(data[:100], AlgorithmLZ77Huffman); err == nil {
+		t.Errorf("truncated input decoded")
+	}
+}
+
+func TestDecodeTraceUnknownAlgorithm(t *testing.T) {
+	_, err := DecodeTrace(nil, Algorithm(42), &bytes.Buffer{})
+	if err == nil {
+		t.Errorf("DecodeTrace succeeded for unknown algorithm")
+	}
+}
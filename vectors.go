@@ -0,0 +1,124 @@
+//
+// vectors.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"strings"
+)
+
+// knownVector is a compressed stream and its decompressed data.
+type knownVector struct {
+	compressed []byte
+	expected   string
+}
+
+// knownVectors are the example streams of the MS-XCA specification
+// (MS-XCA 3). The specification's examples are the output of the
+// Windows compressors.
+var knownVectors = map[string]knownVector{
+	// The alphabet without matches.
+	"lz77-literals": {
+		compressed: []byte{
+			0x3f, 0x00, 0x00, 0x00, 0x61, 0x62, 0x63, 0x64,
+			0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c,
+			0x6d, 0x6e, 0x6f, 0x70, 0x71, 0x72, 0x73, 0x74,
+			0x75, 0x76, 0x77, 0x78, 0x79, 0x7a,
+		},
+		expected: "abcdefghijklmnopqrstuvwxyz",
+	},
+	// The string "abc" repeated 100 times: three literals and one
+	// overlapping match of 297 bytes.
+	"lz77-repeated": {
+		compressed: []byte{
+			0xff, 0xff, 0xff, 0x1f, 0x61, 0x62, 0x63, 0x17,
+			0x00, 0x0f, 0xff, 0x26, 0x01,
+		},
+		expected: strings.Repeat("abc", 100),
+	},
+	// The alphabet in a single block without matches.
+	"lz77huffman-literals": {
+		compressed: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x50, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55,
+			0x55, 0x55, 0x55, 0x45, 0x44, 0x04, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0xd8, 0x52, 0x3e, 0xd7, 0x94, 0x11, 0x5b, 0xe9,
+			0x19, 0x5f, 0xf9, 0xd6, 0x7c, 0xdf, 0x8d, 0x04,
+			0x00, 0x00, 0x00, 0x00,
+		},
+		expected: "abcdefghijklmnopqrstuvwxyz",
+	},
+	// A compressed chunk of text with short matches.
+	"lznt1-text": {
+		compressed: []byte{
+			0x38, 0xb0, 0x88, 0x46, 0x23, 0x20, 0x00, 0x20,
+			0x47, 0x20, 0x41, 0x00, 0x10, 0xa2, 0x47, 0x01,
+			0xa0, 0x45, 0x20, 0x44, 0x00, 0x08, 0x45, 0x01,
+			0x50, 0x79, 0x00, 0xc0, 0x45, 0x20, 0x05, 0x24,
+			0x13, 0x88, 0x05, 0xb4, 0x02, 0x4a, 0x44, 0xef,
+			0x03, 0x58, 0x02, 0x8c, 0x09, 0x16, 0x01, 0x48,
+			0x45, 0x00, 0xbe, 0x00, 0x9e, 0x00, 0x04, 0x01,
+			0x18, 0x90, 0x00,
+		},
+		expected: "F# F# G A A G F# E D D E F# F# E E F# F# G A A G F# E D D E F# E D D E E F# D E F# G F# D E F# G F# E D E A F# F# G A A G F# E D D E F# E D D\x00",
+	},
+}
+
+// DecodeKnownVector returns the compressed stream and the
+// decompressed data of the known test vector name. The vectors are
+// the examples of the MS-XCA specification, produced by the Windows
+// compressors, so they test the interoperability with Windows
+// without the Windows binaries. The vectors are:
+//
+//	lz77-literals         LZ77, all literals
+//	lz77-repeated         LZ77, highly compressible
+//	lz77huffman-literals  LZ77+Huffman, single block, all literals
+//	lznt1-text            LZNT1, single compressed chunk
+//
+// The set is incomplete: it does not have a multi-block LZ77+Huffman
+// vector or a highly compressible LZ77+Huffman vector. They are added
+// when real Windows captures of them are available.
+//
+// The function returns false if the vector is unknown. The returned
+// slices are copies that the caller can modify.
+func DecodeKnownVector(name string) (compressed, expected []byte, ok bool) {
+	v, ok := knownVectors[name]
+	if !ok {
+		return nil, nil, false
+	}
+	return append([]byte{}, v.compressed...), []byte(v.expected), true
+}
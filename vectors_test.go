@@ -0,0 +1,52 @@
+//
+// vectors_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeKnownVector(t *testing.T) {
+	formats := map[string]Algorithm{
+		"lz77-literals":        AlgorithmLZ77,
+		"lz77-repeated":        AlgorithmLZ77,
+		"lz77huffman-literals": AlgorithmLZ77Huffman,
+		"lznt1-text":           AlgorithmLZNT1,
+	}
+	if len(formats) != len(knownVectors) {
+		t.Errorf("%d formats for %d vectors", len(formats), len(knownVectors))
+	}
+	for name, algo := range formats {
+		compressed, expected, ok := DecodeKnownVector(name)
+		if !ok {
+			t.Fatalf("%s: unknown vector", name)
+		}
+		out, err := DecompressWithOptions(algo, compressed, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: decompress failed: %s", name, err)
+		}
+		if !bytes.Equal(out, expected) {
+			t.Errorf("%s: got %q, expected %q", name, out, expected)
+		}
+		if err := Verify(algo, compressed); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+
+		// The caller owns the returned slices.
+		compressed[0] ^= 0xff
+		again, _, _ := DecodeKnownVector(name)
+		if again[0] == compressed[0] {
+			t.Errorf("%s: vector modified through the returned slice", name)
+		}
+	}
+	if _, _, ok := DecodeKnownVector("unknown"); ok {
+		t.Errorf("unknown vector found")
+	}
+}
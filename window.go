@@ -0,0 +1,195 @@
+//
+// window.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"io"
+)
+
+// windowSize is the size of the circular buffer of the streaming
+// decoders. The buffer holds the MatchWindowSize bytes of the match
+// window and the output that is not yet written to the sink. The size
+// is a power of two so the buffer positions are computed with
+// windowMask.
+const (
+	windowSize = 2 * MatchWindowSize
+	windowMask = windowSize - 1
+)
+
+// window implements the circular output buffer of the streaming
+// decoders. The decoded bytes are written to the buffer and they are
+// emitted to the sink before the buffer position is reused. The peak
+// memory of the decoder is constant regardless of the output size.
+type window struct {
+	buf []byte
+
+	// pos is the number of bytes written to the window and flushed
+	// is the number of bytes emitted to the sink. The positions are
+	// stream offsets; the buffer index of the offset pos is
+	// pos&windowMask.
+	pos     int
+	flushed int
+
+	sink func(data []byte) error
+
+	// segmentSize is the size of the segments that the window emits
+	// to the sink. The segment collects the bytes of the current
+	// segment. The value 0 emits the output as the buffer fills.
+	segmentSize int
+	segment     []byte
+}
+
+// newWindow creates a new window that emits its output to sink in
+// segments of segmentSize bytes.
+func newWindow(sink func(data []byte) error, segmentSize int) *window {
+	w := &window{
+		buf:         make([]byte, windowSize),
+		sink:        sink,
+		segmentSize: segmentSize,
+	}
+	if segmentSize > 0 {
+		w.segment = make([]byte, 0, segmentSize)
+	}
+	return w
+}
+
+// writerSink returns a window sink that writes the output to w and
+// adds the number of bytes that w accepted to n.
+func writerSink(w io.Writer, n *int64) func(data []byte) error {
+	return func(data []byte) error {
+		m, err := w.Write(data)
+		*n += int64(m)
+		return err
+	}
+}
+
+// pending returns the number of bytes that are not yet emitted to the
+// sink.
+func (w *window) pending() int {
+	return w.pos - w.flushed
+}
+
+// avail returns the number of bytes that can be written to the
+// buffer before the pending output must be emitted.
+func (w *window) avail() int {
+	return windowSize - w.pending()
+}
+
+// writeByte writes the byte b to the window.
+func (w *window) writeByte(b byte) error {
+	if w.avail() == 0 {
+		if err := w.emit(); err != nil {
+			return err
+		}
+	}
+	w.buf[w.pos&windowMask] = b
+	w.pos++
+	return w.spill()
+}
+
+// write writes data to the window.
+func (w *window) write(data []byte) error {
+	for len(data) > 0 {
+		if w.avail() == 0 {
+			if err := w.emit(); err != nil {
+				return err
+			}
+		}
+		dst := w.pos & windowMask
+		n := copy(w.buf[dst:min(windowSize, dst+w.avail())], data)
+		w.pos += n
+		data = data[n:]
+	}
+	return w.spill()
+}
+
+// copyMatch copies a match of length bytes from offset bytes before
+// the current position. The offset must not exceed MatchWindowSize
+// and the window must have at least offset bytes. The copies are
+// split at the wraparound points of the source and destination. The
+// overlapping matches repeat the last offset bytes: the copied bytes
+// are a multiple of offset and the source extends to them as the copy
+// proceeds.
+func (w *window) copyMatch(offset, length int) error {
+	var copied int
+	for copied < length {
+		if w.avail() == 0 {
+			if err := w.emit(); err != nil {
+				return err
+			}
+		}
+		distance := offset
+		if copied >= offset {
+			distance = (copied/offset + 1) * offset
+			if distance > MatchWindowSize {
+				distance = MatchWindowSize / offset * offset
+			}
+		}
+		dst := w.pos & windowMask
+		src := (w.pos - distance) & windowMask
+		n := min(length-copied, distance, w.avail(),
+			windowSize-dst, windowSize-src)
+		copy(w.buf[dst:dst+n], w.buf[src:src+n])
+		w.pos += n
+		copied += n
+	}
+	return w.spill()
+}
+
+// byteAt returns the byte offset bytes before the current position.
+func (w *window) byteAt(offset int) byte {
+	return w.buf[(w.pos-offset)&windowMask]
+}
+
+// spill emits the pending output if it fills the current segment.
+func (w *window) spill() error {
+	if w.segmentSize > 0 &&
+		len(w.segment)+w.pending() >= w.segmentSize {
+		return w.emit()
+	}
+	return nil
+}
+
+// emit emits the pending output to the sink. The output is emitted
+// in full segments if the window has a segment size.
+func (w *window) emit() error {
+	for w.pending() > 0 {
+		start := w.flushed & windowMask
+		end := min(windowSize, start+w.pending())
+		data := w.buf[start:end]
+		if w.segmentSize > 0 {
+			data = data[:min(len(data), w.segmentSize-len(w.segment))]
+			w.segment = append(w.segment, data...)
+			if len(w.segment) == w.segmentSize {
+				if err := w.sink(w.segment); err != nil {
+					return err
+				}
+				w.segment = w.segment[:0]
+			}
+		} else if err := w.sink(data); err != nil {
+			return err
+		}
+		w.flushed += len(data)
+	}
+	return nil
+}
+
+// flush emits all pending output to the sink, including the last
+// partial segment.
+func (w *window) flush() error {
+	if err := w.emit(); err != nil {
+		return err
+	}
+	if len(w.segment) > 0 {
+		err := w.sink(w.segment)
+		w.segment = w.segment[:0]
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+//
+// window_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestWindowWraparound(t *testing.T) {
+	// The matches start near the wraparound points of the buffer so
+	// the sources and destinations are split at the end of the
+	// buffer.
+	type op struct {
+		literals int
+		offset   int
+		length   int
+	}
+	var ops []op
+	for i := 0; i < 40; i++ {
+		ops = append(ops, op{
+			literals: windowSize - 7 - i*13,
+			offset:   1 + i*811%MatchWindowSize,
+			length:   3 + i*977,
+		}, op{
+			offset: MatchWindowSize,
+			length: windowSize + i,
+		}, op{
+			offset: 3,
+			length: 70000,
+		})
+	}
+	for _, segmentSize := range []int{0, 1, 1000, windowSize + 5} {
+		var out bytes.Buffer
+		var segments []int
+		w := newWindow(func(p []byte) error {
+			segments = append(segments, len(p))
+			out.Write(p)
+			return nil
+		}, segmentSize)
+		d := &decoder{}
+		for i, op := range ops {
+			data := randomBytes(int64(i), op.literals)
+			if err := w.write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.literals(0, data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.copyMatch(op.offset, op.length); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.match(0, op.offset, op.length); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.flush(); err != nil {
+			t.Fatal(err)
+		}
+		// The last segment can be shorter.
+		for i := 0; segmentSize > 0 && i < len(segments)-1; i++ {
+			if segments[i] != segmentSize {
+				t.Errorf("segment size %d: segment %d has %d bytes",
+					segmentSize, i, segments[i])
+			}
+		}
+		if !bytes.Equal(out.Bytes(), d.out) {
+			t.Errorf("segment size %d: output mismatch", segmentSize)
+		}
+		if len(w.buf) != windowSize {
+			t.Errorf("window grew to %d bytes", len(w.buf))
+		}
+	}
+}
+
+func TestDecompressToWriterMemory(t *testing.T) {
+	var data []byte
+	for i := 0; i < 256; i++ {
+		data = append(data, randomBytes(int64(i), 1000)...)
+		data = append(data, repeatedMatch(200000)...)
+	}
+	compressed, err := CompressLZ77Huffman(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	n, err := DecompressToWriter(AlgorithmLZ77Huffman, compressed,
+		io.Discard, nil)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("got %d bytes, expected %d", n, len(data))
+	}
+	// The decoder allocates its window and the decoding table. The
+	// allocations do not depend on the output size.
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 4*windowSize {
+		t.Errorf("decoding %d bytes allocated %d bytes", n, alloc)
+	}
+}
@@ -0,0 +1,379 @@
+//
+// writer.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	errWriterClosed    = errors.New("Writer closed")
+	errInvalidState    = errors.New("Invalid checkpoint state")
+	checkpointMagic    = []byte("XPW1")
+	writerSlideTrigger = 8 * lz77MaxOffset
+)
+
+// Writer implements a streaming plain LZ77 compressor. The
+// compressed stream is identical to the output of CompressLZ77 for
+// the same input data. The output is written to the underlying writer
+// as soon as it is final. The compressor holds back the output of the
+// current flag group and, if a shared length nibble is pending, the
+// output starting from the nibble byte.
+type Writer struct {
+	w      io.Writer
+	buf    []byte
+	pos    int
+	enc    *lz77Encoder
+	m      *matcher
+	err    error
+	closed bool
+}
+
+// NewWriter creates a new Writer that writes the compressed data to
+// w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:   w,
+		enc: newLZ77Encoder(nil),
+		m:   newMatcher(lz77MaxOffset, lz77MaxMatch, maxChainLen),
+	}
+}
+
+// Write compresses the data p.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf = append(w.buf, p...)
+
+	// Encode all positions that have the full match lookahead.
+	end := len(w.buf) - lz77MaxMatch
+	if w.pos < end {
+		w.pos, w.err = w.enc.encode(w.m, w.buf, w.pos, end, lz77MaxOffset)
+		if w.err != nil {
+			return 0, w.err
+		}
+		w.slide()
+		w.err = w.flush()
+		if w.err != nil {
+			return 0, w.err
+		}
+	}
+	return len(p), nil
+}
+
+// Close compresses all pending data and terminates the compressed
+// stream. Close does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return errWriterClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	w.closed = true
+	w.pos, w.err = w.enc.encode(w.m, w.buf, w.pos, len(w.buf),
+		lz77MaxOffset)
+	if w.err != nil {
+		return w.err
+	}
+	_, w.err = w.w.Write(w.enc.finish())
+	return w.err
+}
+
+// slide drops the history that is no longer reachable by the match
+// offsets.
+func (w *Writer) slide() {
+	if w.pos < writerSlideTrigger {
+		return
+	}
+	size := w.m.mask + 1
+	shift := (min(w.pos, w.m.next) - lz77MaxOffset) / size * size
+	if shift <= 0 {
+		return
+	}
+	w.m.slide(shift)
+	n := copy(w.buf, w.buf[shift:])
+	w.buf = w.buf[:n]
+	w.pos -= shift
+}
+
+// flush writes the final part of the encoded output to the underlying
+// writer.
+func (w *Writer) flush() error {
+	e := w.enc
+	final := e.flagPos
+	if e.nibblePos != noPosition && e.nibblePos < final {
+		final = e.nibblePos
+	}
+	if final == 0 {
+		return nil
+	}
+	_, err := w.w.Write(e.out[:final])
+	if err != nil {
+		return err
+	}
+	n := copy(e.out, e.out[final:])
+	e.out = e.out[:n]
+	e.flagPos -= final
+	if e.nibblePos != noPosition {
+		e.nibblePos -= final
+	}
+	return nil
+}
+
+// Checkpoint returns the serialized compressor state. The state
+// covers all data written to the Writer and it can be restored with
+// RestoreWriter. The compressed output written to the underlying
+// writer before the checkpoint is not part of the state.
+func (w *Writer) Checkpoint() ([]byte, error) {
+	if w.closed {
+		return nil, errWriterClosed
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	e := w.enc
+
+	state := append([]byte{}, checkpointMagic...)
+	state = binary.LittleEndian.AppendUint32(state, e.flags)
+	state = binary.LittleEndian.AppendUint32(state, uint32(e.flagCount))
+	state = binary.LittleEndian.AppendUint32(state, uint32(e.flagPos))
+	state = binary.LittleEndian.AppendUint32(state, uint32(e.nibblePos+1))
+	state = appendState(state, e.out)
+	state = binary.LittleEndian.AppendUint32(state, uint32(w.pos))
+	state = appendState(state, w.buf)
+
+	return state, nil
+}
+
+func appendState(state, data []byte) []byte {
+	state = binary.LittleEndian.AppendUint32(state, uint32(len(data)))
+	return append(state, data...)
+}
+
+func readState(in *input) ([]byte, error) {
+	l, err := in.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	if int(l) > in.Avail() {
+		return nil, TruncatedInput
+	}
+	data := append([]byte{}, in.input[in.pos:in.pos+int(l)]...)
+	in.pos += int(l)
+	return data, nil
+}
+
+// RestoreWriter restores a Writer from the checkpoint state. The
+// restored Writer writes the rest of the compressed stream to out.
+func RestoreWriter(state []byte, out io.Writer) (*Writer, error) {
+	if !bytes.HasPrefix(state, checkpointMagic) {
+		return nil, errInvalidState
+	}
+	in := &input{
+		input: state,
+		pos:   len(checkpointMagic),
+	}
+	w := NewWriter(out)
+	e := w.enc
+
+	var vals [4]uint32
+	var err error
+	for i := range vals {
+		vals[i], err = in.ReadUint32()
+		if err != nil {
+			return nil, errInvalidState
+		}
+	}
+	e.flags = vals[0]
+	e.flagCount = uint(vals[1])
+	e.flagPos = int(vals[2])
+	e.nibblePos = int(vals[3]) - 1
+
+	e.out, err = readState(in)
+	if err != nil {
+		return nil, errInvalidState
+	}
+	pos, err := in.ReadUint32()
+	if err != nil {
+		return nil, errInvalidState
+	}
+	w.pos = int(pos)
+	w.buf, err = readState(in)
+	if err != nil || in.Avail() != 0 {
+		return nil, errInvalidState
+	}
+	if e.flagCount > 32 || e.flagPos+4 > len(e.out) ||
+		e.nibblePos >= len(e.out) || w.pos > len(w.buf) {
+		return nil, errInvalidState
+	}
+
+	// The matcher rebuilds the hash chains for the match window
+	// on the next search.
+	start := w.pos - w.m.mask - 1
+	if start < 0 {
+		start = 0
+	}
+	w.m.next = start
+	return w, nil
+}
+
+// huffmanWriterSlideTrigger is the buffered input size after which
+// the HuffmanWriter drops the history before its match window.
+const huffmanWriterSlideTrigger = 8 * MatchWindowSize
+
+// HuffmanWriter implements a streaming LZ77+Huffman compressor. The
+// compressed stream is identical to the output of CompressLZ77Huffman
+// for the same input data. The compressor buffers the tokens of one
+// block of huffmanBlockSize output bytes: when the block is complete,
+// the first pass computes the symbol frequencies and builds the
+// block's Huffman table and the second pass writes the coded tokens.
+// The memory use is bounded by the block and the match window.
+type HuffmanWriter struct {
+	w      io.Writer
+	enc    *Encoder
+	bw     *bitWriter
+	buf    []byte
+	pos    int
+	m      *matcher
+	err    error
+	closed bool
+
+	// tokens are the tokens of the current block and produced is
+	// their output size.
+	tokens   []lzToken
+	produced int
+}
+
+// NewHuffmanWriter creates a new HuffmanWriter that writes the
+// compressed data to w.
+func NewHuffmanWriter(w io.Writer) *HuffmanWriter {
+	return &HuffmanWriter{
+		w:   w,
+		enc: new(Encoder),
+		m:   newMatcher(MatchWindowSize, huffmanMaxMatch, maxChainLen),
+	}
+}
+
+// Write compresses the data p.
+func (w *HuffmanWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	// The data is buffered in pieces so the buffer does not grow
+	// with p.
+	for i := 0; i < len(p); i += huffmanBlockSize {
+		w.buf = append(w.buf, p[i:min(len(p), i+huffmanBlockSize)]...)
+
+		// Encode all positions that have the full match lookahead.
+		w.err = w.encode(len(w.buf) - huffmanMaxMatch)
+		if w.err != nil {
+			return 0, w.err
+		}
+		w.slide()
+	}
+	return len(p), nil
+}
+
+// Close compresses all pending data and terminates the compressed
+// stream. Close does not close the underlying writer.
+func (w *HuffmanWriter) Close() error {
+	if w.closed {
+		return errWriterClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	w.closed = true
+	if w.bw == nil && w.pos == 0 {
+		// The stream fits into one block and the tokens are selected
+		// as by CompressLZ77Huffman.
+		tokens, err := w.enc.huffmanTokens(w.buf)
+		if err != nil {
+			w.err = err
+			return err
+		}
+		w.tokens = tokens
+	} else if w.err = w.encode(len(w.buf)); w.err != nil {
+		return w.err
+	}
+	if len(w.tokens) > 0 || w.bw == nil {
+		for _, block := range huffmanBlocks(w.tokens) {
+			w.bw = w.enc.huffmanEncodeBlock(w.bw, block, nil, w.w)
+		}
+	}
+	w.bw.flush()
+	w.err = w.bw.err
+	return w.err
+}
+
+// encode finds the tokens for the buffered input until end and
+// encodes the completed blocks.
+func (w *HuffmanWriter) encode(end int) error {
+	if w.pos >= end {
+		return nil
+	}
+	start := w.pos
+	tokens, err := findTokens(w.m, w.buf, w.pos, end, MatchWindowSize,
+		huffmanMaxMatch, nil)
+	if err != nil {
+		return err
+	}
+	tokens = dropTerminatorMatches(w.buf[start:], tokens)
+	for _, t := range tokens {
+		w.tokens = append(w.tokens, t)
+		if t.length == 0 {
+			w.produced++
+			w.pos++
+		} else {
+			w.produced += t.length
+			w.pos += t.length
+		}
+		if w.produced >= huffmanBlockSize {
+			w.bw = w.enc.huffmanEncodeBlock(w.bw, huffmanBlock{
+				tokens: w.tokens,
+			}, nil, w.w)
+			if w.bw.err != nil {
+				return w.bw.err
+			}
+			w.tokens = w.tokens[:0]
+			w.produced = 0
+		}
+	}
+	return nil
+}
+
+// slide drops the history that is no longer reachable by the match
+// offsets. The long matches can skip positions that the matcher has
+// not yet inserted into its hash chains and the history is kept from
+// the match window of the matcher's next position.
+func (w *HuffmanWriter) slide() {
+	if w.pos < huffmanWriterSlideTrigger {
+		return
+	}
+	size := w.m.mask + 1
+	shift := (min(w.pos, w.m.next) - MatchWindowSize) / size * size
+	if shift <= 0 {
+		return
+	}
+	w.m.slide(shift)
+	n := copy(w.buf, w.buf[shift:])
+	w.buf = w.buf[:n]
+	w.pos -= shift
+}
@@ -0,0 +1,175 @@
+//
+// writer_test.go
+//
+// Copyright (c) 2026 Markku Rossi
+//
+// All rights reserved.
+//
+
+package xpress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func writerInput() []byte {
+	var data []byte
+	for i := 0; i < 20; i++ {
+		data = append(data, randomBytes(int64(i%3), 10000)...)
+		data = append(data, bytes.Repeat([]byte{byte(i)}, 7000+i)...)
+	}
+	return data
+}
+
+func TestWriter(t *testing.T) {
+	data := writerInput()
+	expected, err := CompressLZ77(data)
+	if err != nil {
+		t.Fatalf("CompressLZ77 failed: %s", err)
+	}
+
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	for i := 0; i < len(data); i += 1000 {
+		end := i + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := w.Write(data[i:end]); err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("streaming output differs from CompressLZ77")
+	}
+	if _, err := w.Write([]byte{0}); err == nil {
+		t.Errorf("Write succeeded after Close")
+	}
+}
+
+func TestWriterRepetitive(t *testing.T) {
+	// The match finder inserts the positions of the long matches
+	// lazily so the slide must not pass its next position.
+	for _, data := range [][]byte{
+		bytes.Repeat([]byte("abcdefgh"), 30000),
+		bytes.Repeat([]byte{0}, 300000),
+		append(randomBytes(5, 70000), bytes.Repeat([]byte("xy"), 100000)...),
+	} {
+		var out bytes.Buffer
+		w := NewWriter(&out)
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %s", err)
+		}
+		decompressed, err := DecompressLZ77(out.Bytes())
+		if err != nil || !bytes.Equal(decompressed, data) {
+			t.Errorf("%d bytes: round trip failed: %v", len(data), err)
+		}
+	}
+}
+
+func TestWriterCheckpoint(t *testing.T) {
+	data := writerInput()
+	half := len(data) / 2
+
+	var out1 bytes.Buffer
+	w := NewWriter(&out1)
+	if _, err := w.Write(data[:half]); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	state, err := w.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %s", err)
+	}
+
+	var out2 bytes.Buffer
+	w, err = RestoreWriter(state, &out2)
+	if err != nil {
+		t.Fatalf("RestoreWriter failed: %s", err)
+	}
+	if _, err := w.Write(data[half:]); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+
+	compressed := append(out1.Bytes(), out2.Bytes()...)
+	decompressed, err := DecompressLZ77(compressed)
+	if err != nil {
+		t.Fatalf("DecompressLZ77 failed: %s", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Errorf("round trip failed")
+	}
+	expected, _ := CompressLZ77(data)
+	if !bytes.Equal(compressed, expected) {
+		t.Errorf("restored output differs from CompressLZ77")
+	}
+
+	if _, err := RestoreWriter(state[:len(state)-1], &out2); err == nil {
+		t.Errorf("RestoreWriter accepted truncated state")
+	}
+}
+
+func TestHuffmanWriter(t *testing.T) {
+	var large []byte
+	for i := 0; i < 12; i++ {
+		large = append(large, randomBytes(int64(i), 30000)...)
+		large = append(large, large[len(large)-20000:]...)
+		large = append(large, repeatedMatch(70000+i)...)
+	}
+	inputs := [][]byte{
+		nil,
+		[]byte("abc"),
+		writerInput(),
+		bytes.Repeat([]byte{'a'}, huffmanBlockSize),
+		large,
+	}
+	for i, data := range inputs {
+		expected, err := CompressLZ77Huffman(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []int{1000, 100000} {
+			var out bytes.Buffer
+			w := NewHuffmanWriter(&out)
+			var maxBuf int
+			for j := 0; j < len(data); j += size {
+				if _, err := w.Write(data[j:min(len(data), j+size)]); err != nil {
+					t.Fatalf("Write failed: %s", err)
+				}
+				maxBuf = max(maxBuf, cap(w.buf))
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %s", err)
+			}
+			if !bytes.Equal(out.Bytes(), expected) {
+				t.Errorf("input %d, write size %d: streaming output differs "+
+					"from CompressLZ77Huffman", i, size)
+			}
+			decompressed, err := DecompressLZ77Huffman(out.Bytes(), nil)
+			if err != nil {
+				t.Fatalf("input %d: %s", i, err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Errorf("input %d: output mismatch", i)
+			}
+			if maxBuf > 4*huffmanWriterSlideTrigger {
+				t.Errorf("input %d: writer buffered %d bytes", i, maxBuf)
+			}
+			if len(w.tokens) > huffmanBlockSize {
+				t.Errorf("input %d: %d pending tokens", i, len(w.tokens))
+			}
+			if _, err := w.Write([]byte{0}); err == nil {
+				t.Errorf("Write succeeded after Close")
+			}
+		}
+	}
+}